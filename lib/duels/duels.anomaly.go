@@ -0,0 +1,132 @@
+package duels
+
+import (
+	"backend/lib/rulesets"
+	"backend/lib/schema"
+	"backend/lib/services"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sort"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type Anomaly string
+
+const (
+	AnomalyNegativeDuration Anomaly = "negative_duration"
+	AnomalyDurationExceeded Anomaly = "duration_exceeded"
+	AnomalyNegativeResource Anomaly = "negative_resource"
+	AnomalyResourceExceeded Anomaly = "resource_exceeded"
+	AnomalyUnknownWinner    Anomaly = "unknown_winner"
+	AnomalyEliminatedWinner Anomaly = "eliminated_winner"
+)
+
+func (summary Summary) values() map[string]int {
+	return map[string]int{
+		"ego_count":      summary.EgoCount,
+		"energy":         summary.Energy,
+		"corrupted_data": summary.CorruptedData,
+		"emotional_data": summary.EmotionalData,
+		"quantum_data":   summary.QuantumData,
+		"logical_data":   summary.LogicalData,
+	}
+}
+
+// DetectAnomalies checks a result against the bounds of the ruleset its duel was played with
+func DetectAnomalies(result *DuelResult) []Anomaly {
+	// Sessions created before rulesets existed were played with the default one
+	ruleset := rulesets.Default(result.SessionData.DuelType)
+	if result.SessionData.Ruleset != nil {
+		ruleset = *result.SessionData.Ruleset
+	}
+	bounds := ruleset.Bounds()
+
+	detected := make(map[Anomaly]bool)
+	if result.Outcome.Duration < 0 {
+		detected[AnomalyNegativeDuration] = true
+	} else if result.Outcome.Duration > int64(bounds.MaxDuration) {
+		detected[AnomalyDurationExceeded] = true
+	}
+
+	max_resources := bounds.MaxResources.Values()
+	for _, summary := range []Summary{result.P1Summary, result.P2Summary} {
+		for resource, value := range summary.values() {
+			if value < 0 {
+				detected[AnomalyNegativeResource] = true
+			} else if value > max_resources[resource] {
+				detected[AnomalyResourceExceeded] = true
+			}
+		}
+	}
+
+	switch result.Outcome.Winner {
+	case P1:
+		if result.P1Summary.EgoCount == 0 {
+			detected[AnomalyEliminatedWinner] = true
+		}
+	case P2:
+		if result.P2Summary.EgoCount == 0 {
+			detected[AnomalyEliminatedWinner] = true
+		}
+	case Default:
+	default:
+		detected[AnomalyUnknownWinner] = true
+	}
+
+	anomalies := make([]Anomaly, 0, len(detected))
+	for anomaly := range detected {
+		anomalies = append(anomalies, anomaly)
+	}
+	sort.Slice(anomalies, func(i, j int) bool {
+		return anomalies[i] < anomalies[j]
+	})
+	return anomalies
+}
+
+// isServerForfeit tells if a result is a forfeit built by the server, which is trusted without being checked.
+// The method alone is reported by the nexuspools, only the claim of the forfeit proves the server built it.
+func isServerForfeit(cache *services.Cache, result *DuelResult) (bool, error) {
+	if result.Outcome.Method != schema.WinningMethodForfeit {
+		return false, nil
+	}
+	winner, err := cache.GetForfeitClaim(result.SessionID)
+	if err != nil {
+		return false, err
+	}
+	return winner != "" && winner == string(result.Outcome.Winner), nil
+}
+
+// flagResult stores a suspicious result for moderation in the transaction storing the result
+func flagResult(ctx context.Context, tx pgx.Tx, cache *services.Cache, result *DuelResult) error {
+	slog.Warn("Suspicious duel result", "SessionID", result.SessionID, "anomalies", result.Anomalies)
+
+	result_json, err := json.Marshal(result)
+	if err != nil {
+		return fmt.Errorf("failed to marshal flagged duel result: %w", err)
+	}
+	reasons := make([]string, 0, len(result.Anomalies))
+	for _, anomaly := range result.Anomalies {
+		reasons = append(reasons, string(anomaly))
+	}
+
+	added, err := services.InsertFlaggedDuel(ctx, tx, services.FlaggedDuelData{
+		SessionID: result.SessionID,
+		DuelType:  result.SessionData.DuelType,
+		Result:    result_json,
+		Anomalies: reasons,
+		FlaggedAt: time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+	if added {
+		if err := cache.IncrDuelMetric(services.DUEL_METRIC_FLAGGED); err != nil {
+			slog.Error("failed to record flagged duel result", "error", err)
+		}
+	}
+	return nil
+}
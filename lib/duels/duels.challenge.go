@@ -0,0 +1,61 @@
+package duels
+
+import (
+	"backend/lib/notifications"
+	"backend/lib/services"
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// expireChallenges settles the friendly challenges left unanswered and notifies both players
+func expireChallenges(ctx context.Context, cache *services.Cache, notify *notifications.NotificationService, now time.Time) error {
+	waiting_room_ids, err := cache.ListExpiredChallenges(now, 100)
+	if err != nil {
+		return err
+	}
+
+	for _, waiting_room_id := range waiting_room_ids {
+		challenge, err := cache.ExpireChallenge(waiting_room_id)
+		if err != nil {
+			slog.Error("failed to expire challenge", "error", err, "waiting_room_id", waiting_room_id)
+			continue
+		}
+		if challenge == nil || notify == nil {
+			continue
+		}
+
+		notify.Send(
+			ctx,
+			notifications.TypeMessage,
+			"duel:challenge:expiration",
+			notifications.PriorityMedium,
+			challenge.Challenger.PID,
+			fiber.Map{
+				"msg": fmt.Sprintf("%s#%s did not answer your friendly duel", challenge.Opponent.Username, challenge.Opponent.Tag),
+			},
+			fiber.Map{
+				"waiting_room_id": waiting_room_id,
+				"opponent_tag":    challenge.Opponent.Tag,
+			},
+		)
+		notify.Send(
+			ctx,
+			notifications.TypeMessage,
+			"duel:challenge:expiration",
+			notifications.PriorityMedium,
+			challenge.Opponent.PID,
+			fiber.Map{
+				"msg": fmt.Sprintf("The friendly duel of %s#%s has expired", challenge.Challenger.Username, challenge.Challenger.Tag),
+			},
+			fiber.Map{
+				"waiting_room_id": waiting_room_id,
+				"opponent_tag":    challenge.Challenger.Tag,
+			},
+		)
+	}
+	return nil
+}
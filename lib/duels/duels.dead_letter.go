@@ -0,0 +1,79 @@
+package duels
+
+import (
+	"backend/lib/services"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// PublishResult signs and appends a duel result to the duel result stream
+func PublishResult(ctx context.Context, cache *services.Cache, hmac_key string, session_id string, payload []byte) (string, error) {
+	if hmac_key == "" {
+		return "", ErrNoSigningKey
+	}
+	timestamp, signature := SignResult(hmac_key, session_id, time.Now(), string(payload))
+
+	id, err := cache.Db.XAdd(ctx, &redis.XAddArgs{
+		Stream: DuelResultStream,
+		Values: map[string]interface{}{
+			"session_id": session_id,
+			"timestamp":  timestamp,
+			"payload":    payload,
+			"hmac":       signature,
+		},
+	}).Result()
+	if err != nil {
+		return "", fmt.Errorf("failed to publish duel result: %w", err)
+	}
+	return id, nil
+}
+
+// DeadLetter stores a result that could not be processed so that it can be inspected and replayed
+func DeadLetter(cache *services.Cache, result *DuelResult, reason error, attempts int) error {
+	payload, err := json.Marshal(result)
+	if err != nil {
+		return fmt.Errorf("failed to marshal dead duel result: %w", err)
+	}
+
+	id, err := cache.AddDeadLetter(&services.DeadLetterData{
+		SessionID: result.SessionID,
+		Payload:   payload,
+		Reason:    reason.Error(),
+		Attempts:  attempts,
+	})
+	if err != nil {
+		return err
+	}
+	slog.Warn("Duel result moved to the dead letters",
+		"id", id,
+		"SessionID", result.SessionID,
+		"reason", reason,
+		"attempts", attempts)
+	return nil
+}
+
+// ReplayDeadLetter publishes a dead result again on the duel result stream and removes it from the dead letters.
+// Results are validated against their cached duel session, so a result can only be replayed until its session
+// expires (DUEL_SESSION_TTL, or DUEL_SESSION_CLOSED_TTL once closed). ErrUnknownSession is returned afterwards.
+func ReplayDeadLetter(ctx context.Context, cache *services.Cache, hmac_key string, dead_letter_id string) error {
+	dead_letter, err := cache.GetDeadLetter(dead_letter_id)
+	if err != nil {
+		return err
+	}
+	if _, err := cache.GetDuelSession(dead_letter.SessionID); errors.Is(err, redis.Nil) {
+		return ErrUnknownSession
+	} else if err != nil {
+		return err
+	}
+
+	if _, err := PublishResult(ctx, cache, hmac_key, dead_letter.SessionID, dead_letter.Payload); err != nil {
+		return err
+	}
+	return cache.DeleteDeadLetter(dead_letter_id)
+}
@@ -0,0 +1,134 @@
+package duels
+
+import (
+	"backend/lib/schema"
+	"backend/lib/services"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+var (
+	ErrNotAPlayer       = errors.New("user is not a player of the duel")
+	ErrAlreadyForfeited = errors.New("duel is already forfeited")
+	ErrNotStarted       = errors.New("duel has not started")
+)
+
+// A disconnected player who does not come back in time forfeits the duel
+const DISCONNECT_GRACE_PERIOD = time.Minute
+
+// Forfeit publishes a synthetic result awarding the duel to the opponent of the loser.
+// The result goes through the duel result stream like the ones reported by nexuspools.
+func Forfeit(ctx context.Context, cache *services.Cache, hmac_key string, session_id string, loser pgtype.UUID) error {
+	session_data, err := cache.GetDuelSession(session_id)
+	if err != nil {
+		return ErrUnknownSession
+	}
+	if session_data.IsClosed() {
+		return ErrSessionClosed
+	}
+
+	var winner PID
+	switch loser {
+	case session_data.P1.PID:
+		winner = P2
+	case session_data.P2.PID:
+		winner = P1
+	default:
+		return ErrNotAPlayer
+	}
+
+	var duration int64
+	if !session_data.StartedAt.IsZero() {
+		duration = int64(time.Since(session_data.StartedAt).Seconds())
+	}
+	payload, err := json.Marshal(DuelResult{
+		Outcome: Outcome{
+			Winner:   winner,
+			Method:   schema.WinningMethodForfeit,
+			Duration: duration,
+		},
+		SessionData: session_data,
+		SessionID:   session_id,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal forfeit result: %w", err)
+	}
+
+	claimed, err := cache.ClaimForfeit(session_id, string(winner))
+	if err != nil {
+		return err
+	}
+	if !claimed {
+		return ErrAlreadyForfeited
+	}
+	if _, err := PublishResult(ctx, cache, hmac_key, session_id, payload); err != nil {
+		if err := cache.ReleaseForfeit(session_id); err != nil {
+			slog.Error("failed to release forfeit", "error", err, "SessionID", session_id)
+		}
+		return err
+	}
+
+	slog.Info("Duel forfeited", "SessionID", session_id, "winner", winner)
+	return nil
+}
+
+// Disconnect starts the grace period of a player who left a running duel
+func Disconnect(cache *services.Cache, session_id string, user_id pgtype.UUID) error {
+	session_data, err := cache.GetDuelSession(session_id)
+	if err != nil {
+		return ErrUnknownSession
+	}
+	if session_data.IsClosed() {
+		return ErrSessionClosed
+	}
+	if session_data.Status != services.DuelSessionRunning {
+		return ErrNotStarted
+	}
+	if user_id != session_data.P1.PID && user_id != session_data.P2.PID {
+		return ErrNotAPlayer
+	}
+
+	now := time.Now()
+	return cache.AddDisconnect(services.DisconnectData{
+		SessionID: session_id,
+		UserID:    user_id,
+		At:        now,
+		Deadline:  now.Add(DISCONNECT_GRACE_PERIOD),
+	})
+}
+
+// Reconnect ends the grace period of a player who came back to its duel
+func Reconnect(cache *services.Cache, session_id string, user_id pgtype.UUID) error {
+	return cache.RemoveDisconnect(session_id, user_id)
+}
+
+// forfeitDisconnected awards the duels whose disconnected player did not come back in time
+func forfeitDisconnected(ctx context.Context, cache *services.Cache, hmac_key string, now time.Time) error {
+	disconnects, err := cache.ListExpiredDisconnects(now, 100)
+	if err != nil {
+		return err
+	}
+
+	for _, disconnect := range disconnects {
+		err := Forfeit(ctx, cache, hmac_key, disconnect.SessionID, disconnect.UserID)
+		switch {
+		case err == nil, errors.Is(err, ErrUnknownSession), errors.Is(err, ErrSessionClosed), errors.Is(err, ErrAlreadyForfeited):
+			// The duel is settled, the disconnect is no longer needed
+		case errors.Is(err, ErrNoSigningKey):
+			return err
+		default:
+			slog.Error("failed to forfeit disconnected player", "error", err, "SessionID", disconnect.SessionID)
+			continue
+		}
+		if err := cache.RemoveDisconnect(disconnect.SessionID, disconnect.UserID); err != nil {
+			slog.Error("failed to remove disconnect", "error", err, "SessionID", disconnect.SessionID)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,242 @@
+package duels
+
+import (
+	"backend/lib/notifications"
+	"backend/lib/seasons"
+	"backend/lib/services"
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	basepool "github.com/ciphrpool/base-pool/gen"
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+var (
+	ErrMatchmakerStarted = errors.New("matchmaker is already started")
+)
+
+const (
+	RANKED_BASE_WINDOW    = 50               // Elo window of a player who just joined the queue
+	RANKED_WINDOW_GROWTH  = 10               // Elo added to the window for each second spent in the queue
+	RANKED_MAX_WINDOW     = 600              // Elo window can not grow beyond this value
+	RANKED_MAX_QUEUE_TIME = 10 * time.Minute // Players are removed from the queue after this time
+	RANKED_TICK_INTERVAL  = 2 * time.Second
+)
+
+// RankedWindow returns the elo window accepted by a player after waiting in the queue
+func RankedWindow(waited time.Duration) uint {
+	window := RANKED_BASE_WINDOW + uint(waited/time.Second)*RANKED_WINDOW_GROWTH
+	if window > RANKED_MAX_WINDOW {
+		return RANKED_MAX_WINDOW
+	}
+	return window
+}
+
+type Matchmaker struct {
+	tick_interval time.Duration
+	is_running    bool
+	mu            sync.Mutex
+}
+
+// NewMatchmaker creates a new ranked matchmaker
+func NewMatchmaker() *Matchmaker {
+	return &Matchmaker{
+		tick_interval: RANKED_TICK_INTERVAL,
+		is_running:    false,
+	}
+}
+
+// Start runs the matchmaking loop until the context is cancelled
+func (m *Matchmaker) Start(ctx context.Context, cache *services.Cache, db *services.Database, notify *notifications.NotificationService) error {
+	if cache == nil || cache.Db == nil {
+		return ErrNilCache
+	}
+	m.mu.Lock()
+	if m.is_running {
+		m.mu.Unlock()
+		return ErrMatchmakerStarted
+	}
+	m.is_running = true
+	m.mu.Unlock()
+
+	slog.Debug("Starting the ranked matchmaker", "tick_interval", m.tick_interval)
+	go func() {
+		ticker := time.NewTicker(m.tick_interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := m.match(ctx, cache, db, notify); err != nil {
+					slog.Error("ranked matchmaking failed", "error", err)
+				}
+			case <-ctx.Done():
+				m.mu.Lock()
+				m.is_running = false
+				m.mu.Unlock()
+				slog.Info("context cancelled, stopping matchmaker")
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+// match pairs the players of the queue whose elo windows overlap
+func (m *Matchmaker) match(ctx context.Context, cache *services.Cache, db *services.Database, notify *notifications.NotificationService) error {
+	// Players keep waiting while the seasons change
+	season, err := seasons.Current(ctx, cache, db)
+	if err != nil {
+		return err
+	}
+	if season == nil {
+		return nil
+	}
+
+	entries, err := cache.ListRankedQueue()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	matched := make([]bool, len(entries))
+	for i := range entries {
+		if matched[i] {
+			continue
+		}
+		waited := now.Sub(entries[i].JoinedAt)
+		if waited > RANKED_MAX_QUEUE_TIME {
+			matched[i] = true
+			m.expire(ctx, cache, notify, entries[i])
+			continue
+		}
+
+		window := RankedWindow(waited)
+		// Entries are ordered by elo, only the following players can be close enough
+		for j := i + 1; j < len(entries); j++ {
+			gap := entries[j].Elo - entries[i].Elo
+			if gap > window {
+				break
+			}
+			if matched[j] || gap > RankedWindow(now.Sub(entries[j].JoinedAt)) {
+				continue
+			}
+
+			claimed, err := cache.ClaimRankedPair(entries[i].UserID, entries[j].UserID)
+			if err != nil {
+				return err
+			}
+			if !claimed {
+				continue
+			}
+			matched[i] = true
+			matched[j] = true
+
+			if err := m.startDuel(ctx, cache, db, notify, season.ID, entries[i], entries[j]); err != nil {
+				slog.Error("failed to start ranked duel", "error", err)
+				m.requeue(ctx, cache, notify, entries[i], entries[j])
+			}
+			break
+		}
+	}
+	return nil
+}
+
+func (m *Matchmaker) expire(ctx context.Context, cache *services.Cache, notify *notifications.NotificationService, entry services.RankedQueueEntry) {
+	if err := cache.LeaveRankedQueue(entry.UserID); err != nil {
+		slog.Error("failed to remove expired ranked queue entry", "error", err, "user_id", services.UUIDToString(entry.UserID))
+		return
+	}
+	notify.Send(
+		ctx,
+		notifications.TypeAlert,
+		"duel:ranked:timeout",
+		notifications.PriorityMedium,
+		entry.UserID,
+		fiber.Map{
+			"msg": "No opponent has been found, you have been removed from the ranked queue",
+		},
+		fiber.Map{},
+	)
+}
+
+// requeue puts back the players of a duel that could not be started, they keep their waiting time
+// so that a persistent failure ends with the queue timeout
+func (m *Matchmaker) requeue(ctx context.Context, cache *services.Cache, notify *notifications.NotificationService, entries ...services.RankedQueueEntry) {
+	for _, entry := range entries {
+		err := cache.RequeueRanked(entry)
+		if err == nil {
+			continue
+		}
+		slog.Error("failed to requeue ranked player", "error", err, "user_id", services.UUIDToString(entry.UserID))
+		notify.Send(
+			ctx,
+			notifications.TypeAlert,
+			"duel:ranked:failure",
+			notifications.PriorityMedium,
+			entry.UserID,
+			fiber.Map{
+				"msg": "The ranked duel could not be started, please join the queue again",
+			},
+			fiber.Map{},
+		)
+	}
+}
+
+func (m *Matchmaker) startDuel(ctx context.Context, cache *services.Cache, db *services.Database, notify *notifications.NotificationService, season_id string, p1 services.RankedQueueEntry, p2 services.RankedQueueEntry) error {
+	query_ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	queries := basepool.New(db.Pool)
+
+	p1_duel_summary_data, err := queries.GetUserDuelSummaryDataById(query_ctx, p1.UserID)
+	if err != nil {
+		return err
+	}
+	p2_duel_summary_data, err := queries.GetUserDuelSummaryDataById(query_ctx, p2.UserID)
+	if err != nil {
+		return err
+	}
+
+	session_id, err := cache.CreateDuelSession(&services.DuelSessionData{
+		DuelType: basepool.DuelTypeRanked,
+		SeasonID: season_id,
+		P1: services.DuelPlayerSummaryData{
+			PID:      p1_duel_summary_data.ID,
+			Elo:      uint(p1_duel_summary_data.Elo),
+			Tag:      p1_duel_summary_data.Tag,
+			Username: p1_duel_summary_data.Username,
+		},
+		P2: services.DuelPlayerSummaryData{
+			PID:      p2_duel_summary_data.ID,
+			Elo:      uint(p2_duel_summary_data.Elo),
+			Tag:      p2_duel_summary_data.Tag,
+			Username: p2_duel_summary_data.Username,
+		},
+	})
+	if err != nil {
+		return err
+	}
+	slog.Debug("Ranked duel matched", "session_id", session_id, "p1_elo", p1.Elo, "p2_elo", p2.Elo)
+
+	// Notify both players with the Duel Session
+	for _, user_id := range []pgtype.UUID{p1.UserID, p2.UserID} {
+		notify.Send(
+			ctx,
+			notifications.TypeRedirect,
+			"duel:acceptance",
+			notifications.PriorityHigh,
+			user_id,
+			fiber.Map{
+				"msg": "A ranked opponent has been found, you will be redirected to the duel...",
+			},
+			fiber.Map{
+				"duel_session_id": session_id,
+				"duel_type":       "ranked",
+			},
+		)
+	}
+	return nil
+}
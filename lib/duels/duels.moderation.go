@@ -0,0 +1,151 @@
+package duels
+
+import (
+	"backend/lib/leaderboards"
+	"backend/lib/notifications"
+	"backend/lib/seasons"
+	"backend/lib/services"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	basepool "github.com/ciphrpool/base-pool/gen"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// lockFlaggedResult returns the stored result of a flagged duel awaiting a review, locked until the end of the transaction
+func lockFlaggedResult(ctx context.Context, tx pgx.Tx, session_id string) (*DuelResult, error) {
+	flagged, err := services.LockFlaggedDuel(ctx, tx, session_id)
+	if err != nil {
+		return nil, err
+	}
+	var result DuelResult
+	if err := json.Unmarshal(flagged.Result, &result); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal flagged duel result: %w", err)
+	}
+	return &result, nil
+}
+
+// ApproveFlaggedResult accepts a flagged result and applies what was deferred while it was reviewed:
+// the rating changes of a ranked duel of the current season, the statistics and the tournament progress
+func ApproveFlaggedResult(ctx context.Context, cache *services.Cache, db *services.Database, notify *notifications.NotificationService, session_id string) error {
+	query_ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	queries := basepool.New(db.Pool)
+
+	defer cancel()
+	// Start transaction
+	tx, err := db.Pool.Begin(query_ctx)
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback(query_ctx)
+
+	result, err := lockFlaggedResult(query_ctx, tx, session_id)
+	if err != nil {
+		return err
+	}
+
+	// The ratings of a past season have been reset, its duels are no longer rated
+	var season *services.SeasonData
+	rated := false
+	if result.SessionData.DuelType == basepool.DuelTypeRanked {
+		season, err = seasons.Current(query_ctx, cache, db)
+		if err != nil {
+			return err
+		}
+		rated = season != nil && season.ID == result.SessionData.SeasonID
+	}
+
+	var p1_elo_delta, p2_elo_delta int
+	if rated {
+		p1_elo_delta, p2_elo_delta, err = calculateEloChanges(query_ctx, tx, result)
+		if err != nil {
+			return fmt.Errorf("failed to compute elo changes: %w", err)
+		}
+
+		qtx := queries.WithTx(tx)
+		err = qtx.UpdatePlayerElo(query_ctx, basepool.UpdatePlayerEloParams{
+			EloDelta: int32(p1_elo_delta),
+			UserID:   result.SessionData.P1.PID,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to update p1 elo: %w", err)
+		}
+
+		err = qtx.UpdatePlayerElo(query_ctx, basepool.UpdatePlayerEloParams{
+			EloDelta: int32(p2_elo_delta),
+			UserID:   result.SessionData.P2.PID,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to update p2 elo: %w", err)
+		}
+
+		if err := services.UpdateDuelResultEloDeltas(query_ctx, tx, session_id, p1_elo_delta, p2_elo_delta); err != nil {
+			return err
+		}
+		if err := recordPeaks(query_ctx, tx, season.ID, result, p1_elo_delta, p2_elo_delta); err != nil {
+			return err
+		}
+	}
+
+	if err := services.ReviewFlaggedDuel(query_ctx, tx, session_id, services.FlaggedDuelApproved); err != nil {
+		return err
+	}
+
+	// Commit transaction
+	if err := tx.Commit(query_ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	slog.Info("Flagged duel result approved", "SessionID", session_id, "p1_elo_delta", p1_elo_delta, "p2_elo_delta", p2_elo_delta)
+
+	switch result.SessionData.DuelType {
+	case basepool.DuelTypeRanked:
+		recordStats(cache, result)
+		for _, pid := range []pgtype.UUID{result.SessionData.P1.PID, result.SessionData.P2.PID} {
+			if err := leaderboards.Refresh(query_ctx, cache, db, pid); err != nil {
+				slog.Error("failed to update the leaderboards", "error", err, "SessionID", session_id)
+			}
+		}
+	case basepool.DuelTypeFriendly:
+		recordStats(cache, result)
+	case basepool.DuelTypeTournament:
+		recordStats(cache, result)
+		if result.SessionData.TournamentID != "" {
+			return reportTournamentResult(ctx, cache, db, notify, result, tournamentWinner(result))
+		}
+	}
+	return nil
+}
+
+// DismissFlaggedResult rejects a flagged result, which stays stored without rating change nor statistics.
+// A rejected tournament duel counts as a draw, a single elimination match is then played again.
+func DismissFlaggedResult(ctx context.Context, cache *services.Cache, db *services.Database, notify *notifications.NotificationService, session_id string) error {
+	query_ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	tx, err := db.Pool.Begin(query_ctx)
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback(query_ctx)
+
+	result, err := lockFlaggedResult(query_ctx, tx, session_id)
+	if err != nil {
+		return err
+	}
+	if err := services.ReviewFlaggedDuel(query_ctx, tx, session_id, services.FlaggedDuelDismissed); err != nil {
+		return err
+	}
+	if err := tx.Commit(query_ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	slog.Info("Flagged duel result dismissed", "SessionID", session_id)
+
+	if result.SessionData.DuelType == basepool.DuelTypeTournament && result.SessionData.TournamentID != "" {
+		return reportTournamentResult(ctx, cache, db, notify, result, pgtype.UUID{})
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package duels
+
+import (
+	"backend/lib/notifications"
+	"backend/lib/services"
+	"context"
+	"fmt"
+
+	basepool "github.com/ciphrpool/base-pool/gen"
+	"github.com/gofiber/fiber/v2"
+)
+
+// playerOutcome returns the outcome of the duel from the point of view of a player
+func playerOutcome(result *DuelResult, side PID) string {
+	switch result.Outcome.Winner {
+	case side:
+		return "victory"
+	case P1, P2:
+		return "defeat"
+	default:
+		return "draw"
+	}
+}
+
+// notifyResult sends the outcome of a processed duel to both players
+func notifyResult(ctx context.Context, notify *notifications.NotificationService, result *DuelResult, p1_elo_delta int, p2_elo_delta int) {
+	if notify == nil {
+		return
+	}
+
+	sides := []struct {
+		side      PID
+		player    services.DuelPlayerSummaryData
+		opponent  services.DuelPlayerSummaryData
+		elo_delta int
+	}{
+		{P1, result.SessionData.P1, result.SessionData.P2, p1_elo_delta},
+		{P2, result.SessionData.P2, result.SessionData.P1, p2_elo_delta},
+	}
+
+	for _, side := range sides {
+		// House bots are not notified
+		if side.side == P2 && result.SessionData.BotID != "" {
+			continue
+		}
+		outcome := playerOutcome(result, side.side)
+		content := fiber.Map{
+			"msg":      fmt.Sprintf("Duel against %s#%s finished : %s", side.opponent.Username, side.opponent.Tag, outcome),
+			"outcome":  outcome,
+			"method":   result.Outcome.Method,
+			"duration": result.Outcome.Duration,
+		}
+		if result.SessionData.DuelType == basepool.DuelTypeRanked {
+			content["elo_delta"] = side.elo_delta
+			content["elo"] = int(side.player.Elo) + side.elo_delta
+		}
+
+		notify.Send(
+			ctx,
+			notifications.TypeMessage,
+			"duel:result",
+			notifications.PriorityHigh,
+			side.player.PID,
+			content,
+			fiber.Map{
+				"duel_session_id": result.SessionID,
+				"duel_type":       result.SessionData.DuelType,
+			},
+		)
+	}
+}
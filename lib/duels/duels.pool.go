@@ -1,16 +1,24 @@
 package duels
 
 import (
+	"backend/lib/notifications"
 	"backend/lib/services"
 	"context"
 	"errors"
 	"log/slog"
 	"sync"
+	"time"
 )
 
 var (
 	ErrPoolNotStarted = errors.New("worker pool not started")
 	ErrPoolClosed     = errors.New("worker pool is closed")
+	ErrPoolFull       = errors.New("worker pool queue is full")
+)
+
+const (
+	MAX_PROCESS_ATTEMPTS = 3                      // Number of times a result is processed before being dead lettered
+	RETRY_BASE_DELAY     = 500 * time.Millisecond // Delay before the first retry, doubled at each attempt
 )
 
 type WorkerPool struct {
@@ -18,6 +26,8 @@ type WorkerPool struct {
 	result_chan chan *DuelResult
 	worker_size int
 	started     bool
+	on_success  func(ctx context.Context, result *DuelResult)
+	on_failure  func(ctx context.Context, result *DuelResult, err error, attempts int)
 	mu          sync.RWMutex
 }
 
@@ -35,7 +45,22 @@ func NewWorkerPool(worker_size int) *WorkerPool {
 	}
 }
 
-// SubmitResult sends a duel result to be processed by the worker pool
+// OnSuccess registers a callback called once a result has been fully processed
+func (p *WorkerPool) OnSuccess(callback func(ctx context.Context, result *DuelResult)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.on_success = callback
+}
+
+// OnFailure registers a callback called when a result could not be processed
+func (p *WorkerPool) OnFailure(callback func(ctx context.Context, result *DuelResult, err error, attempts int)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.on_failure = callback
+}
+
+// SubmitResult sends a duel result to be processed by the worker pool.
+// It never blocks, ErrPoolFull is returned when every worker is busy and the queue is full.
 func (p *WorkerPool) SubmitResult(result *DuelResult) error {
 	if result == nil {
 		return errors.New("cannot submit nil result")
@@ -53,12 +78,39 @@ func (p *WorkerPool) SubmitResult(result *DuelResult) error {
 	case p.result_chan <- result:
 		return nil
 	default:
-		return errors.New("worker pool queue is full")
+		return ErrPoolFull
 	}
 }
 
+// process runs the worker on a result, retrying with an exponential backoff on failure
+func (p *WorkerPool) process(ctx context.Context, w *DuelWorker, result *DuelResult, cache *services.Cache, db *services.Database, notify *notifications.NotificationService) (err error, attempts int) {
+	delay := RETRY_BASE_DELAY
+	for attempts = 1; attempts <= MAX_PROCESS_ATTEMPTS; attempts++ {
+		err = w.Process(ctx, result, cache, db, notify)
+		if err == nil || !IsRetryable(err) {
+			return err, attempts
+		}
+		if attempts == MAX_PROCESS_ATTEMPTS {
+			break
+		}
+
+		slog.Warn("failed to process duel result, retrying",
+			"error", err,
+			"SessionID", result.SessionID,
+			"attempt", attempts,
+			"delay", delay)
+		select {
+		case <-time.After(delay):
+			delay *= 2
+		case <-ctx.Done():
+			return ctx.Err(), attempts
+		}
+	}
+	return err, MAX_PROCESS_ATTEMPTS
+}
+
 // Start initializes and starts all workers in the pool
-func (p *WorkerPool) Start(ctx context.Context, cache *services.Cache, db *services.Database) {
+func (p *WorkerPool) Start(ctx context.Context, cache *services.Cache, db *services.Database, notify *notifications.NotificationService) {
 
 	p.mu.Lock()
 	defer p.mu.Unlock()
@@ -67,6 +119,9 @@ func (p *WorkerPool) Start(ctx context.Context, cache *services.Cache, db *servi
 		return
 	}
 
+	on_success := p.on_success
+	on_failure := p.on_failure
+
 	// Initialize workers
 	slog.Debug("Starting the Duels Worker Pool", "worker_size", p.worker_size)
 	for i := 0; i < p.worker_size; i++ {
@@ -79,8 +134,18 @@ func (p *WorkerPool) Start(ctx context.Context, cache *services.Cache, db *servi
 					if !ok {
 						return
 					}
-					if err := w.Process(ctx, result, cache, db); err != nil {
-						continue // TODO : handle error
+					if err, attempts := p.process(ctx, w, result, cache, db, notify); err != nil {
+						if ctx.Err() != nil {
+							// Shutting down, the result stays pending and will be processed again
+							return
+						}
+						if on_failure != nil {
+							on_failure(ctx, result, err, attempts)
+						}
+						continue
+					}
+					if on_success != nil {
+						on_success(ctx, result)
 					}
 				case <-ctx.Done():
 					return
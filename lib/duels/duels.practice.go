@@ -0,0 +1,96 @@
+package duels
+
+import (
+	"backend/lib/notifications"
+	"backend/lib/rulesets"
+	"backend/lib/schema"
+	"backend/lib/services"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	basepool "github.com/ciphrpool/base-pool/gen"
+)
+
+// CreatePracticeSession opens a duel session between a player and a house bot, the bot always plays P2
+func CreatePracticeSession(cache *services.Cache, player services.DuelPlayerSummaryData, bot services.BotData, ruleset rulesets.Ruleset) (string, error) {
+	return cache.CreateDuelSession(&services.DuelSessionData{
+		DuelType: schema.DuelTypePractice,
+		P1:       player,
+		P2: services.DuelPlayerSummaryData{
+			PID:      bot.UserID,
+			Elo:      bot.Elo,
+			Tag:      bot.Tag,
+			Username: bot.Username,
+		},
+		BotID:   bot.ID,
+		Ruleset: &ruleset,
+	})
+}
+
+// LoadBots rebuilds the cached catalogue of the house bots from the database.
+// A catalogue only found in the cache, as kept before the bots table, is imported first.
+func LoadBots(ctx context.Context, cache *services.Cache, db *services.Database) error {
+	bots, err := services.ListBots(ctx, db.Pool)
+	if err != nil {
+		return err
+	}
+	if len(bots) == 0 {
+		cached, err := cache.ListBots()
+		if err != nil {
+			return err
+		}
+		for _, bot := range cached {
+			err := services.UpsertBot(ctx, db.Pool, bot)
+			if errors.Is(err, services.ErrBotAccountNotFound) {
+				slog.Warn("bot account no longer exists, bot dropped", "bot_id", bot.ID, "user_id", services.UUIDToString(bot.UserID))
+				continue
+			} else if err != nil {
+				return err
+			}
+		}
+		if len(cached) > 0 {
+			if bots, err = services.ListBots(ctx, db.Pool); err != nil {
+				return err
+			}
+		}
+	}
+	return cache.ReplaceBots(bots)
+}
+
+// PracticeDuelResultProcessor stores the result of a practice duel, which affects neither ratings nor statistics
+func PracticeDuelResultProcessor(ctx context.Context, result *DuelResult, cache *services.Cache, db *services.Database, notify *notifications.NotificationService) error {
+	query_ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	queries := basepool.New(db.Pool)
+
+	defer cancel()
+	// Start transaction
+	tx, err := db.Pool.Begin(query_ctx)
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback(query_ctx)
+
+	duplicate, err := isDuplicate(query_ctx, tx, cache, result)
+	if err != nil {
+		return err
+	}
+	if duplicate {
+		return nil
+	}
+
+	qtx := queries.WithTx(tx)
+	if err := insertDuelResult(query_ctx, tx, qtx, cache, result, 0, 0); err != nil {
+		return err
+	}
+
+	// Commit transaction
+	if err := tx.Commit(query_ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	notifyResult(ctx, notify, result, 0, 0)
+	return nil
+}
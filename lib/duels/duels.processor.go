@@ -1,58 +1,57 @@
 package duels
 
 import (
+	"backend/lib/leaderboards"
+	"backend/lib/notifications"
+	"backend/lib/rating"
+	"backend/lib/seasons"
 	"backend/lib/services"
+	"backend/lib/tournaments"
 	"context"
 	"fmt"
+	"log/slog"
 	"time"
 
 	basepool "github.com/ciphrpool/base-pool/gen"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
 )
 
-func calculateEloChanges(result *DuelResult) (p1_delta, p2_delta int) {
-	base_points := 32
+func duelOutcome(result *DuelResult) basepool.DuelOutcome {
 	if result.Outcome.Winner == P1 {
-		return base_points, -base_points
+		return basepool.DuelOutcomeP1WON
+	} else if result.Outcome.Winner == P2 {
+		return basepool.DuelOutcomeP2WON
 	}
-	return -base_points, base_points
+	return basepool.DuelOutcomeDraw
 }
 
-func FriendlyDuelResultProcessor(ctx context.Context, result *DuelResult, cache *services.Cache, db *services.Database) error {
-	query_ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	queries := basepool.New(db.Pool)
-
-	defer cancel()
-	// Start transaction
-	tx, err := db.Pool.Begin(query_ctx)
-	if err != nil {
-		return fmt.Errorf("failed to start transaction: %w", err)
-	}
-	defer tx.Rollback(query_ctx)
-
-	var duel_outcome basepool.DuelOutcome
-	if result.Outcome.Winner == P1 {
-		duel_outcome = basepool.DuelOutcomeP1WON
-	} else if result.Outcome.Winner == P2 {
-		duel_outcome = basepool.DuelOutcomeP2WON
-	} else {
-		duel_outcome = basepool.DuelOutcomeDraw
+func p1Score(result *DuelResult) rating.Score {
+	switch result.Outcome.Winner {
+	case P1:
+		return rating.Win
+	case P2:
+		return rating.Loss
+	default:
+		return rating.Draw
 	}
+}
 
+func insertDuelResult(ctx context.Context, tx pgx.Tx, qtx *basepool.Queries, cache *services.Cache, result *DuelResult, p1_elo_delta int, p2_elo_delta int) error {
 	sessionID, err := services.StringToUUID(result.SessionID)
 	if err != nil {
 		return fmt.Errorf("failed to conevrt session id: %w", err)
 	}
 
-	qtx := queries.WithTx(tx)
-	err = qtx.InsertDuelResult(query_ctx, basepool.InsertDuelResultParams{
+	err = qtx.InsertDuelResult(ctx, basepool.InsertDuelResultParams{
 		SessionID:       sessionID,
 		P1ID:            result.SessionData.P1.PID,
 		P2ID:            result.SessionData.P2.PID,
-		DuelOutcome:     duel_outcome,
+		DuelOutcome:     duelOutcome(result),
 		DuelType:        result.SessionData.DuelType,
 		WinningMethod:   result.Outcome.Method,
-		P1EloDelta:      int32(0),
-		P2EloDelta:      int32(0),
+		P1EloDelta:      int32(p1_elo_delta),
+		P2EloDelta:      int32(p2_elo_delta),
 		Duration:        int32(result.Outcome.Duration),
 		P1EgoCount:      int32(result.P1Summary.EgoCount),
 		P1Energy:        int32(result.P1Summary.Energy),
@@ -71,29 +70,246 @@ func FriendlyDuelResultProcessor(ctx context.Context, result *DuelResult, cache
 		return fmt.Errorf("failed to store the result of the duel: %w", err)
 	}
 
+	// Sessions created before rulesets existed have none to store
+	if result.SessionData.Ruleset != nil {
+		if err := services.InsertDuelResultRuleset(ctx, tx, result.SessionID, *result.SessionData.Ruleset); err != nil {
+			return err
+		}
+	}
+	if result.IsFlagged() {
+		return flagResult(ctx, tx, cache, result)
+	}
+	return nil
+}
+
+// isDuplicate locks the duel session for the transaction and tells if its result has already been processed.
+// The stored result row acts as the processed marker since it is committed with every other side effect.
+func isDuplicate(ctx context.Context, tx pgx.Tx, cache *services.Cache, result *DuelResult) (bool, error) {
+	exists, err := services.LockDuelResult(ctx, tx, result.SessionID)
+	if err != nil {
+		return false, err
+	}
+	if !exists {
+		return false, nil
+	}
+
+	slog.Info("Duel result already processed, skipping", "SessionID", result.SessionID)
+	if err := cache.IncrDuelMetric(services.DUEL_METRIC_DUPLICATES); err != nil {
+		slog.Error("failed to record duplicate duel result", "error", err)
+	}
+	return true, nil
+}
+
+// recordPeaks keeps the highest season rating of both players
+func recordPeaks(ctx context.Context, tx pgx.Tx, season_id string, result *DuelResult, p1_elo_delta int, p2_elo_delta int) error {
+	players := []struct {
+		player services.DuelPlayerSummaryData
+		delta  int
+	}{
+		{result.SessionData.P1, p1_elo_delta},
+		{result.SessionData.P2, p2_elo_delta},
+	}
+	for _, p := range players {
+		if err := services.RecordSeasonPeak(ctx, tx, season_id, p.player.PID, int(p.player.Elo)+p.delta); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// calculateEloChanges computes the elo deltas of both players from the ratings snapshot of the duel session
+func calculateEloChanges(ctx context.Context, tx pgx.Tx, result *DuelResult) (p1_delta, p2_delta int, err error) {
+	p1_games, err := services.CountUserDuelsByType(ctx, tx, result.SessionData.P1.PID, basepool.DuelTypeRanked)
+	if err != nil {
+		return 0, 0, err
+	}
+	p2_games, err := services.CountUserDuelsByType(ctx, tx, result.SessionData.P2.PID, basepool.DuelTypeRanked)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	p1 := rating.Player{
+		Rating:      int(result.SessionData.P1.Elo),
+		GamesPlayed: p1_games,
+	}
+	p2 := rating.Player{
+		Rating:      int(result.SessionData.P2.Elo),
+		GamesPlayed: p2_games,
+	}
+	p1_delta, p2_delta = rating.Deltas(p1, p2, p1Score(result))
+	return p1_delta, p2_delta, nil
+}
+
+func FriendlyDuelResultProcessor(ctx context.Context, result *DuelResult, cache *services.Cache, db *services.Database, notify *notifications.NotificationService) error {
+	query_ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	queries := basepool.New(db.Pool)
+
+	defer cancel()
+	// Start transaction
+	tx, err := db.Pool.Begin(query_ctx)
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback(query_ctx)
+
+	duplicate, err := isDuplicate(query_ctx, tx, cache, result)
+	if err != nil {
+		return err
+	}
+	if duplicate {
+		return nil
+	}
+
+	qtx := queries.WithTx(tx)
+	if err := insertDuelResult(query_ctx, tx, qtx, cache, result, 0, 0); err != nil {
+		return err
+	}
+
 	// Commit transaction
 	if err := tx.Commit(query_ctx); err != nil {
 		return fmt.Errorf("failed to commit transaction: %w", err)
 	}
+
+	notifyResult(ctx, notify, result, 0, 0)
+	recordStats(cache, result)
 	return nil
 }
 
-func RankedDuelResultProcessor(ctx context.Context, result *DuelResult, cache *services.Cache, db *services.Database) error {
-	// err = qtx.UpdatePlayerElo(query_ctx, basepool.UpdatePlayerEloParams{
-	// 	EloDelta: int32(p1_elo_delta),
-	// 	UserID:   result.SessionData.P1.PID,
-	// })
-	// if err != nil {
-	// 	return fmt.Errorf("failed to update p1 elo: %w", err)
-	// }
-
-	// err = qtx.UpdatePlayerElo(query_ctx, basepool.UpdatePlayerEloParams{
-	// 	EloDelta: int32(p2_elo_delta),
-	// 	UserID:   result.SessionData.P2.PID,
-	// })
+func RankedDuelResultProcessor(ctx context.Context, result *DuelResult, cache *services.Cache, db *services.Database, notify *notifications.NotificationService) error {
+	query_ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	queries := basepool.New(db.Pool)
+
+	defer cancel()
+	// Start transaction
+	tx, err := db.Pool.Begin(query_ctx)
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback(query_ctx)
+
+	duplicate, err := isDuplicate(query_ctx, tx, cache, result)
+	if err != nil {
+		return err
+	}
+	if duplicate {
+		return nil
+	}
+
+	// A duel overlapping the end of its season is stored without changing the reset ratings,
+	// and so is a flagged duel which is left to the moderators
+	season, err := seasons.Current(query_ctx, cache, db)
+	if err != nil {
+		return err
+	}
+	in_season := season != nil && season.ID == result.SessionData.SeasonID
+	rated := in_season && !result.IsFlagged()
+
+	var p1_elo_delta, p2_elo_delta int
+	if rated {
+		p1_elo_delta, p2_elo_delta, err = calculateEloChanges(query_ctx, tx, result)
+		if err != nil {
+			return fmt.Errorf("failed to compute elo changes: %w", err)
+		}
+	}
+
+	qtx := queries.WithTx(tx)
+	if err := insertDuelResult(query_ctx, tx, qtx, cache, result, p1_elo_delta, p2_elo_delta); err != nil {
+		return err
+	}
+
+	err = qtx.UpdatePlayerElo(query_ctx, basepool.UpdatePlayerEloParams{
+		EloDelta: int32(p1_elo_delta),
+		UserID:   result.SessionData.P1.PID,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update p1 elo: %w", err)
+	}
+
+	err = qtx.UpdatePlayerElo(query_ctx, basepool.UpdatePlayerEloParams{
+		EloDelta: int32(p2_elo_delta),
+		UserID:   result.SessionData.P2.PID,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update p2 elo: %w", err)
+	}
+	if rated {
+		if err := recordPeaks(query_ctx, tx, season.ID, result, p1_elo_delta, p2_elo_delta); err != nil {
+			return err
+		}
+	}
+
+	// Commit transaction
+	if err := tx.Commit(query_ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	notifyResult(ctx, notify, result, p1_elo_delta, p2_elo_delta)
+	recordStats(cache, result)
+
+	// The leaderboards can be rebuilt from the database, a failure does not fail the result
+	for _, pid := range []pgtype.UUID{result.SessionData.P1.PID, result.SessionData.P2.PID} {
+		if err := leaderboards.Refresh(query_ctx, cache, db, pid); err != nil {
+			slog.Error("failed to update the leaderboards", "error", err, "SessionID", result.SessionID)
+		}
+	}
 	return nil
 }
 
-func TournamentDuelResultProcessor(ctx context.Context, result *DuelResult, cache *services.Cache, db *services.Database) error {
+func TournamentDuelResultProcessor(ctx context.Context, result *DuelResult, cache *services.Cache, db *services.Database, notify *notifications.NotificationService) error {
+	query_ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	queries := basepool.New(db.Pool)
+
+	defer cancel()
+	// Start transaction
+	tx, err := db.Pool.Begin(query_ctx)
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback(query_ctx)
+
+	duplicate, err := isDuplicate(query_ctx, tx, cache, result)
+	if err != nil {
+		return err
+	}
+
+	if !duplicate {
+		qtx := queries.WithTx(tx)
+		if err := insertDuelResult(query_ctx, tx, qtx, cache, result, 0, 0); err != nil {
+			return err
+		}
+
+		// Commit transaction
+		if err := tx.Commit(query_ctx); err != nil {
+			return fmt.Errorf("failed to commit transaction: %w", err)
+		}
+
+		notifyResult(ctx, notify, result, 0, 0)
+		recordStats(cache, result)
+	}
+
+	// Reporting is idempotent, a duplicate may still need to advance the tournament if it failed previously.
+	// A flagged result advances the tournament once reviewed.
+	if result.SessionData.TournamentID == "" || result.IsFlagged() {
+		return nil
+	}
+	return reportTournamentResult(ctx, cache, db, notify, result, tournamentWinner(result))
+}
+
+// tournamentWinner returns the player who won a tournament duel, an invalid one for a draw
+func tournamentWinner(result *DuelResult) pgtype.UUID {
+	switch result.Outcome.Winner {
+	case P1:
+		return result.SessionData.P1.PID
+	case P2:
+		return result.SessionData.P2.PID
+	default:
+		return pgtype.UUID{}
+	}
+}
+
+func reportTournamentResult(ctx context.Context, cache *services.Cache, db *services.Database, notify *notifications.NotificationService, result *DuelResult, winner pgtype.UUID) error {
+	if err := tournaments.ReportResult(ctx, cache, db, notify, result.SessionData.TournamentID, result.SessionID, winner); err != nil {
+		return fmt.Errorf("failed to advance the tournament: %w", err)
+	}
 	return nil
 }
@@ -35,4 +35,11 @@ type DuelResult struct {
 	Outcome     Outcome                  `json:"outcome"`
 	SessionData services.DuelSessionData `json:"session_data"`
 	SessionID   string                   `json:"session_id"`
+	StreamID    string                   `json:"-"` // ID of the stream entry the result was read from
+	Anomalies   []Anomaly                `json:"-"` // Suspicious results are stored without affecting ratings until approved
+}
+
+// IsFlagged tells if the result is awaiting moderation
+func (result *DuelResult) IsFlagged() bool {
+	return len(result.Anomalies) > 0
 }
@@ -0,0 +1,75 @@
+package duels
+
+import (
+	"backend/lib/signing"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	ErrUnsignedResult = errors.New("duel result is not signed")
+	ErrStaleResult    = errors.New("duel result signature is stale")
+	ErrInvalidResult  = errors.New("duel result signature is invalid")
+	ErrNoSigningKey   = errors.New("nexuspool hmac key is not loaded")
+)
+
+// Maximum gap between the signature of a result and its arrival in the stream
+const RESULT_SIGNATURE_MAX_AGE = 2 * time.Minute
+
+// resultSignedContent is the content covered by the signature of a duel result
+func resultSignedContent(session_id string, timestamp string, payload string) string {
+	return strings.Join([]string{session_id, timestamp, payload}, ":")
+}
+
+// SignResult signs a duel result payload the same way nexuspools do
+func SignResult(hmac_key string, session_id string, timestamp time.Time, payload string) (string, string) {
+	ts := strconv.FormatInt(timestamp.UnixMilli(), 10)
+	return ts, signing.SignHMAC([]byte(hmac_key), resultSignedContent(session_id, ts, payload))
+}
+
+// streamTime returns the time at which redis received a stream entry
+func streamTime(id string) (time.Time, error) {
+	ms, _, _ := strings.Cut(id, "-")
+	value, err := strconv.ParseInt(ms, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid stream id %s", id)
+	}
+	return time.UnixMilli(value), nil
+}
+
+// VerifyResult checks the signature of a duel result message.
+// The freshness is checked against the time the entry was added to the stream so that
+// redelivered results are still accepted while old signed results cannot be published again.
+func VerifyResult(hmac_key string, message redis.XMessage, session_id string, payload string) error {
+	if hmac_key == "" {
+		return ErrNoSigningKey
+	}
+	timestamp, _ := message.Values["timestamp"].(string)
+	signature, _ := message.Values["hmac"].(string)
+	if timestamp == "" || signature == "" {
+		return ErrUnsignedResult
+	}
+
+	signed_at, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrUnsignedResult
+	}
+	received_at, err := streamTime(message.ID)
+	if err != nil {
+		return err
+	}
+	if age := received_at.Sub(time.UnixMilli(signed_at)); age > RESULT_SIGNATURE_MAX_AGE || age < -RESULT_SIGNATURE_MAX_AGE {
+		return ErrStaleResult
+	}
+
+	valid, err := signing.CheckHMAC([]byte(hmac_key), resultSignedContent(session_id, timestamp, payload), signature)
+	if err != nil || !valid {
+		return ErrInvalidResult
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package duels
+
+import (
+	"backend/lib/services"
+	"backend/lib/stats"
+	"log/slog"
+)
+
+func statsSample(result *DuelResult, side PID) stats.Sample {
+	summary := result.P1Summary
+	if side == P2 {
+		summary = result.P2Summary
+	}
+
+	outcome := stats.Draw
+	switch result.Outcome.Winner {
+	case side:
+		outcome = stats.Win
+	case P1, P2:
+		outcome = stats.Loss
+	}
+
+	return stats.Sample{
+		DuelType: string(result.SessionData.DuelType),
+		Result:   outcome,
+		Method:   string(result.Outcome.Method),
+		Duration: result.Outcome.Duration,
+		Resources: stats.Resources{
+			EgoCount:      int64(summary.EgoCount),
+			Energy:        int64(summary.Energy),
+			CorruptedData: int64(summary.CorruptedData),
+			EmotionalData: int64(summary.EmotionalData),
+			QuantumData:   int64(summary.QuantumData),
+			LogicalData:   int64(summary.LogicalData),
+		},
+	}
+}
+
+// recordStats updates the statistics of both players once a result is stored, flagged results count once approved
+func recordStats(cache *services.Cache, result *DuelResult) {
+	if result.IsFlagged() {
+		return
+	}
+	if err := stats.Record(cache, result.SessionData.P1.PID, result.SessionID, statsSample(result, P1)); err != nil {
+		slog.Error("failed to record p1 stats", "error", err, "SessionID", result.SessionID)
+	}
+	if err := stats.Record(cache, result.SessionData.P2.PID, result.SessionID, statsSample(result, P2)); err != nil {
+		slog.Error("failed to record p2 stats", "error", err, "SessionID", result.SessionID)
+	}
+}
@@ -2,85 +2,152 @@ package duels
 
 import (
 	"backend/lib/services"
+	"backend/lib/vault"
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"log/slog"
+	"os"
 	"strings"
 	"sync"
+	"time"
 
+	"github.com/google/uuid"
 	"github.com/redis/go-redis/v9"
 )
 
 var (
 	ErrNilWorkerPool = errors.New("worker pool cannot be nil")
-	ErrEmptyChannel  = errors.New("channel name cannot be empty")
+	ErrEmptyStream   = errors.New("stream name cannot be empty")
 )
 
 var (
-	DuelResultChannel = "duel:result:*"
+	DuelResultStream = "duel:results"
+	DuelResultGroup  = "mcs"
+)
+
+const (
+	STREAM_READ_COUNT     = 16
+	STREAM_READ_BLOCK     = 5 * time.Second
+	STREAM_CLAIM_INTERVAL = 30 * time.Second
+	STREAM_CLAIM_MIN_IDLE = 1 * time.Minute
+	STREAM_FULL_BACKOFF   = 1 * time.Second // Pause of the reads while the worker pool is full
 )
 
 type DuelSubscriber struct {
 	worker_pool *WorkerPool
-	channel     string
-	pubsub      *redis.PubSub
+	stream      string
+	group       string
+	consumer    string
+	vault       *vault.VaultManager
+	cancel      context.CancelFunc
+	done        sync.WaitGroup
 	mu          sync.Mutex
 	is_active   bool
 }
 
-// NewDuelSubscriber creates a new subscriber connected to Redis
+// NewDuelSubscriber creates a new consumer of the duel result stream
 func NewDuelSubscriber(worker_pool *WorkerPool) (*DuelSubscriber, error) {
 	if worker_pool == nil {
 		return nil, ErrNilWorkerPool
 	}
 
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "mcs"
+	}
+
 	return &DuelSubscriber{
 		worker_pool: worker_pool,
-		channel:     DuelResultChannel,
+		stream:      DuelResultStream,
+		group:       DuelResultGroup,
+		consumer:    fmt.Sprintf("%s:%s", hostname, uuid.New().String()),
 		is_active:   false,
 	}, nil
 }
 
-// Subscribe starts listening for duel results
-func (s *DuelSubscriber) Subscribe(ctx context.Context, cache *services.Cache) error {
+// Subscribe joins the consumer group and starts reading duel results
+func (s *DuelSubscriber) Subscribe(ctx context.Context, cache *services.Cache, vault *vault.VaultManager) error {
 	s.mu.Lock()
+	defer s.mu.Unlock()
 	if s.is_active {
-		s.mu.Unlock()
 		return errors.New("subscriber is already active")
 	}
+	s.vault = vault
+
+	// Create the consumer group, starting from the beginning so that results published before are not lost
+	slog.Debug("Joining the duel result stream", "stream", s.stream, "group", s.group, "consumer", s.consumer)
+	err := cache.Db.XGroupCreateMkStream(ctx, s.stream, s.group, "0").Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return fmt.Errorf("failed to create the duel result consumer group: %w", err)
+	}
 
-	// Initialize PubSub
-	slog.Debug("Subscribing to the duel result channel", "channel", s.channel)
-	s.pubsub = cache.Db.PSubscribe(ctx, s.channel)
+	sub_ctx, cancel := context.WithCancel(ctx)
+	s.cancel = cancel
 	s.is_active = true
-	s.mu.Unlock()
 
-	// Start message processing in a separate goroutine
+	// Read new results
+	s.done.Add(1)
 	go func() {
-		ch := s.pubsub.Channel()
+		defer s.done.Done()
 		for {
 			select {
-			case msg, ok := <-ch:
-				if !ok {
-					slog.Info("pubsub channel closed")
+			case <-sub_ctx.Done():
+				slog.Info("context cancelled, stopping subscriber")
+				return
+			default:
+			}
+
+			streams, err := cache.Db.XReadGroup(sub_ctx, &redis.XReadGroupArgs{
+				Group:    s.group,
+				Consumer: s.consumer,
+				Streams:  []string{s.stream, ">"},
+				Count:    STREAM_READ_COUNT,
+				Block:    STREAM_READ_BLOCK,
+			}).Result()
+			if err == redis.Nil {
+				continue
+			} else if err != nil {
+				if sub_ctx.Err() != nil {
 					return
 				}
+				slog.Error("failed to read the duel result stream", "error", err)
+				time.Sleep(time.Second)
+				continue
+			}
 
-				slog.Debug("Received a result from the channel")
-				session_id := strings.TrimPrefix(msg.Channel, "duel:result:")
-
-				// Process the message
-				if err := s.processMessage(ctx, msg.Payload, session_id); err != nil {
-					slog.Error("failed to process message",
-						"error", err,
-						"channel", msg.Channel)
-					continue
+			full := false
+			for _, stream := range streams {
+				for _, message := range stream.Messages {
+					if err := s.handleMessage(sub_ctx, cache, message); errors.Is(err, ErrPoolFull) {
+						full = true
+					}
+				}
+			}
+			// Stop reading new results until the workers catch up
+			if full {
+				select {
+				case <-time.After(STREAM_FULL_BACKOFF):
+				case <-sub_ctx.Done():
 				}
+			}
+		}
+	}()
 
-			case <-ctx.Done():
-				slog.Info("context cancelled, stopping subscriber")
-				s.UnSubscribe(context.Background())
+	// Claim the results left pending by crashed or stuck consumers
+	s.done.Add(1)
+	go func() {
+		defer s.done.Done()
+		ticker := time.NewTicker(STREAM_CLAIM_INTERVAL)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := s.claimPending(sub_ctx, cache); err != nil && sub_ctx.Err() == nil {
+					slog.Error("failed to claim pending duel results", "error", err)
+				}
+			case <-sub_ctx.Done():
 				return
 			}
 		}
@@ -89,7 +156,7 @@ func (s *DuelSubscriber) Subscribe(ctx context.Context, cache *services.Cache) e
 	return nil
 }
 
-// UnSubscribe stops listening for messages
+// UnSubscribe stops reading the stream, pending results stay in the group for the next consumer
 func (s *DuelSubscriber) UnSubscribe(ctx context.Context) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -98,29 +165,118 @@ func (s *DuelSubscriber) UnSubscribe(ctx context.Context) error {
 		return nil
 	}
 
-	slog.Debug("Unsubscribing to the duel result channel", "channel", s.channel)
-	if err := s.pubsub.PUnsubscribe(ctx, s.channel); err != nil {
-		return err
+	slog.Debug("Leaving the duel result stream", "stream", s.stream, "consumer", s.consumer)
+	s.cancel()
+	s.done.Wait()
+
+	s.is_active = false
+	return nil
+}
+
+// Ack acknowledges a processed result so that it is never delivered again
+func (s *DuelSubscriber) Ack(ctx context.Context, cache *services.Cache, result *DuelResult) error {
+	if result.StreamID == "" {
+		return nil
 	}
+	pipe := cache.Db.TxPipeline()
+	pipe.XAck(ctx, s.stream, s.group, result.StreamID)
+	pipe.XDel(ctx, s.stream, result.StreamID)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to acknowledge duel result: %w", err)
+	}
+	return nil
+}
 
-	if err := s.pubsub.Close(); err != nil {
-		return err
+func (s *DuelSubscriber) claimPending(ctx context.Context, cache *services.Cache) error {
+	start := "0-0"
+	for {
+		messages, next, err := cache.Db.XAutoClaim(ctx, &redis.XAutoClaimArgs{
+			Stream:   s.stream,
+			Group:    s.group,
+			Consumer: s.consumer,
+			MinIdle:  STREAM_CLAIM_MIN_IDLE,
+			Start:    start,
+			Count:    STREAM_READ_COUNT,
+		}).Result()
+		if err != nil {
+			return err
+		}
+
+		if len(messages) > 0 {
+			slog.Info("Claimed pending duel results", "count", len(messages))
+		}
+		for _, message := range messages {
+			s.handleMessage(ctx, cache, message)
+		}
+
+		if next == "0-0" || next == "" {
+			return nil
+		}
+		start = next
 	}
+}
 
-	s.is_active = false
+// handleMessage verifies a message and submits its result, it only returns the error of the submission
+func (s *DuelSubscriber) handleMessage(ctx context.Context, cache *services.Cache, message redis.XMessage) error {
+	slog.Debug("Received a result from the stream", "id", message.ID)
+
+	result, err := s.processMessage(message)
+	if errors.Is(err, ErrNoSigningKey) {
+		// The result cannot be verified yet, it stays pending and will be claimed again
+		slog.Error("failed to verify message", "error", err, "id", message.ID)
+		return nil
+	}
+	if errors.Is(err, ErrUnsignedResult) || errors.Is(err, ErrStaleResult) || errors.Is(err, ErrInvalidResult) {
+		slog.Warn("security event: rejected duel result",
+			"reason", err,
+			"id", message.ID,
+			"SessionID", message.Values["session_id"])
+		if err := cache.IncrDuelMetric(services.DUEL_METRIC_REJECTED); err != nil {
+			slog.Error("failed to record rejected duel result", "error", err)
+		}
+	}
+	if err != nil {
+		// A malformed result will never be processed, drop it from the stream
+		slog.Error("failed to process message",
+			"error", err,
+			"id", message.ID)
+		if err := s.Ack(ctx, cache, &DuelResult{StreamID: message.ID}); err != nil {
+			slog.Error("failed to drop malformed message", "error", err, "id", message.ID)
+		}
+		return nil
+	}
+
+	// When the pool is full the result is left unacknowledged, it stays pending and is claimed again
+	// once idle for STREAM_CLAIM_MIN_IDLE
+	if err := s.worker_pool.SubmitResult(result); err != nil {
+		slog.Warn("failed to submit duel result",
+			"error", err,
+			"id", message.ID,
+			"SessionID", result.SessionID)
+		return err
+	}
 	return nil
 }
 
-func (s *DuelSubscriber) processMessage(ctx context.Context, message string, session_id string) error {
-	if message == "" {
-		return errors.New("empty message received")
+func (s *DuelSubscriber) processMessage(message redis.XMessage) (*DuelResult, error) {
+	payload, ok := message.Values["payload"].(string)
+	if !ok || payload == "" {
+		return nil, errors.New("empty message received")
+	}
+	session_id, ok := message.Values["session_id"].(string)
+	if !ok || session_id == "" {
+		return nil, errors.New("message without session id received")
+	}
+	if err := VerifyResult(s.vault.NexusHMACKey(), message, session_id, payload); err != nil {
+		return nil, err
 	}
 
 	var result DuelResult
-	if err := json.Unmarshal([]byte(message), &result); err != nil {
-		return err
+	if err := json.Unmarshal([]byte(payload), &result); err != nil {
+		return nil, err
 	}
 	result.SessionID = session_id
+	result.StreamID = message.ID
 
-	return s.worker_pool.SubmitResult(&result)
+	return &result, nil
 }
@@ -1,7 +1,9 @@
 package duels
 
 import (
+	"backend/lib/notifications"
 	"backend/lib/services"
+	"backend/lib/vault"
 	"context"
 	"errors"
 	"log/slog"
@@ -47,7 +49,7 @@ func NewDuelSupervisor(worker_size int) (*DuelSupervisor, error) {
 }
 
 // Start begins the supervision of the subscriber and worker pool
-func (s *DuelSupervisor) Start(ctx context.Context, cache *services.Cache, db *services.Database) error {
+func (s *DuelSupervisor) Start(ctx context.Context, cache *services.Cache, db *services.Database, vault *vault.VaultManager, notify *notifications.NotificationService) error {
 	if cache == nil {
 		return ErrNilCache
 	}
@@ -61,18 +63,35 @@ func (s *DuelSupervisor) Start(ctx context.Context, cache *services.Cache, db *s
 	}
 	s.mu.Unlock()
 
+	// Results are only acknowledged once stored, unprocessed ones stay pending in the stream
+	s.worker_pool.OnSuccess(func(ctx context.Context, result *DuelResult) {
+		if err := s.subscriber.Ack(ctx, cache, result); err != nil {
+			slog.Error("failed to acknowledge duel result", "error", err, "SessionID", result.SessionID)
+		}
+	})
+	// Failed results are dead lettered then acknowledged so that they are not delivered again
+	s.worker_pool.OnFailure(func(ctx context.Context, result *DuelResult, err error, attempts int) {
+		if err := DeadLetter(cache, result, err, attempts); err != nil {
+			slog.Error("failed to dead letter duel result", "error", err, "SessionID", result.SessionID)
+			return
+		}
+		if err := s.subscriber.Ack(ctx, cache, result); err != nil {
+			slog.Error("failed to acknowledge duel result", "error", err, "SessionID", result.SessionID)
+		}
+	})
+
 	// Start components with proper error handling
 	errCh := make(chan error, 2) // Buffer for both components
 
 	// Start worker pool
 	go func() {
-		s.worker_pool.Start(ctx, cache, db)
+		s.worker_pool.Start(ctx, cache, db, notify)
 		errCh <- nil // Worker pool doesn't return error
 	}()
 
 	// Start subscriber
 	go func() {
-		if err := s.subscriber.Subscribe(ctx, cache); err != nil {
+		if err := s.subscriber.Subscribe(ctx, cache, vault); err != nil {
 			errCh <- err
 			return
 		}
@@ -0,0 +1,194 @@
+package duels
+
+import (
+	"backend/lib/notifications"
+	"backend/lib/services"
+	"backend/lib/tournaments"
+	"backend/lib/vault"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	basepool "github.com/ciphrpool/base-pool/gen"
+	"github.com/gofiber/fiber/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	ErrSweeperStarted = errors.New("duel session sweeper is already started")
+)
+
+const (
+	SWEEPER_TICK_INTERVAL = time.Minute
+	SWEEPER_PAGE_SIZE     = 100
+)
+
+// abandonReason tells why a duel session is considered abandoned, empty if it is not
+func abandonReason(session_data services.DuelSessionData, now time.Time) string {
+	if session_data.IsClosed() || !now.After(session_data.AbandonAt()) {
+		return ""
+	}
+	if session_data.Status == services.DuelSessionRunning {
+		return "no result reported"
+	}
+	return "not started"
+}
+
+type SessionSweeper struct {
+	tick_interval time.Duration
+	is_running    bool
+	mu            sync.Mutex
+}
+
+// NewSessionSweeper creates the job aborting abandoned duel sessions
+func NewSessionSweeper() *SessionSweeper {
+	return &SessionSweeper{
+		tick_interval: SWEEPER_TICK_INTERVAL,
+		is_running:    false,
+	}
+}
+
+// Start aborts the abandoned duel sessions, forfeits the disconnected players and expires
+// the unanswered challenges until the context is cancelled
+func (s *SessionSweeper) Start(ctx context.Context, cache *services.Cache, db *services.Database, vault *vault.VaultManager, notify *notifications.NotificationService) error {
+	s.mu.Lock()
+	if s.is_running {
+		s.mu.Unlock()
+		return ErrSweeperStarted
+	}
+	s.is_running = true
+	s.mu.Unlock()
+
+	slog.Debug("Starting the duel session sweeper", "tick_interval", s.tick_interval)
+	go func() {
+		ticker := time.NewTicker(s.tick_interval)
+		defer ticker.Stop()
+		for {
+			if err := forfeitDisconnected(ctx, cache, vault.NexusHMACKey(), time.Now()); err != nil {
+				slog.Error("disconnect forfeit failed", "error", err)
+			}
+			if err := s.sweep(ctx, cache, db, notify); err != nil {
+				slog.Error("duel session sweep failed", "error", err)
+			}
+			if err := expireChallenges(ctx, cache, notify, time.Now()); err != nil {
+				slog.Error("challenge expiry failed", "error", err)
+			}
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				s.mu.Lock()
+				s.is_running = false
+				s.mu.Unlock()
+				slog.Info("context cancelled, stopping duel session sweeper")
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+func (s *SessionSweeper) sweep(ctx context.Context, cache *services.Cache, db *services.Database, notify *notifications.NotificationService) error {
+	now := time.Now()
+
+	// Aborted sessions leave the index, a page may be skipped past them but is swept on the next tick
+	for offset := int64(0); ; offset += SWEEPER_PAGE_SIZE {
+		duel_session_ids, err := cache.ListOpenDuelSessions(now, offset, SWEEPER_PAGE_SIZE)
+		if err != nil {
+			return err
+		}
+		for _, duel_session_id := range duel_session_ids {
+			if err := abortIfAbandoned(ctx, cache, db, notify, duel_session_id, now); err != nil {
+				slog.Error("failed to abort abandoned duel session", "error", err, "SessionID", duel_session_id)
+			}
+		}
+		if len(duel_session_ids) < SWEEPER_PAGE_SIZE {
+			return nil
+		}
+	}
+}
+
+// abortIfAbandoned aborts a duel session if it is still abandoned once locked,
+// the match of a tournament duel is resolved so that its bracket goes on
+func abortIfAbandoned(ctx context.Context, cache *services.Cache, db *services.Database, notify *notifications.NotificationService, duel_session_id string, now time.Time) error {
+	var last_status services.DuelSessionStatus
+	var reason string
+	session_data, err := cache.UpdateDuelSession(duel_session_id, func(session_data services.DuelSessionData) (services.DuelSessionStatus, error) {
+		reason = abandonReason(session_data, now)
+		if reason == "" {
+			return session_data.Status, nil
+		}
+		last_status = session_data.Status
+		return services.DuelSessionAborted, nil
+	})
+	if errors.Is(err, redis.Nil) {
+		// The session expired before it could be swept
+		if err := cache.ForgetOpenDuelSession(duel_session_id); err != nil {
+			return err
+		}
+		return tournaments.ReportAbandoned(ctx, cache, db, notify, "", duel_session_id)
+	} else if err != nil {
+		return err
+	}
+	if reason == "" {
+		return nil
+	}
+
+	slog.Info("Aborted abandoned duel session", "SessionID", duel_session_id, "status", last_status, "reason", reason)
+	if err := cache.IncrDuelMetric(services.DUEL_METRIC_ABORTED); err != nil {
+		slog.Error("failed to record aborted duel", "error", err)
+	}
+	err = cache.AddAbortedDuel(services.AbortedDuelData{
+		SessionID:  duel_session_id,
+		Session:    session_data,
+		LastStatus: last_status,
+		Reason:     reason,
+		AbortedAt:  session_data.UpdatedAt,
+	})
+	if err != nil {
+		return err
+	}
+
+	notifyAborted(ctx, notify, duel_session_id, session_data, reason)
+	if session_data.DuelType == basepool.DuelTypeTournament {
+		return tournaments.ReportAbandoned(ctx, cache, db, notify, session_data.TournamentID, duel_session_id)
+	}
+	return nil
+}
+
+// notifyAborted tells both players that their duel has been abandoned
+func notifyAborted(ctx context.Context, notify *notifications.NotificationService, duel_session_id string, session_data services.DuelSessionData, reason string) {
+	if notify == nil {
+		return
+	}
+	sides := []struct {
+		player   services.DuelPlayerSummaryData
+		opponent services.DuelPlayerSummaryData
+	}{
+		{session_data.P1, session_data.P2},
+		{session_data.P2, session_data.P1},
+	}
+	for _, side := range sides {
+		// House bots are not notified
+		if side.player.PID == session_data.P2.PID && session_data.BotID != "" {
+			continue
+		}
+		notify.Send(
+			ctx,
+			notifications.TypeMessage,
+			"duel:aborted",
+			notifications.PriorityHigh,
+			side.player.PID,
+			fiber.Map{
+				"msg":    fmt.Sprintf("Duel against %s#%s was aborted : %s", side.opponent.Username, side.opponent.Tag, reason),
+				"reason": reason,
+			},
+			fiber.Map{
+				"duel_session_id": duel_session_id,
+				"duel_type":       session_data.DuelType,
+			},
+		)
+	}
+}
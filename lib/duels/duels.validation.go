@@ -0,0 +1,45 @@
+package duels
+
+import (
+	"backend/lib/services"
+	"errors"
+	"fmt"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	ErrUnknownSession  = errors.New("duel session does not exist")
+	ErrSessionClosed   = errors.New("duel session is already closed")
+	ErrSessionMismatch = errors.New("duel result does not match its session")
+)
+
+// ValidateSession checks a result against the duel session created by the server
+// and replaces the session data sent by the nexuspool with the trusted one
+func ValidateSession(cache *services.Cache, result *DuelResult) error {
+	session_data, err := cache.GetDuelSession(result.SessionID)
+	if errors.Is(err, redis.Nil) {
+		return ErrUnknownSession
+	} else if err != nil {
+		return err
+	}
+
+	if session_data.IsClosed() {
+		return ErrSessionClosed
+	}
+
+	claimed := result.SessionData
+	switch {
+	case claimed.P1.PID != session_data.P1.PID || claimed.P2.PID != session_data.P2.PID:
+		return fmt.Errorf("%w: players", ErrSessionMismatch)
+	case claimed.DuelType != session_data.DuelType:
+		return fmt.Errorf("%w: duel type", ErrSessionMismatch)
+	case claimed.P1.Elo != session_data.P1.Elo || claimed.P2.Elo != session_data.P2.Elo:
+		return fmt.Errorf("%w: elo", ErrSessionMismatch)
+	case claimed.TournamentID != session_data.TournamentID || claimed.MatchID != session_data.MatchID:
+		return fmt.Errorf("%w: tournament match", ErrSessionMismatch)
+	}
+
+	result.SessionData = session_data
+	return nil
+}
@@ -1,11 +1,14 @@
 package duels
 
 import (
+	"backend/lib/notifications"
+	"backend/lib/schema"
 	"backend/lib/services"
 	"context"
 	"errors"
 	"log/slog"
 	"sync"
+	"time"
 
 	basepool "github.com/ciphrpool/base-pool/gen"
 )
@@ -14,6 +17,17 @@ var (
 	ErrNilResult = errors.New("cannot process nil result")
 )
 
+// IsRetryable tells if processing the result again may succeed
+func IsRetryable(err error) bool {
+	switch {
+	case errors.Is(err, ErrNilResult), errors.Is(err, ErrNilCache),
+		errors.Is(err, ErrUnknownSession), errors.Is(err, ErrSessionClosed), errors.Is(err, ErrSessionMismatch):
+		return false
+	default:
+		return true
+	}
+}
+
 type DuelWorker struct {
 	work_chan chan *DuelResult
 	pool      *sync.Pool
@@ -39,7 +53,7 @@ func NewDuelWorker() *DuelWorker {
 }
 
 // Process handles a single duel result
-func (w *DuelWorker) Process(ctx context.Context, result *DuelResult, cache *services.Cache, db *services.Database) error {
+func (w *DuelWorker) Process(ctx context.Context, result *DuelResult, cache *services.Cache, db *services.Database, notify *notifications.NotificationService) error {
 	if cache == nil {
 		return ErrNilCache
 	}
@@ -58,24 +72,73 @@ func (w *DuelWorker) Process(ctx context.Context, result *DuelResult, cache *ser
 	*pooled_result = *result
 
 	slog.Debug("Processing Duel Result", "result", pooled_result)
+	if err := ValidateSession(cache, pooled_result); err != nil {
+		// A redelivered result finds its session closed or expired, it is a duplicate when its result is stored
+		if errors.Is(err, ErrSessionClosed) || errors.Is(err, ErrUnknownSession) {
+			stored, exists_err := storedDuplicate(ctx, cache, db, pooled_result)
+			if exists_err != nil {
+				return exists_err
+			}
+			if stored {
+				return nil
+			}
+		}
+		return err
+	}
+	server_forfeit, err := isServerForfeit(cache, pooled_result)
+	if err != nil {
+		return err
+	}
+	if !server_forfeit {
+		pooled_result.Anomalies = DetectAnomalies(pooled_result)
+	}
+
 	switch pooled_result.SessionData.DuelType {
 	case basepool.DuelTypeFriendly:
-		if err := FriendlyDuelResultProcessor(ctx, pooled_result, cache, db); err != nil {
+		if err := FriendlyDuelResultProcessor(ctx, pooled_result, cache, db, notify); err != nil {
 			return err
 		}
 	case basepool.DuelTypeRanked:
-		if err := RankedDuelResultProcessor(ctx, pooled_result, cache, db); err != nil {
+		if err := RankedDuelResultProcessor(ctx, pooled_result, cache, db, notify); err != nil {
 			return err
 		}
 	case basepool.DuelTypeTournament:
-		if err := TournamentDuelResultProcessor(ctx, pooled_result, cache, db); err != nil {
+		if err := TournamentDuelResultProcessor(ctx, pooled_result, cache, db, notify); err != nil {
+			return err
+		}
+	case schema.DuelTypePractice:
+		if err := PracticeDuelResultProcessor(ctx, pooled_result, cache, db, notify); err != nil {
 			return err
 		}
 	}
 
+	// The session is closed so that the result cannot be published again
+	if _, err := cache.TransitionDuelSession(pooled_result.SessionID, services.DuelSessionFinished); errors.Is(err, services.ErrInvalidTransition) {
+		// The session was aborted while its result was processed, the stored result prevails
+		slog.Warn("duel result processed for an aborted session", "SessionID", pooled_result.SessionID)
+	} else if err != nil {
+		return err
+	}
 	return nil
 }
 
+// storedDuplicate tells if the result of the session has already been stored, and counts it as a duplicate
+func storedDuplicate(ctx context.Context, cache *services.Cache, db *services.Database, result *DuelResult) (bool, error) {
+	query_ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	exists, err := services.DuelResultExists(query_ctx, db.Pool, result.SessionID)
+	if err != nil || !exists {
+		return false, err
+	}
+
+	slog.Info("Duel result already processed, skipping", "SessionID", result.SessionID)
+	if err := cache.IncrDuelMetric(services.DUEL_METRIC_DUPLICATES); err != nil {
+		slog.Error("failed to record duplicate duel result", "error", err)
+	}
+	return true, nil
+}
+
 // Start begins the worker's processing loop
 func (w *DuelWorker) Start(ctx context.Context) {
 	w.mu.Lock()
@@ -0,0 +1,51 @@
+package leaderboards
+
+import (
+	"backend/lib/services"
+	"context"
+	"fmt"
+	"log/slog"
+
+	basepool "github.com/ciphrpool/base-pool/gen"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Refresh sets the current rating of a user in the leaderboards, the friends board included, once the user is ranked
+func Refresh(ctx context.Context, cache *services.Cache, db *services.Database, user_id pgtype.UUID) error {
+	queries := basepool.New(db.Pool)
+
+	ranked, err := services.IsRankedPlayer(ctx, db.Pool, user_id)
+	if err != nil {
+		return err
+	}
+	if !ranked {
+		return nil
+	}
+
+	user, err := queries.GetUserByID(ctx, user_id)
+	if err != nil {
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+
+	return cache.UpsertLeaderboardPlayer(services.LeaderboardPlayer{
+		UserID:   services.UUIDToString(user.ID),
+		Username: user.Username,
+		Tag:      user.Tag,
+		Country:  user.Country,
+		Elo:      int(user.Elo),
+	})
+}
+
+// Rebuild replaces the leaderboards with the ratings stored in the database
+func Rebuild(ctx context.Context, cache *services.Cache, db *services.Database) (int, error) {
+	players, err := services.ListLeaderboardPlayers(ctx, db.Pool)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := cache.RebuildLeaderboards(players); err != nil {
+		return 0, err
+	}
+	slog.Info("Leaderboards rebuilt", "players", len(players))
+	return len(players), nil
+}
@@ -0,0 +1,78 @@
+package rating
+
+import "math"
+
+const (
+	// Rating floor, a player can never drop below it
+	MIN_RATING = 100
+
+	// Number of games a player stays in the provisional tier
+	PROVISIONAL_GAMES = 30
+
+	// Rating from which a player enters the master tier
+	MASTER_RATING = 2400
+)
+
+const (
+	K_PROVISIONAL = 40
+	K_STANDARD    = 20
+	K_MASTER      = 10
+)
+
+// Score is the result of a game from the point of view of one player
+type Score float64
+
+const (
+	Loss Score = 0
+	Draw Score = 0.5
+	Win  Score = 1
+)
+
+// Player holds the data required to rate a player
+type Player struct {
+	Rating      int
+	GamesPlayed int
+}
+
+// KFactor returns the K-factor of a player based on its number of games and rating
+func KFactor(player Player) int {
+	if player.GamesPlayed < PROVISIONAL_GAMES {
+		return K_PROVISIONAL
+	}
+	if player.Rating >= MASTER_RATING {
+		return K_MASTER
+	}
+	return K_STANDARD
+}
+
+// ExpectedScore returns the probability for a player to beat its opponent
+func ExpectedScore(rating int, opponent_rating int) float64 {
+	return 1 / (1 + math.Pow(10, float64(opponent_rating-rating)/400))
+}
+
+// Delta computes the rating change of a player after a game against an opponent
+func Delta(player Player, opponent Player, score Score) int {
+	expected := ExpectedScore(player.Rating, opponent.Rating)
+	delta := int(math.Round(float64(KFactor(player)) * (float64(score) - expected)))
+
+	if player.Rating+delta < MIN_RATING {
+		delta = MIN_RATING - player.Rating
+		if delta > 0 {
+			delta = 0
+		}
+	}
+	return delta
+}
+
+// Deltas computes the rating changes of both players of a game, score is given from p1 point of view
+func Deltas(p1 Player, p2 Player, p1_score Score) (p1_delta, p2_delta int) {
+	return Delta(p1, p2, p1_score), Delta(p2, p1, Win-p1_score)
+}
+
+const (
+	// Rating toward which every player is pulled back at the end of a season
+	SEASON_RESET_MEAN = 1000
+
+	// Share of the distance to the mean kept by a player at the end of a season
+	SEASON_RESET_FACTOR = 0.5
+)
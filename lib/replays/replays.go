@@ -0,0 +1,99 @@
+package replays
+
+import (
+	"backend/lib/services"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+var (
+	ErrNotCompressed  = errors.New("replay is not gzip compressed")
+	ErrReplayTooLarge = errors.New("replay is too large")
+	ErrUnknownSession = errors.New("unknown duel session")
+)
+
+const (
+	REPLAY_MAX_SIZE  = 4 * 1024 * 1024
+	REPLAY_RETENTION = 30 * 24 * time.Hour
+)
+
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// Upload stores the compressed replay of a duel session, replacing any previous upload
+func Upload(ctx context.Context, cache *services.Cache, db *services.Database, store Store, session_id string, content []byte) (*services.ReplayData, error) {
+	if len(content) > REPLAY_MAX_SIZE {
+		return nil, ErrReplayTooLarge
+	}
+	if !bytes.HasPrefix(content, gzipMagic) {
+		return nil, ErrNotCompressed
+	}
+
+	// The replay may arrive before the result is processed, the session is then still cached
+	if _, err := cache.GetDuelSession(session_id); err != nil {
+		exists, err := services.DuelResultExists(ctx, db.Pool, session_id)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			return nil, ErrUnknownSession
+		}
+	}
+
+	size, err := store.Put(ctx, session_id, bytes.NewReader(content))
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	replay := services.ReplayData{
+		SessionID:  session_id,
+		Size:       size,
+		UploadedAt: now,
+		ExpiresAt:  now.Add(REPLAY_RETENTION),
+	}
+	if err := services.UpsertReplay(ctx, db.Pool, replay); err != nil {
+		return nil, err
+	}
+	return &replay, nil
+}
+
+// Open returns the replay of a duel session along with its content
+func Open(ctx context.Context, db *services.Database, store Store, session_id string) (*services.ReplayData, io.ReadCloser, error) {
+	replay, err := services.GetReplay(ctx, db.Pool, session_id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if replay == nil || time.Now().After(replay.ExpiresAt) {
+		return nil, nil, ErrReplayNotFound
+	}
+
+	content, err := store.Get(ctx, session_id)
+	if err != nil {
+		return nil, nil, err
+	}
+	return replay, content, nil
+}
+
+// Purge deletes the replays past their retention
+func Purge(ctx context.Context, db *services.Database, store Store) (int, error) {
+	session_ids, err := services.ListExpiredReplays(ctx, db.Pool, time.Now(), 100)
+	if err != nil {
+		return 0, err
+	}
+
+	purged := 0
+	for _, session_id := range session_ids {
+		if err := store.Delete(ctx, session_id); err != nil {
+			return purged, fmt.Errorf("failed to purge replay %s: %w", session_id, err)
+		}
+		if err := services.DeleteReplay(ctx, db.Pool, session_id); err != nil {
+			return purged, err
+		}
+		purged++
+	}
+	return purged, nil
+}
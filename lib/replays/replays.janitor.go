@@ -0,0 +1,65 @@
+package replays
+
+import (
+	"backend/lib/services"
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+var (
+	ErrJanitorStarted = errors.New("replay janitor is already started")
+)
+
+const JANITOR_TICK_INTERVAL = time.Hour
+
+type Janitor struct {
+	tick_interval time.Duration
+	is_running    bool
+	mu            sync.Mutex
+}
+
+// NewJanitor creates the job enforcing the retention of the replays
+func NewJanitor() *Janitor {
+	return &Janitor{
+		tick_interval: JANITOR_TICK_INTERVAL,
+		is_running:    false,
+	}
+}
+
+// Start purges the expired replays until the context is cancelled
+func (j *Janitor) Start(ctx context.Context, db *services.Database, store Store) error {
+	j.mu.Lock()
+	if j.is_running {
+		j.mu.Unlock()
+		return ErrJanitorStarted
+	}
+	j.is_running = true
+	j.mu.Unlock()
+
+	slog.Debug("Starting the replay janitor", "tick_interval", j.tick_interval)
+	go func() {
+		ticker := time.NewTicker(j.tick_interval)
+		defer ticker.Stop()
+		for {
+			purged, err := Purge(ctx, db, store)
+			if err != nil {
+				slog.Error("replay purge failed", "error", err)
+			} else if purged > 0 {
+				slog.Info("Purged expired replays", "count", purged)
+			}
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				j.mu.Lock()
+				j.is_running = false
+				j.mu.Unlock()
+				slog.Info("context cancelled, stopping replay janitor")
+				return
+			}
+		}
+	}()
+	return nil
+}
@@ -0,0 +1,36 @@
+package replays
+
+import (
+	"backend/lib/signing"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+)
+
+var (
+	ErrInvalidSignature = errors.New("replay signature is invalid")
+	ErrNoSigningKey     = errors.New("nexuspool hmac key is not loaded")
+)
+
+// replaySignedContent binds the digest of a replay to its duel session
+func replaySignedContent(session_id string, content []byte) string {
+	digest := sha256.Sum256(content)
+	return session_id + ":" + hex.EncodeToString(digest[:])
+}
+
+// SignReplay signs a replay the same way nexuspools do
+func SignReplay(hmac_key string, session_id string, content []byte) string {
+	return signing.SignHMAC([]byte(hmac_key), replaySignedContent(session_id, content))
+}
+
+// VerifyReplay checks that a replay was uploaded by a nexuspool for the given session
+func VerifyReplay(hmac_key string, session_id string, content []byte, signature string) error {
+	if hmac_key == "" {
+		return ErrNoSigningKey
+	}
+	valid, err := signing.CheckHMAC([]byte(hmac_key), replaySignedContent(session_id, content), signature)
+	if err != nil || !valid {
+		return ErrInvalidSignature
+	}
+	return nil
+}
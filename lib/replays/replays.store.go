@@ -0,0 +1,103 @@
+package replays
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+)
+
+var (
+	ErrReplayNotFound = errors.New("replay not found")
+	ErrInvalidKey     = errors.New("invalid replay key")
+)
+
+// Store is the blob backend holding the replay files
+type Store interface {
+	Put(ctx context.Context, key string, content io.Reader) (int64, error)
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+}
+
+const DEFAULT_REPLAYS_DIR = "./data/replays"
+
+var validKey = regexp.MustCompile(`^[a-zA-Z0-9-]+$`)
+
+// LocalStore keeps the replays on the local filesystem
+type LocalStore struct {
+	root string
+}
+
+// NewLocalStore creates a store in the REPLAYS_DIR directory
+func NewLocalStore() *LocalStore {
+	root := os.Getenv("REPLAYS_DIR")
+	if root == "" {
+		root = DEFAULT_REPLAYS_DIR
+	}
+	return &LocalStore{root: root}
+}
+
+func (s *LocalStore) path(key string) (string, error) {
+	if !validKey.MatchString(key) {
+		return "", ErrInvalidKey
+	}
+	return filepath.Join(s.root, key+".replay"), nil
+}
+
+func (s *LocalStore) Put(ctx context.Context, key string, content io.Reader) (int64, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return 0, err
+	}
+	if err := os.MkdirAll(s.root, 0o750); err != nil {
+		return 0, fmt.Errorf("failed to create replays directory: %w", err)
+	}
+
+	// Write to a temporary file first so that a replay is never served partially written
+	file, err := os.CreateTemp(s.root, key+".*.tmp")
+	if err != nil {
+		return 0, fmt.Errorf("failed to create replay file: %w", err)
+	}
+	defer os.Remove(file.Name())
+
+	size, err := io.Copy(file, content)
+	if err != nil {
+		file.Close()
+		return 0, fmt.Errorf("failed to write replay file: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return 0, fmt.Errorf("failed to write replay file: %w", err)
+	}
+	if err := os.Rename(file.Name(), path); err != nil {
+		return 0, fmt.Errorf("failed to store replay file: %w", err)
+	}
+	return size, nil
+}
+
+func (s *LocalStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrReplayNotFound
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to open replay file: %w", err)
+	}
+	return file, nil
+}
+
+func (s *LocalStore) Delete(ctx context.Context, key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to delete replay file: %w", err)
+	}
+	return nil
+}
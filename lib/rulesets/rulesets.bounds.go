@@ -0,0 +1,32 @@
+package rulesets
+
+// DURATION_TOLERANCE is the time in seconds a nexuspool may take past the time limit to end a duel
+const DURATION_TOLERANCE = 30
+
+// Bounds are the values a duel played with a ruleset can legitimately end with
+type Bounds struct {
+	MaxDuration  int          // In seconds
+	MaxResources ResourceCaps // Uncapped resources are bounded by MAX_RESOURCE_CAP
+}
+
+// Bounds returns the bounds of the results of the duels played with the ruleset
+func (ruleset Ruleset) Bounds() Bounds {
+	bound := func(value int) int {
+		if value == 0 {
+			return MAX_RESOURCE_CAP
+		}
+		return value
+	}
+	caps := ruleset.ResourceCaps
+	return Bounds{
+		MaxDuration: ruleset.TimeLimit + DURATION_TOLERANCE,
+		MaxResources: ResourceCaps{
+			EgoCount:      bound(caps.EgoCount),
+			Energy:        bound(caps.Energy),
+			CorruptedData: bound(caps.CorruptedData),
+			EmotionalData: bound(caps.EmotionalData),
+			QuantumData:   bound(caps.QuantumData),
+			LogicalData:   bound(caps.LogicalData),
+		},
+	}
+}
@@ -0,0 +1,149 @@
+package rulesets
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrUnsupportedVersion = errors.New("unsupported ruleset version")
+	ErrInvalidRuleset     = errors.New("invalid ruleset")
+)
+
+// RULESET_VERSION is bumped whenever the meaning of a field changes so that
+// nexuspools and stored results can tell which rules were played
+const RULESET_VERSION = 1
+
+const (
+	MIN_TIME_LIMIT      = 60
+	MAX_TIME_LIMIT      = 3600
+	MAX_STARTING_ENERGY = 10000
+	MAX_RESOURCE_CAP    = 100000
+	MAX_MAP_SEED_LENGTH = 64
+)
+
+type VictoryCondition string
+
+const (
+	// The player who destroys every ego of its opponent wins
+	VictoryElimination VictoryCondition = "elimination"
+	// The player holding the most resources when the time limit is reached wins
+	VictoryResources VictoryCondition = "resources"
+	// The player still standing when the time limit is reached wins, a draw otherwise
+	VictoryTimeout VictoryCondition = "timeout"
+)
+
+func (condition VictoryCondition) IsValid() bool {
+	switch condition {
+	case VictoryElimination, VictoryResources, VictoryTimeout:
+		return true
+	default:
+		return false
+	}
+}
+
+// ResourceCaps bounds the resources a player can hold, zero means no cap
+type ResourceCaps struct {
+	EgoCount      int `json:"ego_count,omitempty"`
+	Energy        int `json:"energy,omitempty"`
+	CorruptedData int `json:"corrupted_data,omitempty"`
+	EmotionalData int `json:"emotional_data,omitempty"`
+	QuantumData   int `json:"quantum_data,omitempty"`
+	LogicalData   int `json:"logical_data,omitempty"`
+}
+
+// Values returns the caps by resource name
+func (caps ResourceCaps) Values() map[string]int {
+	return map[string]int{
+		"ego_count":      caps.EgoCount,
+		"energy":         caps.Energy,
+		"corrupted_data": caps.CorruptedData,
+		"emotional_data": caps.EmotionalData,
+		"quantum_data":   caps.QuantumData,
+		"logical_data":   caps.LogicalData,
+	}
+}
+
+// Ruleset is the set of rules a nexuspool applies to a duel
+type Ruleset struct {
+	Version           int                `json:"version"`
+	Preset            string             `json:"preset"`
+	Customized        bool               `json:"customized,omitempty"`
+	TimeLimit         int                `json:"time_limit"` // In seconds
+	StartingEnergy    int                `json:"starting_energy,omitempty"`
+	ResourceCaps      ResourceCaps       `json:"resource_caps"`
+	VictoryConditions []VictoryCondition `json:"victory_conditions"`
+	MapSeed           string             `json:"map_seed,omitempty"`
+}
+
+func (ruleset Ruleset) Validate() error {
+	if ruleset.Version != RULESET_VERSION {
+		return fmt.Errorf("%w %d", ErrUnsupportedVersion, ruleset.Version)
+	}
+	if ruleset.Preset == "" {
+		return fmt.Errorf("%w: a preset is required", ErrInvalidRuleset)
+	}
+	if ruleset.TimeLimit < MIN_TIME_LIMIT || ruleset.TimeLimit > MAX_TIME_LIMIT {
+		return fmt.Errorf("%w: time limit must be between %d and %d seconds", ErrInvalidRuleset, MIN_TIME_LIMIT, MAX_TIME_LIMIT)
+	}
+	if ruleset.StartingEnergy < 0 || ruleset.StartingEnergy > MAX_STARTING_ENERGY {
+		return fmt.Errorf("%w: starting energy must be between 0 and %d", ErrInvalidRuleset, MAX_STARTING_ENERGY)
+	}
+	for resource, value := range ruleset.ResourceCaps.Values() {
+		if value < 0 || value > MAX_RESOURCE_CAP {
+			return fmt.Errorf("%w: %s cap must be between 0 and %d", ErrInvalidRuleset, resource, MAX_RESOURCE_CAP)
+		}
+	}
+	if len(ruleset.VictoryConditions) == 0 {
+		return fmt.Errorf("%w: at least one victory condition is required", ErrInvalidRuleset)
+	}
+	seen := make(map[VictoryCondition]bool, len(ruleset.VictoryConditions))
+	for _, condition := range ruleset.VictoryConditions {
+		if !condition.IsValid() || seen[condition] {
+			return fmt.Errorf("%w: invalid victory condition %q", ErrInvalidRuleset, condition)
+		}
+		seen[condition] = true
+	}
+	if len(ruleset.MapSeed) > MAX_MAP_SEED_LENGTH {
+		return fmt.Errorf("%w: map seed must be at most %d characters", ErrInvalidRuleset, MAX_MAP_SEED_LENGTH)
+	}
+	return nil
+}
+
+// Overrides are the changes a host can make to a preset, nil fields keep the preset values
+type Overrides struct {
+	TimeLimit         *int               `json:"time_limit,omitempty"`
+	StartingEnergy    *int               `json:"starting_energy,omitempty"`
+	ResourceCaps      *ResourceCaps      `json:"resource_caps,omitempty"`
+	VictoryConditions []VictoryCondition `json:"victory_conditions,omitempty"`
+	MapSeed           *string            `json:"map_seed,omitempty"`
+}
+
+// Customize applies overrides to a ruleset and validates the result
+func Customize(ruleset Ruleset, overrides Overrides) (Ruleset, error) {
+	customized := ruleset
+	customized.VictoryConditions = append([]VictoryCondition(nil), ruleset.VictoryConditions...)
+
+	if overrides.TimeLimit != nil {
+		customized.TimeLimit = *overrides.TimeLimit
+	}
+	if overrides.StartingEnergy != nil {
+		customized.StartingEnergy = *overrides.StartingEnergy
+	}
+	if overrides.ResourceCaps != nil {
+		customized.ResourceCaps = *overrides.ResourceCaps
+	}
+	if len(overrides.VictoryConditions) > 0 {
+		customized.VictoryConditions = append([]VictoryCondition(nil), overrides.VictoryConditions...)
+	}
+	if overrides.MapSeed != nil {
+		customized.MapSeed = *overrides.MapSeed
+	}
+	customized.Customized = customized.Customized || overrides.TimeLimit != nil || overrides.StartingEnergy != nil ||
+		overrides.ResourceCaps != nil || len(overrides.VictoryConditions) > 0 || overrides.MapSeed != nil
+
+	if err := customized.Validate(); err != nil {
+		return ruleset, err
+	}
+	return customized, nil
+}
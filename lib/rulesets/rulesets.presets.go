@@ -0,0 +1,84 @@
+package rulesets
+
+import (
+	"backend/lib/schema"
+	"errors"
+	"sort"
+
+	basepool "github.com/ciphrpool/base-pool/gen"
+)
+
+var (
+	ErrUnknownPreset = errors.New("unknown ruleset preset")
+)
+
+const DEFAULT_PRESET = "standard"
+
+var (
+	standard = Ruleset{
+		Version:           RULESET_VERSION,
+		Preset:            DEFAULT_PRESET,
+		TimeLimit:         600,
+		VictoryConditions: []VictoryCondition{VictoryElimination, VictoryTimeout},
+	}
+	blitz = Ruleset{
+		Version:           RULESET_VERSION,
+		Preset:            "blitz",
+		TimeLimit:         180,
+		VictoryConditions: []VictoryCondition{VictoryElimination, VictoryResources},
+	}
+	marathon = Ruleset{
+		Version:           RULESET_VERSION,
+		Preset:            "marathon",
+		TimeLimit:         1800,
+		VictoryConditions: []VictoryCondition{VictoryElimination, VictoryTimeout},
+	}
+)
+
+// presets lists the rulesets allowed for each duel type.
+// Ranked and tournament duels are played with the standard rules only so that ratings stay comparable.
+var presets = map[basepool.DuelType][]Ruleset{
+	basepool.DuelTypeFriendly:   {standard, blitz, marathon},
+	basepool.DuelTypeRanked:     {standard},
+	basepool.DuelTypeTournament: {standard},
+	schema.DuelTypePractice:     {standard, blitz},
+}
+
+// Presets returns the presets of a duel type sorted by name
+func Presets(duel_type basepool.DuelType) []Ruleset {
+	available := make([]Ruleset, 0, len(presets[duel_type]))
+	for _, preset := range presets[duel_type] {
+		available = append(available, copyRuleset(preset))
+	}
+	sort.Slice(available, func(i, j int) bool {
+		return available[i].Preset < available[j].Preset
+	})
+	return available
+}
+
+// Preset returns a preset of a duel type, the default one when no name is given
+func Preset(duel_type basepool.DuelType, name string) (Ruleset, error) {
+	if name == "" {
+		name = DEFAULT_PRESET
+	}
+	for _, preset := range presets[duel_type] {
+		if preset.Preset == name {
+			return copyRuleset(preset), nil
+		}
+	}
+	return Ruleset{}, ErrUnknownPreset
+}
+
+// Default returns the ruleset of the duels created without one
+func Default(duel_type basepool.DuelType) Ruleset {
+	ruleset, err := Preset(duel_type, DEFAULT_PRESET)
+	if err != nil {
+		return copyRuleset(standard)
+	}
+	return ruleset
+}
+
+func copyRuleset(ruleset Ruleset) Ruleset {
+	ruleset.VictoryConditions = append([]VictoryCondition(nil), ruleset.VictoryConditions...)
+	return ruleset
+}
@@ -0,0 +1,12 @@
+package schema
+
+import basepool "github.com/ciphrpool/base-pool/gen"
+
+// The enum values below are added to the base-pool schema by the migrations of this service
+// (lib/services/migrations) and are not part of the generated package yet.
+
+// WinningMethodForfeit is the winning method of the duels conceded or abandoned by a player
+const WinningMethodForfeit basepool.WinningMethod = "forfeit"
+
+// DuelTypePractice is the duel type of the duels played against a house bot
+const DuelTypePractice basepool.DuelType = "practice"
@@ -0,0 +1,199 @@
+package seasons
+
+import (
+	"backend/lib/leaderboards"
+	"backend/lib/rating"
+	"backend/lib/services"
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+)
+
+var (
+	ErrSeasonActive     = errors.New("a season is already running")
+	ErrNoActiveSeason   = errors.New("no season is running")
+	ErrInvalidSeasonEnd = errors.New("season must end in the future")
+)
+
+const SEASON_LENGTH = 90 * 24 * time.Hour
+
+// Rewards granted from the final rating of a player, from the highest tier
+var DefaultRewards = []services.SeasonReward{
+	{Name: "master", MinElo: 2400},
+	{Name: "diamond", MinElo: 2000},
+	{Name: "gold", MinElo: 1600},
+	{Name: "silver", MinElo: 1200},
+	{Name: "bronze", MinElo: 0},
+}
+
+// IsOpen tells if ranked duels can be played in a season
+func IsOpen(season *services.SeasonData, now time.Time) bool {
+	return season != nil && season.Status == services.SeasonStatusActive && now.Before(season.EndAt)
+}
+
+// Reward returns the reward of a final rating, rewards are ordered from the highest tier
+func Reward(rewards []services.SeasonReward, elo int64) string {
+	for _, reward := range rewards {
+		if elo >= int64(reward.MinElo) {
+			return reward.Name
+		}
+	}
+	return ""
+}
+
+// Current returns the season in which ranked duels can be played, nil if there is none
+func Current(ctx context.Context, cache *services.Cache, db *services.Database) (*services.SeasonData, error) {
+	season, err := cache.GetCachedCurrentSeason()
+	if err != nil {
+		slog.Warn("failed to read the cached season", "error", err)
+	}
+	if season == nil {
+		season, err = services.GetCurrentSeason(ctx, db.Pool)
+		if err != nil {
+			return nil, err
+		}
+		if season != nil {
+			if err := cache.SetCachedCurrentSeason(season); err != nil {
+				slog.Warn("failed to cache the current season", "error", err)
+			}
+		}
+	}
+	if !IsOpen(season, time.Now()) {
+		return nil, nil
+	}
+	return season, nil
+}
+
+// save records the progress of a season then drops the cached copy, ranked duels see the change right away
+func save(ctx context.Context, cache *services.Cache, db *services.Database, season *services.SeasonData) error {
+	if err := services.UpdateSeason(ctx, db.Pool, season); err != nil {
+		return err
+	}
+	return cache.ClearCachedCurrentSeason()
+}
+
+// Start opens a new season, the previous one must be over
+func Start(ctx context.Context, cache *services.Cache, db *services.Database, name string, end_at time.Time, rewards []services.SeasonReward) (*services.SeasonData, error) {
+	current, err := services.GetCurrentSeason(ctx, db.Pool)
+	if err != nil {
+		return nil, err
+	}
+	if current != nil {
+		return nil, ErrSeasonActive
+	}
+	now := time.Now()
+	if !end_at.After(now) {
+		return nil, ErrInvalidSeasonEnd
+	}
+	if len(rewards) == 0 {
+		rewards = DefaultRewards
+	}
+
+	season := &services.SeasonData{
+		Name:    name,
+		Status:  services.SeasonStatusActive,
+		StartAt: now,
+		EndAt:   end_at,
+		Rewards: rewards,
+	}
+	if err := services.CreateSeason(ctx, db.Pool, season); err != nil {
+		return nil, err
+	}
+	if err := cache.ClearCachedCurrentSeason(); err != nil {
+		return nil, err
+	}
+	slog.Info("Season started", "season_id", season.ID, "name", season.Name, "end_at", season.EndAt)
+	return season, nil
+}
+
+// End closes the current season : the standings are archived then every rating is softly reset.
+// Each step is recorded on the season so that an interrupted end can be resumed.
+func End(ctx context.Context, cache *services.Cache, db *services.Database) error {
+	season, err := services.GetCurrentSeason(ctx, db.Pool)
+	if err != nil {
+		return err
+	}
+	if season == nil {
+		return ErrNoActiveSeason
+	}
+
+	// Ranked duels are closed from now on
+	season.Status = services.SeasonStatusClosing
+	if err := save(ctx, cache, db, season); err != nil {
+		return err
+	}
+
+	if !season.Archived {
+		if err := archive(ctx, cache, db, season); err != nil {
+			return err
+		}
+		season.Archived = true
+		if err := save(ctx, cache, db, season); err != nil {
+			return err
+		}
+	}
+
+	if !season.RatingsReset {
+		count, err := services.SoftResetRatings(ctx, db.Pool, rating.SEASON_RESET_MEAN, rating.SEASON_RESET_FACTOR, rating.MIN_RATING)
+		if err != nil {
+			return err
+		}
+		slog.Info("Ratings reset for the next season", "season_id", season.ID, "players", count)
+		season.RatingsReset = true
+		if err := save(ctx, cache, db, season); err != nil {
+			return err
+		}
+	}
+
+	if _, err := leaderboards.Rebuild(ctx, cache, db); err != nil {
+		return err
+	}
+
+	season.Status = services.SeasonStatusFinished
+	season.EndedAt = time.Now()
+	if err := save(ctx, cache, db, season); err != nil {
+		return err
+	}
+	slog.Info("Season ended", "season_id", season.ID, "name", season.Name)
+	return nil
+}
+
+// archive snapshots the global leaderboard with the peak rating and reward of each player
+func archive(ctx context.Context, cache *services.Cache, db *services.Database, season *services.SeasonData) error {
+	peaks, err := services.GetSeasonPeaks(ctx, db.Pool, season.ID)
+	if err != nil {
+		return err
+	}
+
+	var standings []services.SeasonStanding
+	var cursor int64
+	for {
+		entries, next_cursor, err := cache.GetLeaderboard(services.LEADERBOARD_GLOBAL_KEY, cursor, 500)
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			peak := peaks[entry.UserID]
+			if peak < entry.Elo {
+				peak = entry.Elo
+			}
+			standings = append(standings, services.SeasonStanding{
+				Rank:     entry.Rank,
+				UserID:   entry.UserID,
+				Username: entry.Username,
+				Tag:      entry.Tag,
+				Country:  entry.Country,
+				Elo:      entry.Elo,
+				PeakElo:  peak,
+				Reward:   Reward(season.Rewards, entry.Elo),
+			})
+		}
+		if next_cursor == 0 {
+			break
+		}
+		cursor = next_cursor
+	}
+
+	return db.SetSeasonStandings(ctx, season.ID, standings)
+}
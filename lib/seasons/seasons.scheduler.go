@@ -0,0 +1,90 @@
+package seasons
+
+import (
+	"backend/lib/services"
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+var (
+	ErrSchedulerStarted = errors.New("season scheduler is already started")
+)
+
+const SCHEDULER_TICK_INTERVAL = time.Minute
+
+type Scheduler struct {
+	tick_interval time.Duration
+	is_running    bool
+	mu            sync.Mutex
+}
+
+// NewScheduler creates the job enforcing season boundaries
+func NewScheduler() *Scheduler {
+	return &Scheduler{
+		tick_interval: SCHEDULER_TICK_INTERVAL,
+		is_running:    false,
+	}
+}
+
+// Start ends the seasons once over and rolls into the next ones until the context is cancelled
+func (s *Scheduler) Start(ctx context.Context, cache *services.Cache, db *services.Database) error {
+	s.mu.Lock()
+	if s.is_running {
+		s.mu.Unlock()
+		return ErrSchedulerStarted
+	}
+	s.is_running = true
+	s.mu.Unlock()
+
+	slog.Debug("Starting the season scheduler", "tick_interval", s.tick_interval)
+	go func() {
+		ticker := time.NewTicker(s.tick_interval)
+		defer ticker.Stop()
+		for {
+			if err := s.tick(ctx, cache, db); err != nil {
+				slog.Error("season scheduling failed", "error", err)
+			}
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				s.mu.Lock()
+				s.is_running = false
+				s.mu.Unlock()
+				slog.Info("context cancelled, stopping season scheduler")
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+func (s *Scheduler) tick(ctx context.Context, cache *services.Cache, db *services.Database) error {
+	season, err := services.GetCurrentSeason(ctx, db.Pool)
+	if err != nil {
+		return err
+	}
+
+	// Without a running season an admin opens the next one, with its own name, end and rewards,
+	// but a fresh deploy gets a first season so that ranked duels are playable right away
+	if season == nil {
+		count, err := services.CountSeasons(ctx, db.Pool)
+		if err != nil || count > 0 {
+			return err
+		}
+		_, err = Start(ctx, cache, db, "", time.Now().Add(SEASON_LENGTH), nil)
+		return err
+	}
+	if season.Status == services.SeasonStatusActive && time.Now().Before(season.EndAt) {
+		return nil
+	}
+
+	// The season is over or its end was interrupted, the next one follows right away
+	if err := End(ctx, cache, db); err != nil {
+		return err
+	}
+	_, err = Start(ctx, cache, db, "", time.Now().Add(SEASON_LENGTH), nil)
+	return err
+}
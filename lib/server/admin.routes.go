@@ -0,0 +1,190 @@
+package server
+
+import (
+	m "backend/lib/maintenance"
+	"backend/lib/server/middleware"
+	"backend/lib/server/routes"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func (server *MaintenanceServer) RegisterAdminRoutes() {
+	admin_group := server.App.Group("/admin")
+	admin_group.Use(
+		middleware.OnMSS(m.MODE_OPERATIONAL, m.STATE_RUNNING, m.SUBSTATE_SAFE),
+		middleware.WithKey("MCS_ADM_KEY", func() (string, error) {
+			return server.VaultManager.GetApiKey("MCS_ADM_KEY")
+		}),
+	)
+
+	server.registerAdminDuelRoutes(admin_group)
+	server.registerAdminLeaderboardRoutes(admin_group)
+	server.registerAdminSeasonRoutes(admin_group)
+	server.registerAdminBotRoutes(admin_group)
+}
+
+func (server *MaintenanceServer) registerAdminBotRoutes(routes_group fiber.Router) {
+	bots_group := routes_group.Group("/bots")
+
+	bots_group.Post("/set",
+		func(c *fiber.Ctx) error {
+			var data routes.SetBotData
+			if err := c.BodyParser(&data); err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error": "invalid request body",
+				})
+			}
+			return routes.SetBotHandler(data, c, &server.Cache, &server.Db)
+		},
+	)
+
+	bots_group.Post("/remove",
+		func(c *fiber.Ctx) error {
+			var data routes.RemoveBotData
+			if err := c.BodyParser(&data); err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error": "invalid request body",
+				})
+			}
+			return routes.RemoveBotHandler(data, c, &server.Cache, &server.Db)
+		},
+	)
+}
+
+func (server *MaintenanceServer) registerAdminSeasonRoutes(routes_group fiber.Router) {
+	seasons_group := routes_group.Group("/seasons")
+
+	seasons_group.Post("/start",
+		func(c *fiber.Ctx) error {
+			var data routes.StartSeasonData
+			if err := c.BodyParser(&data); err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error": "invalid request body",
+				})
+			}
+			return routes.StartSeasonHandler(data, c, &server.Cache, &server.Db)
+		},
+	)
+
+	seasons_group.Post("/end",
+		func(c *fiber.Ctx) error {
+			return routes.EndSeasonHandler(c, &server.Cache, &server.Db)
+		},
+	)
+}
+
+func (server *MaintenanceServer) registerAdminLeaderboardRoutes(routes_group fiber.Router) {
+	leaderboards_group := routes_group.Group("/leaderboards")
+
+	leaderboards_group.Post("/rebuild",
+		func(c *fiber.Ctx) error {
+			return routes.RebuildLeaderboardsHandler(c, &server.Cache, &server.Db)
+		},
+	)
+}
+
+func (server *MaintenanceServer) registerAdminDuelRoutes(routes_group fiber.Router) {
+	duels_group := routes_group.Group("/duels")
+
+	duels_group.Get("/metrics",
+		func(c *fiber.Ctx) error {
+			return routes.GetDuelMetricsHandler(c, &server.Cache)
+		},
+	)
+
+	duels_group.Get("/aborted",
+		func(c *fiber.Ctx) error {
+			var params routes.ListAbortedDuelsParams
+			if err := c.QueryParser(&params); err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error": "invalid query parameters",
+				})
+			}
+			return routes.ListAbortedDuelsHandler(params, c, &server.Cache)
+		},
+	)
+
+	duels_group.Get("/flagged",
+		func(c *fiber.Ctx) error {
+			var params routes.ListFlaggedDuelsParams
+			if err := c.QueryParser(&params); err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error": "invalid query parameters",
+				})
+			}
+			return routes.ListFlaggedDuelsHandler(params, c, &server.Db)
+		},
+	)
+
+	duels_group.Post("/flagged/approve",
+		func(c *fiber.Ctx) error {
+			var data routes.ReviewFlaggedDuelData
+			if err := c.BodyParser(&data); err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error": "invalid request body",
+				})
+			}
+			return routes.ApproveFlaggedDuelHandler(data, c, &server.Cache, &server.Db, server.Notifications)
+		},
+	)
+
+	duels_group.Post("/flagged/dismiss",
+		func(c *fiber.Ctx) error {
+			var data routes.ReviewFlaggedDuelData
+			if err := c.BodyParser(&data); err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error": "invalid request body",
+				})
+			}
+			return routes.DismissFlaggedDuelHandler(data, c, &server.Cache, &server.Db, server.Notifications)
+		},
+	)
+
+	duels_group.Get("/dead_letters",
+		func(c *fiber.Ctx) error {
+			var params routes.ListDeadLettersParams
+			if err := c.QueryParser(&params); err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error": "invalid query parameters",
+				})
+			}
+			return routes.ListDeadLettersHandler(params, c, &server.Cache)
+		},
+	)
+
+	duels_group.Get("/dead_letter",
+		func(c *fiber.Ctx) error {
+			var params routes.DeadLetterParams
+			if err := c.QueryParser(&params); err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error": "invalid query parameters",
+				})
+			}
+			return routes.GetDeadLetterHandler(params, c, &server.Cache)
+		},
+	)
+
+	duels_group.Post("/dead_letter/replay",
+		func(c *fiber.Ctx) error {
+			var data routes.DeadLetterActionData
+			if err := c.BodyParser(&data); err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error": "invalid request body",
+				})
+			}
+			return routes.ReplayDeadLetterHandler(data, c, &server.Cache, &server.VaultManager)
+		},
+	)
+
+	duels_group.Post("/dead_letter/discard",
+		func(c *fiber.Ctx) error {
+			var data routes.DeadLetterActionData
+			if err := c.BodyParser(&data); err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error": "invalid request body",
+				})
+			}
+			return routes.DiscardDeadLetterHandler(data, c, &server.Cache)
+		},
+	)
+}
@@ -44,6 +44,38 @@ func (server *MaintenanceServer) RegisterDuelRoutes() {
 		},
 	)
 
+	friendlies_group.Get("/pending",
+		func(c *fiber.Ctx) error {
+			return routes.FriendliesPendingHandler(c, &server.Cache)
+		},
+	)
+
+	friendlies_group.Post("/cancel",
+		func(c *fiber.Ctx) error {
+			var data routes.FriendliesCancelData
+
+			if err := c.BodyParser(&data); err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error": "invalid request body",
+				})
+			}
+			return routes.FriendliesCancelHandler(data, c, &server.Cache, server.Notifications)
+		},
+	)
+
+	friendlies_group.Post("/rematch",
+		func(c *fiber.Ctx) error {
+			var params routes.FriendliesRematchParams
+
+			if err := c.QueryParser(&params); err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error": "invalid request body",
+				})
+			}
+			return routes.FriendliesRematchHandler(params, c, &server.Cache, &server.Db, server.Notifications)
+		},
+	)
+
 	friendlies_group.Get("/prepare",
 		func(c *fiber.Ctx) error {
 			var params routes.FriendliesPrepareResponseParams
@@ -56,6 +88,174 @@ func (server *MaintenanceServer) RegisterDuelRoutes() {
 			return routes.FriendliesPrepareHandler(params, c, &server.Cache, &server.Db, &server.VaultManager, server.Notifications)
 		},
 	)
+	spectate_group := duel_group.Group("/spectate")
+
+	spectate_group.Get("/live",
+		func(c *fiber.Ctx) error {
+			var params routes.GetLiveDuelParams
+
+			if err := c.QueryParser(&params); err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error": "invalid request body",
+				})
+			}
+			return routes.GetLiveDuelHandler(params, c, &server.Cache, &server.Db)
+		},
+	)
+
+	spectate_group.Get("/prepare",
+		func(c *fiber.Ctx) error {
+			var params routes.SpectatePrepareParams
+
+			if err := c.QueryParser(&params); err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error": "invalid request body",
+				})
+			}
+			return routes.SpectatePrepareHandler(params, c, &server.Cache, &server.Db, &server.VaultManager)
+		},
+	)
+
+	spectate_group.Get("/privacy",
+		func(c *fiber.Ctx) error {
+			return routes.GetSpectatePrivacyHandler(c, &server.Db)
+		},
+	)
+
+	spectate_group.Post("/privacy",
+		func(c *fiber.Ctx) error {
+			var data routes.SetSpectatePrivacyData
+
+			if err := c.BodyParser(&data); err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error": "invalid request body",
+				})
+			}
+			return routes.SetSpectatePrivacyHandler(data, c, &server.Db)
+		},
+	)
+
+	lobby_group := duel_group.Group("/lobby")
+
+	lobby_group.Post("/create",
+		func(c *fiber.Ctx) error {
+			var data routes.CreateLobbyData
+
+			if err := c.BodyParser(&data); err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error": "invalid request body",
+				})
+			}
+			return routes.CreateLobbyHandler(data, c, &server.Cache, &server.Db)
+		},
+	)
+
+	lobby_group.Get("",
+		func(c *fiber.Ctx) error {
+			var params routes.LobbyParams
+
+			if err := c.QueryParser(&params); err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error": "invalid request body",
+				})
+			}
+			return routes.GetLobbyHandler(params, c, &server.Cache)
+		},
+	)
+
+	lobby_group.Post("/join",
+		func(c *fiber.Ctx) error {
+			var data routes.LobbyActionData
+
+			if err := c.BodyParser(&data); err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error": "invalid request body",
+				})
+			}
+			return routes.JoinLobbyHandler(data, c, &server.Cache, &server.Db, server.Notifications)
+		},
+	)
+
+	lobby_group.Post("/leave",
+		func(c *fiber.Ctx) error {
+			var data routes.LobbyActionData
+
+			if err := c.BodyParser(&data); err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error": "invalid request body",
+				})
+			}
+			return routes.LeaveLobbyHandler(data, c, &server.Cache, server.Notifications)
+		},
+	)
+
+	lobby_group.Post("/start",
+		func(c *fiber.Ctx) error {
+			var data routes.LobbyActionData
+
+			if err := c.BodyParser(&data); err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error": "invalid request body",
+				})
+			}
+			return routes.StartLobbyHandler(data, c, &server.Cache, server.Notifications)
+		},
+	)
+
+	duel_group.Get("/rulesets",
+		func(c *fiber.Ctx) error {
+			var params routes.ListRulesetsParams
+
+			if err := c.QueryParser(&params); err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error": "invalid request body",
+				})
+			}
+			return routes.ListRulesetsHandler(params, c)
+		},
+	)
+
+	practice_group := duel_group.Group("/practice")
+
+	practice_group.Get("/bots",
+		func(c *fiber.Ctx) error {
+			return routes.ListPracticeBotsHandler(c, &server.Cache)
+		},
+	)
+
+	practice_group.Post("",
+		func(c *fiber.Ctx) error {
+			var data routes.StartPracticeData
+
+			if err := c.BodyParser(&data); err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error": "invalid request body",
+				})
+			}
+			return routes.StartPracticeHandler(data, c, &server.Cache, &server.Db)
+		},
+	)
+
+	ranked_group := duel_group.Group("/ranked")
+
+	ranked_group.Post("/queue/join",
+		func(c *fiber.Ctx) error {
+			return routes.JoinRankedQueueHandler(c, &server.Cache, &server.Db)
+		},
+	)
+
+	ranked_group.Post("/queue/leave",
+		func(c *fiber.Ctx) error {
+			return routes.LeaveRankedQueueHandler(c, &server.Cache)
+		},
+	)
+
+	ranked_group.Get("/queue/status",
+		func(c *fiber.Ctx) error {
+			return routes.GetRankedQueueStatusHandler(c, &server.Cache)
+		},
+	)
+
 	duel_group.Get("/session",
 		func(c *fiber.Ctx) error {
 			var params routes.GetDuelSessionDataParams
@@ -81,6 +281,32 @@ func (server *MaintenanceServer) RegisterDuelRoutes() {
 		},
 	)
 
+	duel_group.Post("/forfeit",
+		func(c *fiber.Ctx) error {
+			var params routes.ForfeitParams
+
+			if err := c.QueryParser(&params); err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error": "invalid request body",
+				})
+			}
+			return routes.ForfeitHandler(params, c, &server.Cache, &server.VaultManager)
+		},
+	)
+
+	duel_group.Get("/replay",
+		func(c *fiber.Ctx) error {
+			var params routes.GetDuelReplayParams
+
+			if err := c.QueryParser(&params); err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error": "invalid request body",
+				})
+			}
+			return routes.GetDuelReplayHandler(params, c, &server.Db, server.ReplayStore)
+		},
+	)
+
 	duel_group.Get("/history",
 		func(c *fiber.Ctx) error {
 			var params routes.GetDuelHistoryParams
@@ -93,66 +319,30 @@ func (server *MaintenanceServer) RegisterDuelRoutes() {
 			return routes.GetDuelHistoryHandler(params, c, &server.Cache, &server.Db, &server.VaultManager, server.Notifications)
 		},
 	)
-	// test_duel_group := server.App.Group("/test_duel")
-
-	// test_duel_group.Post("/result",
-	// 	func(c *fiber.Ctx) error {
-	// 		type TestDuelPlayerSummaryData struct {
-	// 			PID      string `json:"pid"`
-	// 			Elo      uint   `json:"elo"`
-	// 			Tag      string `json:"tag"`
-	// 			Username string `json:"username"`
-	// 		}
-
-	// 		type TestDDuelSessionData struct {
-	// 			P1       TestDuelPlayerSummaryData `json:"p1"`
-	// 			P2       TestDuelPlayerSummaryData `json:"p2"`
-	// 			DuelType string                    `json:"duel_type"`
-	// 		}
-
-	// 		type TestDuelResult struct {
-	// 			P1Summary   duels.Summary        `json:"p1_summary"`
-	// 			P2Summary   duels.Summary        `json:"p2_summary"`
-	// 			Outcome     duels.Outcome        `json:"outcome"`
-	// 			SessionData TestDDuelSessionData `json:"session_data"`
-	// 			SessionID   string               `json:"session_id"`
-	// 		}
-	// 		var data TestDuelResult
-
-	// 		if err := c.BodyParser(&data); err != nil {
-	// 			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-	// 				"error": "invalid request body",
-	// 			})
-	// 		}
-	// 		// Validate session ID
-	// 		if data.SessionID == "" {
-	// 			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-	// 				"error": "session_id is required",
-	// 			})
-	// 		}
-
-	// 		// Create the channel key
-	// 		channel := fmt.Sprintf("duel:result:%s", data.SessionID)
-
-	// 		// Convert the data to JSON
-	// 		result_json, err := json.Marshal(data)
-	// 		if err != nil {
-	// 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-	// 				"error": "failed to marshal result data",
-	// 			})
-	// 		}
-
-	// 		// Publish to Redis
-	// 		if err := server.Cache.Db.Publish(c.Context(), channel, result_json).Err(); err != nil {
-	// 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-	// 				"error": "failed to publish result",
-	// 			})
-	// 		}
-
-	// 		return c.Status(fiber.StatusOK).JSON(fiber.Map{
-	// 			"message": "duel result published successfully",
-	// 			"channel": channel,
-	// 		})
-	// 	},
-	// )
+
+	duel_group.Get("/history/export",
+		func(c *fiber.Ctx) error {
+			var params routes.ExportDuelHistoryParams
+
+			if err := c.QueryParser(&params); err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error": "invalid request body",
+				})
+			}
+			return routes.ExportDuelHistoryHandler(params, c, &server.Db)
+		},
+	)
+
+	duel_group.Get("/head_to_head",
+		func(c *fiber.Ctx) error {
+			var params routes.GetHeadToHeadParams
+
+			if err := c.QueryParser(&params); err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error": "invalid request body",
+				})
+			}
+			return routes.GetHeadToHeadHandler(params, c, &server.Db)
+		},
+	)
 }
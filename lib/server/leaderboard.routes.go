@@ -0,0 +1,65 @@
+package server
+
+import (
+	m "backend/lib/maintenance"
+	"backend/lib/server/middleware"
+	"backend/lib/server/routes"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func (server *MaintenanceServer) RegisterLeaderboardRoutes() {
+	leaderboard_group := server.App.Group("/leaderboard")
+	leaderboard_group.Use(
+		middleware.OnMSS(m.MODE_OPERATIONAL, m.STATE_RUNNING, m.SUBSTATE_SAFE),
+	)
+	leaderboard_group.Use(middleware.Protected(&server.AuthService))
+
+	leaderboard_group.Get("/global",
+		func(c *fiber.Ctx) error {
+			var params routes.LeaderboardParams
+			if err := c.QueryParser(&params); err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error": "invalid query parameters",
+				})
+			}
+			return routes.GetGlobalLeaderboardHandler(params, c, &server.Cache, &server.Db)
+		},
+	)
+
+	leaderboard_group.Get("/country",
+		func(c *fiber.Ctx) error {
+			var params routes.LeaderboardParams
+			if err := c.QueryParser(&params); err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error": "invalid query parameters",
+				})
+			}
+			return routes.GetCountryLeaderboardHandler(params, c, &server.Cache, &server.Db)
+		},
+	)
+
+	leaderboard_group.Get("/friends",
+		func(c *fiber.Ctx) error {
+			return routes.GetFriendsLeaderboardHandler(c, &server.Cache, &server.Db)
+		},
+	)
+
+	leaderboard_group.Get("/seasons",
+		func(c *fiber.Ctx) error {
+			return routes.ListSeasonsHandler(c, &server.Db)
+		},
+	)
+
+	leaderboard_group.Get("/season",
+		func(c *fiber.Ctx) error {
+			var params routes.GetSeasonStandingsParams
+			if err := c.QueryParser(&params); err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error": "invalid query parameters",
+				})
+			}
+			return routes.GetSeasonStandingsHandler(params, c, &server.Db)
+		},
+	)
+}
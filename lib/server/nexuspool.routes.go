@@ -0,0 +1,71 @@
+package server
+
+import (
+	m "backend/lib/maintenance"
+	"backend/lib/server/middleware"
+	"backend/lib/server/routes"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func (server *MaintenanceServer) RegisterNexusPoolRoutes() {
+	nexuspool_group := server.App.Group("/nexuspool")
+	nexuspool_group.Use(
+		middleware.OnMSS(m.MODE_OPERATIONAL, m.STATE_RUNNING, m.SUBSTATE_SAFE),
+		middleware.WithKey("NEXUSPOOL_ADM_KEY", func() (string, error) {
+			return server.VaultManager.GetApiKey("NEXUSPOOL_ADM_KEY")
+		}),
+	)
+
+	nexuspool_group.Post("/duel/start",
+		func(c *fiber.Ctx) error {
+			var params routes.StartDuelSessionParams
+
+			if err := c.QueryParser(&params); err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error": "invalid request body",
+				})
+			}
+			return routes.StartDuelSessionHandler(params, c, &server.Cache)
+		},
+	)
+
+	nexuspool_group.Post("/duel/disconnect",
+		func(c *fiber.Ctx) error {
+			var params routes.PlayerConnectionParams
+
+			if err := c.QueryParser(&params); err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error": "invalid request body",
+				})
+			}
+			return routes.DisconnectHandler(params, c, &server.Cache)
+		},
+	)
+
+	nexuspool_group.Post("/duel/reconnect",
+		func(c *fiber.Ctx) error {
+			var params routes.PlayerConnectionParams
+
+			if err := c.QueryParser(&params); err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error": "invalid request body",
+				})
+			}
+			return routes.ReconnectHandler(params, c, &server.Cache)
+		},
+	)
+
+	nexuspool_group.Post("/replay",
+		func(c *fiber.Ctx) error {
+			var params routes.UploadReplayParams
+
+			if err := c.QueryParser(&params); err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error": "invalid request body",
+				})
+			}
+			return routes.UploadReplayHandler(params, c, &server.Cache, &server.Db, &server.VaultManager, server.ReplayStore)
+		},
+	)
+}
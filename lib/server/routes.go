@@ -16,6 +16,12 @@ func (server *MaintenanceServer) RegisterRoutes() {
 
 	server.RegisterDuelRoutes()
 
+	server.RegisterTournamentRoutes()
+
+	server.RegisterNexusPoolRoutes()
+
+	server.RegisterLeaderboardRoutes()
+
 	server.RegisterRelationshipRoutes()
 
 	server.RegisterModulesRoutes()
@@ -25,6 +31,8 @@ func (server *MaintenanceServer) RegisterRoutes() {
 	server.RegisterAuthRoutes()
 
 	server.RegisterNotificationRoutes()
+
+	server.RegisterAdminRoutes()
 }
 
 func (server *MaintenanceServer) HelloWorldHandler(c *fiber.Ctx) error {
@@ -0,0 +1,166 @@
+package routes
+
+import (
+	"backend/lib/duels"
+	"backend/lib/services"
+	"backend/lib/vault"
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type ListDeadLettersParams struct {
+	Offset int `query:"offset"`
+	Limit  int `query:"limit"`
+}
+
+func ListDeadLettersHandler(params ListDeadLettersParams, ctx *fiber.Ctx, cache *services.Cache) error {
+	if params.Offset < 0 {
+		params.Offset = 0
+	}
+	if params.Limit <= 0 || params.Limit > 100 {
+		params.Limit = 20
+	}
+
+	dead_letters, total, err := cache.ListDeadLetters(params.Offset, params.Limit)
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to list dead letters",
+		})
+	}
+
+	return ctx.JSON(fiber.Map{
+		"dead_letters": dead_letters,
+		"total":        total,
+		"offset":       params.Offset,
+		"limit":        params.Limit,
+	})
+}
+
+type DeadLetterParams struct {
+	Id string `query:"id"`
+}
+
+func GetDeadLetterHandler(params DeadLetterParams, ctx *fiber.Ctx, cache *services.Cache) error {
+	if params.Id == "" {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "id is required",
+		})
+	}
+
+	dead_letter, err := cache.GetDeadLetter(params.Id)
+	if err != nil {
+		return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "dead letter not found",
+		})
+	}
+
+	var result duels.DuelResult
+	if err := json.Unmarshal(dead_letter.Payload, &result); err != nil {
+		// The raw payload is still returned so that it can be inspected
+		return ctx.JSON(fiber.Map{
+			"dead_letter": dead_letter,
+		})
+	}
+
+	return ctx.JSON(fiber.Map{
+		"dead_letter": dead_letter,
+		"result":      result,
+	})
+}
+
+type DeadLetterActionData struct {
+	Id string `json:"id"`
+}
+
+func ReplayDeadLetterHandler(data DeadLetterActionData, ctx *fiber.Ctx, cache *services.Cache, vault *vault.VaultManager) error {
+	if data.Id == "" {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "id is required",
+		})
+	}
+	if _, err := cache.GetDeadLetter(data.Id); err != nil {
+		return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "dead letter not found",
+		})
+	}
+
+	query_ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := duels.ReplayDeadLetter(query_ctx, cache, vault.NexusHMACKey(), data.Id); errors.Is(err, duels.ErrUnknownSession) {
+		return ctx.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "the duel session of this dead letter has expired, it cannot be replayed",
+		})
+	} else if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to replay dead letter",
+		})
+	}
+
+	return ctx.JSON(fiber.Map{
+		"message": "dead letter replayed",
+	})
+}
+
+func DiscardDeadLetterHandler(data DeadLetterActionData, ctx *fiber.Ctx, cache *services.Cache) error {
+	if data.Id == "" {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "id is required",
+		})
+	}
+
+	if err := cache.DeleteDeadLetter(data.Id); err != nil {
+		return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "dead letter not found",
+		})
+	}
+
+	return ctx.JSON(fiber.Map{
+		"message": "dead letter discarded",
+	})
+}
+
+func GetDuelMetricsHandler(ctx *fiber.Ctx, cache *services.Cache) error {
+	metrics, err := cache.GetDuelMetrics()
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to get duel metrics",
+		})
+	}
+
+	return ctx.JSON(fiber.Map{
+		"metrics": metrics,
+	})
+}
+
+type ListAbortedDuelsParams struct {
+	Offset int `query:"offset"`
+	Limit  int `query:"limit"`
+}
+
+func ListAbortedDuelsHandler(params ListAbortedDuelsParams, ctx *fiber.Ctx, cache *services.Cache) error {
+	if params.Offset < 0 {
+		params.Offset = 0
+	}
+	if params.Limit <= 0 || params.Limit > 100 {
+		params.Limit = 20
+	}
+
+	aborted_duels, total, err := cache.ListAbortedDuels(params.Offset, params.Limit)
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to list aborted duels",
+		})
+	}
+
+	return ctx.JSON(fiber.Map{
+		"aborted_duels": aborted_duels,
+		"total":         total,
+		"offset":        params.Offset,
+		"limit":         params.Limit,
+	})
+}
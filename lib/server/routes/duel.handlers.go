@@ -1,13 +1,16 @@
 package routes
 
 import (
+	"backend/lib/duels"
 	"backend/lib/notifications"
 	"backend/lib/server/middleware"
 	"backend/lib/server/routes/security"
 	"backend/lib/services"
+	"backend/lib/stats"
 	"backend/lib/vault"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
 	"time"
@@ -51,6 +54,18 @@ func FriendliesChallengeHandler(data FriendliesChallengeData, ctx *fiber.Ctx, ca
 			"error": "Cannot create this duel waiting room",
 		})
 	}
+	err = cache.AddChallenge(services.ChallengeData{
+		WaitingRoomID: waiting_room_id,
+		Challenger:    services.ChallengePlayerData{PID: user_id, Tag: user.Tag, Username: user.Username},
+		Opponent:      services.ChallengePlayerData{PID: opponent.ID, Tag: opponent.Tag, Username: opponent.Username},
+		CreatedAt:     time.Now(),
+		ExpiresAt:     time.Now().Add(services.WAITING_ROOM_TTL),
+	})
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Cannot create this challenge",
+		})
+	}
 	// Notify opponent
 	notify.Send(
 		ctx.Context(),
@@ -86,6 +101,200 @@ func FriendliesChallengeHandler(data FriendliesChallengeData, ctx *fiber.Ctx, ca
 	return ctx.SendStatus(fiber.StatusOK)
 }
 
+type FriendliesRematchParams struct {
+	DuelSessionId string `query:"duel_session_id"`
+}
+
+func FriendliesRematchHandler(params FriendliesRematchParams, ctx *fiber.Ctx, cache *services.Cache, db *services.Database, notify *notifications.NotificationService) error {
+	query_ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	queries := basepool.New(db.Pool)
+
+	user_id, err := middleware.GetUserID(ctx)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "unknown user",
+		})
+	}
+
+	session_data, err := cache.GetDuelSession(params.DuelSessionId)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid duel session",
+		})
+	}
+	if session_data.DuelType != basepool.DuelTypeFriendly {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "only friendly duels can be rematched",
+		})
+	}
+	if !session_data.IsClosed() {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "the duel is not finished yet",
+		})
+	}
+
+	var user, opponent services.DuelPlayerSummaryData
+	switch user_id {
+	case session_data.P1.PID:
+		user, opponent = session_data.P1, session_data.P2
+	case session_data.P2.PID:
+		user, opponent = session_data.P2, session_data.P1
+	default:
+		return ctx.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "you did not play this duel",
+		})
+	}
+
+	// Make sure the opponent still exists
+	if _, err := queries.GetUserByID(query_ctx, opponent.PID); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "cannot find this user",
+		})
+	}
+
+	waiting_room_id, err := cache.UpsertDuelWaitingRoom(services.WaitingRoomData{Player1ID: user_id, Player2ID: opponent.PID})
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Cannot create this duel waiting room",
+		})
+	}
+	err = cache.SetRematch(waiting_room_id, services.RematchData{
+		P1ID:              session_data.P2.PID,
+		P2ID:              session_data.P1.PID,
+		PreviousSessionID: params.DuelSessionId,
+	})
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Cannot create this rematch",
+		})
+	}
+	err = cache.AddChallenge(services.ChallengeData{
+		WaitingRoomID:     waiting_room_id,
+		Challenger:        services.ChallengePlayerData{PID: user.PID, Tag: user.Tag, Username: user.Username},
+		Opponent:          services.ChallengePlayerData{PID: opponent.PID, Tag: opponent.Tag, Username: opponent.Username},
+		PreviousSessionID: params.DuelSessionId,
+		CreatedAt:         time.Now(),
+		ExpiresAt:         time.Now().Add(services.WAITING_ROOM_TTL),
+	})
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Cannot create this rematch",
+		})
+	}
+
+	// Notify opponent, the rematch is answered like a challenge
+	notify.Send(
+		ctx.Context(),
+		notifications.TypeAlert,
+		"duel:rematch:request",
+		notifications.PriorityHigh,
+		opponent.PID,
+		fiber.Map{
+			"msg": fmt.Sprintf("%s#%s wants a rematch !", user.Username, user.Tag),
+		},
+		fiber.Map{
+			"waiting_room_id":  waiting_room_id,
+			"opponent_tag":     user.Tag,
+			"previous_session": params.DuelSessionId,
+			"expired_at":       time.Now().Add(services.WAITING_ROOM_TTL).UnixMilli(),
+		},
+	)
+	return ctx.SendStatus(fiber.StatusOK)
+}
+
+func FriendliesPendingHandler(ctx *fiber.Ctx, cache *services.Cache) error {
+	user_id, err := middleware.GetUserID(ctx)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "unknown user",
+		})
+	}
+
+	incoming, outgoing, err := cache.ListPendingChallenges(user_id)
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to list pending challenges",
+		})
+	}
+
+	format := func(challenges []services.ChallengeData, incoming bool) []fiber.Map {
+		formatted := make([]fiber.Map, 0, len(challenges))
+		for _, challenge := range challenges {
+			opponent := challenge.Opponent
+			if incoming {
+				opponent = challenge.Challenger
+			}
+			formatted = append(formatted, fiber.Map{
+				"waiting_room_id":   challenge.WaitingRoomID,
+				"opponent_tag":      opponent.Tag,
+				"opponent_username": opponent.Username,
+				"rematch":           challenge.PreviousSessionID != "",
+				"created_at":        challenge.CreatedAt.UnixMilli(),
+				"expired_at":        challenge.ExpiresAt.UnixMilli(),
+			})
+		}
+		return formatted
+	}
+
+	return ctx.JSON(fiber.Map{
+		"incoming": format(incoming, true),
+		"outgoing": format(outgoing, false),
+	})
+}
+
+type FriendliesCancelData struct {
+	WaitingRoomId string `json:"waiting_room_id"`
+}
+
+func FriendliesCancelHandler(data FriendliesCancelData, ctx *fiber.Ctx, cache *services.Cache, notify *notifications.NotificationService) error {
+	user_id, err := middleware.GetUserID(ctx)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "unknown user",
+		})
+	}
+
+	challenge, err := cache.GetChallenge(data.WaitingRoomId)
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to get the challenge",
+		})
+	}
+	if challenge == nil || time.Now().After(challenge.ExpiresAt) {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "No waiting room found",
+		})
+	}
+	if challenge.Challenger.PID != user_id {
+		return ctx.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "only the challenger can cancel the challenge",
+		})
+	}
+
+	err = cache.DeleteDuelWaitingRoom(services.WaitingRoomData{Player1ID: challenge.Challenger.PID, Player2ID: challenge.Opponent.PID})
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Cannot delete friendly duel waiting room",
+		})
+	}
+	notify.Send(
+		ctx.Context(),
+		notifications.TypeMessage,
+		"duel:challenge:cancellation",
+		notifications.PriorityHigh,
+		challenge.Opponent.PID,
+		fiber.Map{
+			"msg": fmt.Sprintf("%s#%s has cancelled the friendly duel", challenge.Challenger.Username, challenge.Challenger.Tag),
+		},
+		fiber.Map{
+			"waiting_room_id": challenge.WaitingRoomID,
+			"opponent_tag":    challenge.Challenger.Tag,
+		},
+	)
+	return ctx.SendStatus(fiber.StatusOK)
+}
+
 type FriendliesChallengeResponseData struct {
 	WaitingRoomId string `json:"waiting_room_id"`
 	Opponent_tag  string `json:"opponent_tag"`
@@ -152,6 +361,18 @@ func FriendliesChallengeResponeHandler(data FriendliesChallengeResponseData, ctx
 			"error": "cannot find this user",
 		})
 	}
+	challenger_id := p1_duel_summary_data.ID
+
+	// A rematch is played with the sides of the previous duel swapped
+	rematch, err := cache.GetRematch(waiting_room_id)
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Cannot create duel session",
+		})
+	}
+	if rematch != nil && rematch.P1ID == user_id {
+		p1_duel_summary_data, p2_duel_summary_data = p2_duel_summary_data, p1_duel_summary_data
+	}
 
 	session_id, err := cache.CreateDuelSession(&services.DuelSessionData{
 		DuelType: basepool.DuelTypeFriendly,
@@ -180,7 +401,7 @@ func FriendliesChallengeResponeHandler(data FriendliesChallengeResponseData, ctx
 		notifications.TypeRedirect,
 		"duel:acceptance",
 		notifications.PriorityHigh,
-		p1_duel_summary_data.ID,
+		challenger_id,
 		fiber.Map{
 			"msg": "The friendly duel has been accepted, you will be redirected to the duel...",
 		},
@@ -203,7 +424,7 @@ func FriendliesChallengeResponeHandler(data FriendliesChallengeResponseData, ctx
 			"duel_type":       "friendly",
 		},
 	)
-	err = cache.DeleteDuelWaitingRoom(services.WaitingRoomData{Player1ID: p1_duel_summary_data.ID, Player2ID: user_id})
+	err = cache.DeleteDuelWaitingRoom(services.WaitingRoomData{Player1ID: challenger_id, Player2ID: user_id})
 	if err != nil {
 		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error": "Cannot create arena session",
@@ -225,6 +446,11 @@ func FriendliesPrepareHandler(params FriendliesPrepareResponseParams, ctx *fiber
 			"error": "invalid duel session",
 		})
 	}
+	if session_data.IsClosed() {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "duel session is already finished",
+		})
+	}
 	aes_key := vault.OpenNexusAESKey
 
 	user_id, err := middleware.GetUserID(ctx)
@@ -260,6 +486,7 @@ func FriendliesPrepareHandler(params FriendliesPrepareResponseParams, ctx *fiber
 		p1_session_json, err := json.Marshal(fiber.Map{
 			"session_id": params.DuelSessionId,
 			"user_id":    session_data.P1.PID,
+			"role":       "player",
 		})
 		if err != nil {
 			return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
@@ -278,6 +505,7 @@ func FriendliesPrepareHandler(params FriendliesPrepareResponseParams, ctx *fiber
 		p2_session_json, err := json.Marshal(fiber.Map{
 			"session_id": params.DuelSessionId,
 			"user_id":    session_data.P2.PID,
+			"role":       "player",
 		})
 		if err != nil {
 			return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
@@ -298,12 +526,33 @@ func FriendliesPrepareHandler(params FriendliesPrepareResponseParams, ctx *fiber
 		})
 	}
 
+	// Preparing again, e.g. to reconnect, leaves a started session as is
+	_, err = cache.UpdateDuelSession(params.DuelSessionId, func(session_data services.DuelSessionData) (services.DuelSessionStatus, error) {
+		if session_data.Status == services.DuelSessionCreated {
+			return services.DuelSessionPrepared, nil
+		}
+		return session_data.Status, nil
+	})
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to prepare the duel session",
+		})
+	}
+
 	err = cache.RefreshActiveModule(user_id, db, false)
 	if err != nil {
 		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error": err.Error(),
 		})
 	}
+	// The house bot of a practice duel never prepares by itself
+	if session_data.BotID != "" {
+		if err := cache.RefreshActiveModule(session_data.P2.PID, db, false); err != nil {
+			return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "the bot is not available",
+			})
+		}
+	}
 
 	return ctx.JSON(response)
 }
@@ -329,6 +578,7 @@ func GetDuelSessionDataHandler(params GetDuelSessionDataParams, ctx *fiber.Ctx,
 	var response struct {
 		Context services.DuelSessionDataExtern `json:"context"`
 		Side    int                            `json:"side"`
+		Status  services.DuelSessionStatus     `json:"status"`
 	}
 	response.Context = services.DuelSessionDataExtern{
 		P1: services.DuelPlayerSummaryDataExtern{
@@ -342,7 +592,9 @@ func GetDuelSessionDataHandler(params GetDuelSessionDataParams, ctx *fiber.Ctx,
 			Username: session_data.P2.Username,
 		},
 		DuelType: session_data.DuelType,
+		Ruleset:  session_data.Ruleset,
 	}
+	response.Status = session_data.Status
 
 	if user_id.Bytes == session_data.P1.PID.Bytes {
 		response.Side = 1
@@ -396,8 +648,56 @@ type GetDuelHistoryParams struct {
 	Limit        int    `query:"limit"`
 	Cursor       string `query:"cursor"`
 	Opponent_tag string `query:"opponent_tag"`
+	DuelType     string `query:"duel_type"`
+	Outcome      string `query:"outcome"`
+	Method       string `query:"method"`
+	From         string `query:"from"`
+	To           string `query:"to"`
+	Total        bool   `query:"total"`
+}
+
+const DUEL_HISTORY_MAX_LIMIT = 100
+
+// duelHistoryFilter builds the history filter of a user from the query parameters
+func duelHistoryFilter(query_ctx context.Context, queries *basepool.Queries, user_id pgtype.UUID, params GetDuelHistoryParams) (services.DuelHistoryFilter, error) {
+	filter := services.DuelHistoryFilter{
+		UserID:        user_id,
+		DuelType:      params.DuelType,
+		Outcome:       services.HistoryOutcome(params.Outcome),
+		WinningMethod: params.Method,
+	}
+	switch filter.Outcome {
+	case "", services.HistoryWon, services.HistoryLost, services.HistoryDraw:
+	default:
+		return filter, errors.New("invalid outcome")
+	}
+	if params.From != "" {
+		from, err := services.StringToTimestampz(params.From)
+		if err != nil {
+			return filter, errors.New("invalid from date")
+		}
+		filter.From = from.Time
+	}
+	if params.To != "" {
+		to, err := services.StringToTimestampz(params.To)
+		if err != nil {
+			return filter, errors.New("invalid to date")
+		}
+		filter.To = to.Time
+	}
+	if params.Opponent_tag != "" {
+		opponent, err := queries.GetUserIDByTag(query_ctx, params.Opponent_tag)
+		if err != nil {
+			return filter, errors.New("cannot find this user")
+		}
+		filter.OpponentID = opponent.ID
+	}
+	return filter, nil
 }
 
+// GetDuelHistoryHandler returns a page of the duel history of the user.
+// The duels are services.DuelHistoryRow, which adds the ruleset of the duel to the rows generated by base-pool,
+// and nextCursor is an opaque cursor: clients must pass it back as is instead of the RFC3339 date it used to be.
 func GetDuelHistoryHandler(params GetDuelHistoryParams, ctx *fiber.Ctx, cache *services.Cache, db *services.Database, vault *vault.VaultManager, notify *notifications.NotificationService) error {
 	query_ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
@@ -409,80 +709,141 @@ func GetDuelHistoryHandler(params GetDuelHistoryParams, ctx *fiber.Ctx, cache *s
 			"error": "unknown user",
 		})
 	}
-	if params.Limit == 0 {
+	if params.Limit <= 0 {
 		params.Limit = 20 // Default limit
+	} else if params.Limit > DUEL_HISTORY_MAX_LIMIT {
+		params.Limit = DUEL_HISTORY_MAX_LIMIT
 	}
-	var cursor pgtype.Timestamptz
+	var cursor *services.HistoryCursor
 	if params.Cursor != "" {
-		cursor, err = services.StringToTimestampz(params.Cursor)
+		decoded, err := services.DecodeHistoryCursor(params.Cursor)
 		if err != nil {
 			return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 				"error": "invalid cursor",
 			})
 		}
+		cursor = &decoded
 	}
 
-	if params.Opponent_tag != "" {
-		opponent, err := queries.GetUserIDByTag(query_ctx, params.Opponent_tag)
-		if err != nil {
-			return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-				"error": "cannot find this user",
-			})
-		}
+	filter, err := duelHistoryFilter(query_ctx, queries, user_id, params)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
 
-		duels, err := queries.GetUserDuelHistoryFilterTag(query_ctx, basepool.GetUserDuelHistoryFilterTagParams{
-			UserID:     user_id,
-			TimeCursor: cursor,
-			LimitAt:    int32(params.Limit + 1), // Get one extra to check if there are more results
-			OppID:      opponent.ID,
+	// Get one extra to check if there are more results
+	duels, err := services.ListDuelHistory(query_ctx, db.Pool, filter, cursor, params.Limit+1)
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to fetch duel history",
 		})
+	}
+
+	hasMore := len(duels) > params.Limit
+	if hasMore {
+		duels = duels[:params.Limit] // Remove the extra item
+	}
+
+	nextCursor := ""
+	if hasMore && len(duels) > 0 {
+		last := duels[len(duels)-1]
+		nextCursor = services.HistoryCursor{Date: last.Date.Time, SessionID: last.SessionID}.Encode()
+	}
+
+	response := fiber.Map{
+		"duels":      duels,
+		"hasMore":    hasMore,
+		"nextCursor": nextCursor,
+	}
+	if params.Total {
+		total, err := services.CountDuelHistory(query_ctx, db.Pool, filter)
 		if err != nil {
 			return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"error": "failed to fetch duel history",
+				"error": "failed to count duel history",
 			})
 		}
+		response["total"] = total
+	}
+	return ctx.Status(fiber.StatusOK).JSON(response)
+}
 
-		hasMore := len(duels) > params.Limit
-		if hasMore {
-			duels = duels[:params.Limit] // Remove the extra item
-		}
+type GetHeadToHeadParams struct {
+	Opponent_tag string `query:"opponent_tag"`
+	Last         int    `query:"last"`
+}
 
-		nextCursor := ""
-		if hasMore && len(duels) > 0 {
-			nextCursor = duels[len(duels)-1].Date.Time.Format(time.RFC3339)
-		}
+func GetHeadToHeadHandler(params GetHeadToHeadParams, ctx *fiber.Ctx, db *services.Database) error {
+	query_ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	queries := basepool.New(db.Pool)
 
-		return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
-			"duels":      duels,
-			"hasMore":    hasMore,
-			"nextCursor": nextCursor,
+	user_id, err := middleware.GetUserID(ctx)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "unknown user",
 		})
-	} else {
-		duels, err := queries.GetUserDuelHistory(query_ctx, basepool.GetUserDuelHistoryParams{
-			UserID:     user_id,
-			TimeCursor: cursor,
-			LimitAt:    int32(params.Limit + 1), // Get one extra to check if there are more results
+	}
+	if params.Last <= 0 || params.Last > 20 {
+		params.Last = 5 // Default number of recent duels
+	}
+
+	opponent, err := queries.GetUserIDByTag(query_ctx, params.Opponent_tag)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "cannot find this user",
 		})
-		if err != nil {
-			return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"error": "failed to fetch duel history",
-			})
-		}
+	}
 
-		hasMore := len(duels) > params.Limit
-		if hasMore {
-			duels = duels[:params.Limit] // Remove the extra item
-		}
+	duels, err := services.ListHeadToHead(query_ctx, db.Pool, user_id, opponent.ID)
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to fetch head to head",
+		})
+	}
 
-		nextCursor := ""
-		if hasMore && len(duels) > 0 {
-			nextCursor = duels[len(duels)-1].Date.Time.Format(time.RFC3339)
-		}
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"opponent_tag": params.Opponent_tag,
+		"head_to_head": stats.ComputeHeadToHead(duels, params.Last),
+	})
+}
 
-		return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
-			"duels":      duels,
-			"hasMore":    hasMore,
-			"nextCursor": nextCursor,
+type ForfeitParams struct {
+	DuelSessionId string `query:"duel_session_id"`
+}
+
+func ForfeitHandler(params ForfeitParams, ctx *fiber.Ctx, cache *services.Cache, vault *vault.VaultManager) error {
+	query_ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	user_id, err := middleware.GetUserID(ctx)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "unknown user",
+		})
+	}
+
+	err = duels.Forfeit(query_ctx, cache, vault.NexusHMACKey(), params.DuelSessionId, user_id)
+	switch {
+	case errors.Is(err, duels.ErrUnknownSession):
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid duel session",
+		})
+	case errors.Is(err, duels.ErrNotAPlayer):
+		return ctx.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "invalid user",
+		})
+	case errors.Is(err, duels.ErrSessionClosed), errors.Is(err, duels.ErrAlreadyForfeited):
+		return ctx.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "duel session is already finished",
+		})
+	case err != nil:
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to forfeit the duel",
 		})
 	}
+
+	return ctx.JSON(fiber.Map{
+		"message": "duel forfeited",
+	})
 }
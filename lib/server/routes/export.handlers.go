@@ -0,0 +1,145 @@
+package routes
+
+import (
+	"backend/lib/server/middleware"
+	"backend/lib/services"
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"log/slog"
+	"strconv"
+	"time"
+
+	basepool "github.com/ciphrpool/base-pool/gen"
+	"github.com/gofiber/fiber/v2"
+)
+
+// Rows are read by batches so that an export never holds the whole history in memory
+const DUEL_HISTORY_EXPORT_BATCH = 500
+
+type ExportDuelHistoryParams struct {
+	Format       string `query:"format"`
+	Opponent_tag string `query:"opponent_tag"`
+	DuelType     string `query:"duel_type"`
+	Outcome      string `query:"outcome"`
+	Method       string `query:"method"`
+	From         string `query:"from"`
+	To           string `query:"to"`
+}
+
+var duelHistoryCSVHeader = []string{
+	"session_id", "date", "duel_type", "duel_outcome", "winning_method", "duration",
+	"p1_tag", "p1_username", "p1_elo_delta", "p2_tag", "p2_username", "p2_elo_delta", "ruleset",
+}
+
+func duelHistoryCSVRecord(row services.DuelHistoryRow) []string {
+	return []string{
+		services.UUIDToString(row.SessionID),
+		row.Date.Time.UTC().Format(time.RFC3339Nano),
+		row.DuelType,
+		row.DuelOutcome,
+		row.WinningMethod,
+		strconv.Itoa(int(row.Duration)),
+		row.P1Tag,
+		row.P1Username,
+		strconv.Itoa(int(row.P1EloDelta)),
+		row.P2Tag,
+		row.P2Username,
+		strconv.Itoa(int(row.P2EloDelta)),
+		string(row.Ruleset),
+	}
+}
+
+func ExportDuelHistoryHandler(params ExportDuelHistoryParams, ctx *fiber.Ctx, db *services.Database) error {
+	query_ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	queries := basepool.New(db.Pool)
+
+	user_id, err := middleware.GetUserID(ctx)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "unknown user",
+		})
+	}
+
+	filter, err := duelHistoryFilter(query_ctx, queries, user_id, GetDuelHistoryParams{
+		Opponent_tag: params.Opponent_tag,
+		DuelType:     params.DuelType,
+		Outcome:      params.Outcome,
+		Method:       params.Method,
+		From:         params.From,
+		To:           params.To,
+	})
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	var write func(w *bufio.Writer, rows []services.DuelHistoryRow) error
+	switch params.Format {
+	case "csv":
+		ctx.Set(fiber.HeaderContentType, "text/csv; charset=utf-8")
+		write = func(w *bufio.Writer, rows []services.DuelHistoryRow) error {
+			writer := csv.NewWriter(w)
+			for _, row := range rows {
+				if err := writer.Write(duelHistoryCSVRecord(row)); err != nil {
+					return err
+				}
+			}
+			writer.Flush()
+			return writer.Error()
+		}
+	case "ndjson":
+		ctx.Set(fiber.HeaderContentType, "application/x-ndjson")
+		write = func(w *bufio.Writer, rows []services.DuelHistoryRow) error {
+			encoder := json.NewEncoder(w)
+			for _, row := range rows {
+				if err := encoder.Encode(row); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	default:
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "format must be csv or ndjson",
+		})
+	}
+	ctx.Set(fiber.HeaderContentDisposition, `attachment; filename="duel_history.`+params.Format+`"`)
+
+	// The stream is written once the handler returns, the status cannot change past this point
+	ctx.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		if params.Format == "csv" {
+			writer := csv.NewWriter(w)
+			writer.Write(duelHistoryCSVHeader)
+			writer.Flush()
+		}
+
+		var cursor *services.HistoryCursor
+		for {
+			batch_ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			rows, err := services.ListDuelHistory(batch_ctx, db.Pool, filter, cursor, DUEL_HISTORY_EXPORT_BATCH)
+			cancel()
+			if err != nil {
+				slog.Error("duel history export failed", "error", err, "user_id", services.UUIDToString(user_id))
+				return
+			}
+			if err := write(w, rows); err != nil {
+				slog.Error("duel history export failed", "error", err, "user_id", services.UUIDToString(user_id))
+				return
+			}
+			// The client went away
+			if err := w.Flush(); err != nil {
+				return
+			}
+			if len(rows) < DUEL_HISTORY_EXPORT_BATCH {
+				return
+			}
+			last := rows[len(rows)-1]
+			cursor = &services.HistoryCursor{Date: last.Date.Time, SessionID: last.SessionID}
+		}
+	})
+	return nil
+}
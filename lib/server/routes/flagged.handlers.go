@@ -0,0 +1,85 @@
+package routes
+
+import (
+	"backend/lib/duels"
+	"backend/lib/notifications"
+	"backend/lib/services"
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type ListFlaggedDuelsParams struct {
+	Offset int `query:"offset"`
+	Limit  int `query:"limit"`
+}
+
+// ListFlaggedDuelsHandler returns the suspicious duel results awaiting moderation
+func ListFlaggedDuelsHandler(params ListFlaggedDuelsParams, ctx *fiber.Ctx, db *services.Database) error {
+	query_ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if params.Offset < 0 {
+		params.Offset = 0
+	}
+	if params.Limit <= 0 || params.Limit > 100 {
+		params.Limit = 20
+	}
+
+	flagged_duels, total, err := services.ListFlaggedDuels(query_ctx, db.Pool, params.Offset, params.Limit)
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to list flagged duels",
+		})
+	}
+
+	return ctx.JSON(fiber.Map{
+		"flagged_duels": flagged_duels,
+		"total":         total,
+		"offset":        params.Offset,
+		"limit":         params.Limit,
+	})
+}
+
+type ReviewFlaggedDuelData struct {
+	SessionId string `json:"session_id"`
+}
+
+type reviewFlaggedResult func(ctx context.Context, cache *services.Cache, db *services.Database, notify *notifications.NotificationService, session_id string) error
+
+func reviewFlaggedDuel(data ReviewFlaggedDuelData, ctx *fiber.Ctx, cache *services.Cache, db *services.Database, notify *notifications.NotificationService, review reviewFlaggedResult, message string) error {
+	if data.SessionId == "" {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "session_id is required",
+		})
+	}
+
+	err := review(ctx.Context(), cache, db, notify, data.SessionId)
+	if errors.Is(err, services.ErrFlaggedDuelNotFound) {
+		return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "flagged duel not found",
+		})
+	} else if err != nil {
+		slog.Error("failed to review flagged duel", "error", err, "SessionID", data.SessionId)
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to review flagged duel",
+		})
+	}
+
+	return ctx.JSON(fiber.Map{
+		"message": message,
+	})
+}
+
+// ApproveFlaggedDuelHandler accepts a flagged result and applies its deferred rating change
+func ApproveFlaggedDuelHandler(data ReviewFlaggedDuelData, ctx *fiber.Ctx, cache *services.Cache, db *services.Database, notify *notifications.NotificationService) error {
+	return reviewFlaggedDuel(data, ctx, cache, db, notify, duels.ApproveFlaggedResult, "flagged duel approved")
+}
+
+// DismissFlaggedDuelHandler rejects a flagged result, which then never affects ratings
+func DismissFlaggedDuelHandler(data ReviewFlaggedDuelData, ctx *fiber.Ctx, cache *services.Cache, db *services.Database, notify *notifications.NotificationService) error {
+	return reviewFlaggedDuel(data, ctx, cache, db, notify, duels.DismissFlaggedResult, "flagged duel dismissed")
+}
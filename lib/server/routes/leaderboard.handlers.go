@@ -0,0 +1,168 @@
+package routes
+
+import (
+	"backend/lib/leaderboards"
+	"backend/lib/seasons"
+	"backend/lib/server/middleware"
+	"backend/lib/services"
+	"context"
+	"time"
+
+	basepool "github.com/ciphrpool/base-pool/gen"
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	LEADERBOARD_DEFAULT_LIMIT = 50
+	LEADERBOARD_MAX_LIMIT     = 100
+)
+
+type LeaderboardParams struct {
+	Cursor  int64  `query:"cursor"`
+	Limit   int64  `query:"limit"`
+	Country string `query:"country"`
+}
+
+func (params *LeaderboardParams) normalize() {
+	if params.Cursor < 0 {
+		params.Cursor = 0
+	}
+	if params.Limit <= 0 || params.Limit > LEADERBOARD_MAX_LIMIT {
+		params.Limit = LEADERBOARD_DEFAULT_LIMIT
+	}
+}
+
+func leaderboardPage(key string, params LeaderboardParams, ctx *fiber.Ctx, cache *services.Cache, db *services.Database) error {
+	query_ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	user_id, err := middleware.GetUserID(ctx)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "unknown user",
+		})
+	}
+	params.normalize()
+
+	entries, next_cursor, err := cache.GetLeaderboard(key, params.Cursor, params.Limit)
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to get leaderboard",
+		})
+	}
+	me, err := cache.GetLeaderboardRank(key, services.UUIDToString(user_id))
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to get leaderboard rank",
+		})
+	}
+	season, err := seasons.Current(query_ctx, cache, db)
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to get current season",
+		})
+	}
+
+	return ctx.JSON(fiber.Map{
+		"entries":     entries,
+		"next_cursor": next_cursor,
+		"me":          me,
+		"season":      season,
+	})
+}
+
+func GetGlobalLeaderboardHandler(params LeaderboardParams, ctx *fiber.Ctx, cache *services.Cache, db *services.Database) error {
+	return leaderboardPage(services.LEADERBOARD_GLOBAL_KEY, params, ctx, cache, db)
+}
+
+func GetCountryLeaderboardHandler(params LeaderboardParams, ctx *fiber.Ctx, cache *services.Cache, db *services.Database) error {
+	if params.Country == "" {
+		// Default to the country of the user
+		query_ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		queries := basepool.New(db.Pool)
+
+		user_id, err := middleware.GetUserID(ctx)
+		if err != nil {
+			return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "unknown user",
+			})
+		}
+		user, err := queries.GetUserByID(query_ctx, user_id)
+		if err != nil {
+			return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "user not found",
+			})
+		}
+		params.Country = user.Country
+	}
+	if params.Country == "" {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "country is required",
+		})
+	}
+
+	return leaderboardPage(services.LeaderboardCountryKey(params.Country), params, ctx, cache, db)
+}
+
+func GetFriendsLeaderboardHandler(ctx *fiber.Ctx, cache *services.Cache, db *services.Database) error {
+	query_ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	queries := basepool.New(db.Pool)
+
+	user_id, err := middleware.GetUserID(ctx)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "unknown user",
+		})
+	}
+
+	friends, err := queries.GetAllFriends(query_ctx, user_id)
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to get friends",
+		})
+	}
+
+	user_ids := []string{services.UUIDToString(user_id)}
+	for _, friend := range friends {
+		user_ids = append(user_ids, services.UUIDToString(friend.ID))
+	}
+
+	entries, err := cache.GetLeaderboardAmong(user_ids)
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to get leaderboard",
+		})
+	}
+
+	var me *services.LeaderboardEntry
+	for i := range entries {
+		if entries[i].UserID == user_ids[0] {
+			me = &entries[i]
+			break
+		}
+	}
+
+	return ctx.JSON(fiber.Map{
+		"entries": entries,
+		"me":      me,
+	})
+}
+
+func RebuildLeaderboardsHandler(ctx *fiber.Ctx, cache *services.Cache, db *services.Database) error {
+	query_ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	count, err := leaderboards.Rebuild(query_ctx, cache, db)
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to rebuild leaderboards",
+		})
+	}
+
+	return ctx.JSON(fiber.Map{
+		"message": "leaderboards rebuilt",
+		"players": count,
+	})
+}
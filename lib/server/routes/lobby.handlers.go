@@ -0,0 +1,364 @@
+package routes
+
+import (
+	"backend/lib/notifications"
+	"backend/lib/rulesets"
+	"backend/lib/server/middleware"
+	"backend/lib/services"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	basepool "github.com/ciphrpool/base-pool/gen"
+	"github.com/gofiber/fiber/v2"
+)
+
+var (
+	errLobbyStarted = errors.New("the lobby has already started")
+	errLobbyFull    = errors.New("the lobby is full")
+	errLobbyHost    = errors.New("you are the host of this lobby")
+	errNotLobbyHost = errors.New("only the host can start the lobby")
+	errLobbyEmpty   = errors.New("nobody has joined the lobby yet")
+)
+
+func normalizeLobbyCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// lobbyError maps the errors of a lobby update to a response
+func lobbyError(ctx *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, services.ErrLobbyNotFound):
+		return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "cannot find this lobby",
+		})
+	case errors.Is(err, errLobbyStarted), errors.Is(err, errLobbyFull), errors.Is(err, errLobbyHost), errors.Is(err, errLobbyEmpty):
+		return ctx.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	case errors.Is(err, errNotLobbyHost):
+		return ctx.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	default:
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to update the lobby",
+		})
+	}
+}
+
+type CreateLobbyData struct {
+	Preset    string             `json:"preset"`
+	Overrides rulesets.Overrides `json:"overrides"`
+}
+
+func CreateLobbyHandler(data CreateLobbyData, ctx *fiber.Ctx, cache *services.Cache, db *services.Database) error {
+	query_ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	queries := basepool.New(db.Pool)
+
+	user_id, err := middleware.GetUserID(ctx)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "unknown user",
+		})
+	}
+	preset, err := rulesets.Preset(basepool.DuelTypeFriendly, data.Preset)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "unknown ruleset preset",
+		})
+	}
+	ruleset, err := rulesets.Customize(preset, data.Overrides)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	host, err := queries.GetUserDuelSummaryDataById(query_ctx, user_id)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "cannot find this user",
+		})
+	}
+
+	lobby := services.LobbyData{
+		Host: services.DuelPlayerSummaryData{
+			PID:      host.ID,
+			Elo:      uint(host.Elo),
+			Tag:      host.Tag,
+			Username: host.Username,
+		},
+		Ruleset: ruleset,
+	}
+	code, err := cache.CreateLobby(&lobby)
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Cannot create this lobby",
+		})
+	}
+
+	return ctx.JSON(fiber.Map{
+		"code":       code,
+		"ruleset":    lobby.Ruleset,
+		"expired_at": lobby.CreatedAt.Add(services.LOBBY_TTL).UnixMilli(),
+	})
+}
+
+type LobbyParams struct {
+	Code string `query:"code"`
+}
+
+func GetLobbyHandler(params LobbyParams, ctx *fiber.Ctx, cache *services.Cache) error {
+	lobby, err := cache.GetLobby(normalizeLobbyCode(params.Code))
+	if err != nil {
+		return lobbyError(ctx, err)
+	}
+
+	response := fiber.Map{
+		"code":    lobby.Code,
+		"status":  lobby.Status,
+		"ruleset": lobby.Ruleset,
+		"host": services.DuelPlayerSummaryDataExtern{
+			Elo:      lobby.Host.Elo,
+			Tag:      lobby.Host.Tag,
+			Username: lobby.Host.Username,
+		},
+		"guest": nil,
+	}
+	if lobby.Guest != nil {
+		response["guest"] = services.DuelPlayerSummaryDataExtern{
+			Elo:      lobby.Guest.Elo,
+			Tag:      lobby.Guest.Tag,
+			Username: lobby.Guest.Username,
+		}
+	}
+	return ctx.JSON(response)
+}
+
+type LobbyActionData struct {
+	Code string `json:"code"`
+}
+
+func JoinLobbyHandler(data LobbyActionData, ctx *fiber.Ctx, cache *services.Cache, db *services.Database, notify *notifications.NotificationService) error {
+	query_ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	queries := basepool.New(db.Pool)
+
+	user_id, err := middleware.GetUserID(ctx)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "unknown user",
+		})
+	}
+	guest, err := queries.GetUserDuelSummaryDataById(query_ctx, user_id)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "cannot find this user",
+		})
+	}
+
+	lobby, err := cache.UpdateLobby(normalizeLobbyCode(data.Code), func(lobby *services.LobbyData) error {
+		switch {
+		case lobby.Status != services.LobbyOpen:
+			return errLobbyStarted
+		case lobby.Host.PID == user_id:
+			return errLobbyHost
+		case lobby.Guest != nil && lobby.Guest.PID != user_id:
+			return errLobbyFull
+		}
+		lobby.Guest = &services.DuelPlayerSummaryData{
+			PID:      guest.ID,
+			Elo:      uint(guest.Elo),
+			Tag:      guest.Tag,
+			Username: guest.Username,
+		}
+		return nil
+	})
+	if err != nil {
+		return lobbyError(ctx, err)
+	}
+
+	notify.Send(
+		ctx.Context(),
+		notifications.TypeAlert,
+		"duel:lobby:join",
+		notifications.PriorityHigh,
+		lobby.Host.PID,
+		fiber.Map{
+			"msg": fmt.Sprintf("%s#%s has joined your lobby", guest.Username, guest.Tag),
+		},
+		fiber.Map{
+			"code":         lobby.Code,
+			"opponent_tag": guest.Tag,
+		},
+	)
+	return ctx.JSON(fiber.Map{
+		"code":    lobby.Code,
+		"ruleset": lobby.Ruleset,
+	})
+}
+
+// LeaveLobbyHandler removes the guest from a lobby, or closes it when called by the host
+func LeaveLobbyHandler(data LobbyActionData, ctx *fiber.Ctx, cache *services.Cache, notify *notifications.NotificationService) error {
+	user_id, err := middleware.GetUserID(ctx)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "unknown user",
+		})
+	}
+
+	code := normalizeLobbyCode(data.Code)
+	lobby, err := cache.GetLobby(code)
+	if err != nil {
+		return lobbyError(ctx, err)
+	}
+	if lobby.Status != services.LobbyOpen {
+		return lobbyError(ctx, errLobbyStarted)
+	}
+
+	if lobby.Host.PID == user_id {
+		if err := cache.DeleteLobby(code); err != nil {
+			return lobbyError(ctx, err)
+		}
+		if lobby.Guest != nil {
+			notify.Send(
+				ctx.Context(),
+				notifications.TypeMessage,
+				"duel:lobby:closed",
+				notifications.PriorityHigh,
+				lobby.Guest.PID,
+				fiber.Map{
+					"msg": fmt.Sprintf("%s#%s has closed the lobby", lobby.Host.Username, lobby.Host.Tag),
+				},
+				fiber.Map{
+					"code": code,
+				},
+			)
+		}
+		return ctx.SendStatus(fiber.StatusOK)
+	}
+
+	lobby, err = cache.UpdateLobby(code, func(lobby *services.LobbyData) error {
+		if lobby.Status != services.LobbyOpen {
+			return errLobbyStarted
+		}
+		if lobby.Guest != nil && lobby.Guest.PID == user_id {
+			lobby.Guest = nil
+		}
+		return nil
+	})
+	if err != nil {
+		return lobbyError(ctx, err)
+	}
+
+	notify.Send(
+		ctx.Context(),
+		notifications.TypeMessage,
+		"duel:lobby:leave",
+		notifications.PriorityMedium,
+		lobby.Host.PID,
+		fiber.Map{
+			"msg": "Your opponent has left the lobby",
+		},
+		fiber.Map{
+			"code": code,
+		},
+	)
+	return ctx.SendStatus(fiber.StatusOK)
+}
+
+// StartLobbyHandler creates the duel session of a lobby with its ruleset
+func StartLobbyHandler(data LobbyActionData, ctx *fiber.Ctx, cache *services.Cache, notify *notifications.NotificationService) error {
+	user_id, err := middleware.GetUserID(ctx)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "unknown user",
+		})
+	}
+
+	code := normalizeLobbyCode(data.Code)
+	lobby, err := cache.UpdateLobby(code, func(lobby *services.LobbyData) error {
+		switch {
+		case lobby.Host.PID != user_id:
+			return errNotLobbyHost
+		case lobby.Status != services.LobbyOpen:
+			return errLobbyStarted
+		case lobby.Guest == nil:
+			return errLobbyEmpty
+		}
+		lobby.Status = services.LobbyStarted
+		return nil
+	})
+	if err != nil {
+		return lobbyError(ctx, err)
+	}
+
+	ruleset := lobby.Ruleset
+	session_id, err := cache.CreateDuelSession(&services.DuelSessionData{
+		DuelType: basepool.DuelTypeFriendly,
+		P1:       lobby.Host,
+		P2:       *lobby.Guest,
+		Ruleset:  &ruleset,
+	})
+	if err != nil {
+		reopenLobby(cache, code, "")
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Cannot create duel session",
+		})
+	}
+	lobby, err = cache.UpdateLobby(code, func(lobby *services.LobbyData) error {
+		lobby.SessionID = session_id
+		return nil
+	})
+	if err != nil {
+		reopenLobby(cache, code, session_id)
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Cannot create duel session",
+		})
+	}
+
+	// Notify both players with the Duel Session
+	for _, player := range []services.DuelPlayerSummaryData{lobby.Host, *lobby.Guest} {
+		notify.Send(
+			ctx.Context(),
+			notifications.TypeRedirect,
+			"duel:acceptance",
+			notifications.PriorityHigh,
+			player.PID,
+			fiber.Map{
+				"msg": "The lobby has started, you will be redirected to the duel...",
+			},
+			fiber.Map{
+				"duel_session_id": session_id,
+				"duel_type":       "friendly",
+			},
+		)
+	}
+
+	return ctx.JSON(fiber.Map{
+		"duel_session_id": session_id,
+	})
+}
+
+// reopenLobby lets the host start a lobby again after its duel session could not be set up.
+// The session created for the lobby, if any, is aborted so that its players are free to play.
+func reopenLobby(cache *services.Cache, code string, session_id string) {
+	if session_id != "" {
+		if _, err := cache.TransitionDuelSession(session_id, services.DuelSessionAborted); err != nil {
+			slog.Error("failed to abort lobby duel session", "error", err, "SessionID", session_id)
+		}
+	}
+	_, err := cache.UpdateLobby(code, func(lobby *services.LobbyData) error {
+		lobby.Status = services.LobbyOpen
+		lobby.SessionID = ""
+		return nil
+	})
+	if err != nil {
+		slog.Error("failed to reopen lobby", "error", err, "code", code)
+	}
+}
@@ -116,7 +116,7 @@ func PushModuleHandler(data PushModuleData, ctx *fiber.Ctx, cache *services.Cach
 		})
 	}
 
-	hmac_key := vault.OpenNexusHMACKey
+	hmac_key := vault.NexusHMACKey()
 	res, err := security.CheckHMACwithUserID([]byte(hmac_key), services.UUIDToString(user_id), data.Code, data.Hmac)
 	if err != nil || !res {
 		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
@@ -0,0 +1,93 @@
+package routes
+
+import (
+	"backend/lib/duels"
+	"backend/lib/services"
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+type StartDuelSessionParams struct {
+	DuelSessionId string `query:"duel_session_id"`
+}
+
+// StartDuelSessionHandler is called by the nexuspool once both players joined the duel
+func StartDuelSessionHandler(params StartDuelSessionParams, ctx *fiber.Ctx, cache *services.Cache) error {
+	session_data, err := cache.TransitionDuelSession(params.DuelSessionId, services.DuelSessionRunning)
+	if errors.Is(err, redis.Nil) {
+		return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "invalid duel session",
+		})
+	} else if errors.Is(err, services.ErrInvalidTransition) {
+		return ctx.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "duel session cannot be started",
+		})
+	} else if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to start the duel session",
+		})
+	}
+
+	return ctx.JSON(fiber.Map{
+		"status": session_data.Status,
+	})
+}
+
+type PlayerConnectionParams struct {
+	DuelSessionId string `query:"duel_session_id"`
+	UserId        string `query:"user_id"`
+}
+
+// DisconnectHandler is called by the nexuspool when a player leaves a running duel
+func DisconnectHandler(params PlayerConnectionParams, ctx *fiber.Ctx, cache *services.Cache) error {
+	user_id, err := services.StringToUUID(params.UserId)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid user",
+		})
+	}
+
+	err = duels.Disconnect(cache, params.DuelSessionId, user_id)
+	switch {
+	case errors.Is(err, duels.ErrUnknownSession):
+		return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "invalid duel session",
+		})
+	case errors.Is(err, duels.ErrNotAPlayer):
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid user",
+		})
+	case errors.Is(err, duels.ErrSessionClosed), errors.Is(err, duels.ErrNotStarted):
+		return ctx.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "duel session is not running",
+		})
+	case err != nil:
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to record the disconnect",
+		})
+	}
+
+	return ctx.JSON(fiber.Map{
+		"grace_period": duels.DISCONNECT_GRACE_PERIOD.Seconds(),
+	})
+}
+
+// ReconnectHandler is called by the nexuspool when a disconnected player comes back
+func ReconnectHandler(params PlayerConnectionParams, ctx *fiber.Ctx, cache *services.Cache) error {
+	user_id, err := services.StringToUUID(params.UserId)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid user",
+		})
+	}
+
+	if err := duels.Reconnect(cache, params.DuelSessionId, user_id); err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to record the reconnect",
+		})
+	}
+
+	return ctx.SendStatus(fiber.StatusOK)
+}
@@ -0,0 +1,195 @@
+package routes
+
+import (
+	"backend/lib/duels"
+	"backend/lib/rulesets"
+	"backend/lib/schema"
+	"backend/lib/server/middleware"
+	"backend/lib/services"
+	"context"
+	"time"
+
+	basepool "github.com/ciphrpool/base-pool/gen"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+type PracticeBot struct {
+	ID          string                 `json:"id"`
+	Name        string                 `json:"name"`
+	Description string                 `json:"description"`
+	Difficulty  services.BotDifficulty `json:"difficulty"`
+	Elo         uint                   `json:"elo"`
+}
+
+func ListPracticeBotsHandler(ctx *fiber.Ctx, cache *services.Cache) error {
+	bots, err := cache.ListBots()
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to list bots",
+		})
+	}
+
+	practice_bots := make([]PracticeBot, 0, len(bots))
+	for _, bot := range bots {
+		practice_bots = append(practice_bots, PracticeBot{
+			ID:          bot.ID,
+			Name:        bot.Name,
+			Description: bot.Description,
+			Difficulty:  bot.Difficulty,
+			Elo:         bot.Elo,
+		})
+	}
+	return ctx.JSON(fiber.Map{
+		"bots": practice_bots,
+	})
+}
+
+type StartPracticeData struct {
+	BotId  string `json:"bot_id"`
+	Preset string `json:"preset"`
+}
+
+func StartPracticeHandler(data StartPracticeData, ctx *fiber.Ctx, cache *services.Cache, db *services.Database) error {
+	query_ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	queries := basepool.New(db.Pool)
+
+	user_id, err := middleware.GetUserID(ctx)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "unknown user",
+		})
+	}
+
+	ruleset, err := rulesets.Preset(schema.DuelTypePractice, data.Preset)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "unknown ruleset preset",
+		})
+	}
+
+	bot, err := cache.GetBot(data.BotId)
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to get the bot",
+		})
+	}
+	if bot == nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "cannot find this bot",
+		})
+	}
+
+	player, err := queries.GetUserDuelSummaryDataById(query_ctx, user_id)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "cannot find this user",
+		})
+	}
+
+	session_id, err := duels.CreatePracticeSession(cache, services.DuelPlayerSummaryData{
+		PID:      player.ID,
+		Elo:      uint(player.Elo),
+		Tag:      player.Tag,
+		Username: player.Username,
+	}, *bot, ruleset)
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Cannot create duel session",
+		})
+	}
+
+	return ctx.JSON(fiber.Map{
+		"duel_session_id": session_id,
+		"duel_type":       schema.DuelTypePractice,
+		"ruleset":         ruleset,
+	})
+}
+
+type SetBotData struct {
+	Id          string                 `json:"id"`
+	Name        string                 `json:"name"`
+	Description string                 `json:"description"`
+	Difficulty  services.BotDifficulty `json:"difficulty"`
+	Tag         string                 `json:"tag"`
+}
+
+// SetBotHandler adds a house bot to the catalogue, or updates it when an id is given
+func SetBotHandler(data SetBotData, ctx *fiber.Ctx, cache *services.Cache, db *services.Database) error {
+	query_ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	queries := basepool.New(db.Pool)
+
+	if data.Name == "" || !data.Difficulty.IsValid() {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "a name and a valid difficulty are required",
+		})
+	}
+
+	// The bot plays with the active module of its account
+	account, err := queries.GetUserDuelSummaryDataByTag(query_ctx, data.Tag)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "cannot find the bot account",
+		})
+	}
+
+	if data.Id == "" {
+		data.Id = uuid.New().String()
+	}
+	bot := services.BotData{
+		ID:          data.Id,
+		Name:        data.Name,
+		Description: data.Description,
+		Difficulty:  data.Difficulty,
+		UserID:      account.ID,
+		Tag:         account.Tag,
+		Username:    account.Username,
+		Elo:         uint(account.Elo),
+	}
+	if err := services.UpsertBot(query_ctx, db.Pool, bot); err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to store the bot",
+		})
+	}
+	if err := cache.SetBot(bot); err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to store the bot",
+		})
+	}
+
+	return ctx.JSON(fiber.Map{
+		"bot": bot,
+	})
+}
+
+type RemoveBotData struct {
+	Id string `json:"id"`
+}
+
+func RemoveBotHandler(data RemoveBotData, ctx *fiber.Ctx, cache *services.Cache, db *services.Database) error {
+	query_ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	deleted, err := services.DeleteBot(query_ctx, db.Pool, data.Id)
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to remove the bot",
+		})
+	}
+	if !deleted {
+		return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "cannot find this bot",
+		})
+	}
+	if _, err := cache.DeleteBot(data.Id); err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to remove the bot",
+		})
+	}
+
+	return ctx.JSON(fiber.Map{
+		"message": "bot removed",
+	})
+}
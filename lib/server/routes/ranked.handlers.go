@@ -0,0 +1,94 @@
+package routes
+
+import (
+	"backend/lib/duels"
+	"backend/lib/seasons"
+	"backend/lib/server/middleware"
+	"backend/lib/services"
+	"context"
+	"time"
+
+	basepool "github.com/ciphrpool/base-pool/gen"
+	"github.com/gofiber/fiber/v2"
+)
+
+func JoinRankedQueueHandler(ctx *fiber.Ctx, cache *services.Cache, db *services.Database) error {
+	query_ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	queries := basepool.New(db.Pool)
+
+	user_id, err := middleware.GetUserID(ctx)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "unknown user",
+		})
+	}
+	season, err := seasons.Current(query_ctx, cache, db)
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "cannot join the ranked queue",
+		})
+	}
+	if season == nil {
+		return ctx.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+			"error": "ranked is closed until the next season starts",
+		})
+	}
+
+	duel_summary_data, err := queries.GetUserDuelSummaryDataById(query_ctx, user_id)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "cannot find this user",
+		})
+	}
+
+	err = cache.JoinRankedQueue(user_id, uint(duel_summary_data.Elo))
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "cannot join the ranked queue",
+		})
+	}
+	return ctx.SendStatus(fiber.StatusOK)
+}
+
+func LeaveRankedQueueHandler(ctx *fiber.Ctx, cache *services.Cache) error {
+	user_id, err := middleware.GetUserID(ctx)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "unknown user",
+		})
+	}
+
+	err = cache.LeaveRankedQueue(user_id)
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "cannot leave the ranked queue",
+		})
+	}
+	return ctx.SendStatus(fiber.StatusOK)
+}
+
+func GetRankedQueueStatusHandler(ctx *fiber.Ctx, cache *services.Cache) error {
+	user_id, err := middleware.GetUserID(ctx)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "unknown user",
+		})
+	}
+
+	entry, err := cache.GetRankedQueueEntry(user_id)
+	if err != nil {
+		return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+			"in_queue": false,
+		})
+	}
+	waited := time.Since(entry.JoinedAt)
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"in_queue":  true,
+		"elo":       entry.Elo,
+		"joined_at": entry.JoinedAt.UnixMilli(),
+		"waited":    waited.Milliseconds(),
+		"window":    duels.RankedWindow(waited),
+	})
+}
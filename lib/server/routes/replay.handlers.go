@@ -0,0 +1,115 @@
+package routes
+
+import (
+	"backend/lib/replays"
+	"backend/lib/server/middleware"
+	"backend/lib/services"
+	"backend/lib/vault"
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	basepool "github.com/ciphrpool/base-pool/gen"
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5"
+)
+
+type UploadReplayParams struct {
+	DuelSessionId string `query:"duel_session_id"`
+	Hmac          string `query:"hmac"`
+}
+
+func UploadReplayHandler(params UploadReplayParams, ctx *fiber.Ctx, cache *services.Cache, db *services.Database, vault *vault.VaultManager, store replays.Store) error {
+	query_ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if _, err := services.StringToUUID(params.DuelSessionId); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid duel session",
+		})
+	}
+
+	content := ctx.Body()
+	if err := replays.VerifyReplay(vault.NexusHMACKey(), params.DuelSessionId, content, params.Hmac); err != nil {
+		slog.Warn("security event: rejected duel replay", "error", err, "SessionID", params.DuelSessionId)
+		return ctx.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "invalid replay signature",
+		})
+	}
+
+	replay, err := replays.Upload(query_ctx, cache, db, store, params.DuelSessionId, content)
+	switch {
+	case errors.Is(err, replays.ErrNotCompressed), errors.Is(err, replays.ErrReplayTooLarge):
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	case errors.Is(err, replays.ErrUnknownSession):
+		return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "invalid duel session",
+		})
+	case err != nil:
+		slog.Error("failed to store duel replay", "error", err, "SessionID", params.DuelSessionId)
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to store replay",
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"replay": replay,
+	})
+}
+
+type GetDuelReplayParams struct {
+	DuelSessionId string `query:"duel_session_id"`
+}
+
+func GetDuelReplayHandler(params GetDuelReplayParams, ctx *fiber.Ctx, db *services.Database, store replays.Store) error {
+	query_ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	queries := basepool.New(db.Pool)
+
+	user_id, err := middleware.GetUserID(ctx)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "unknown user",
+		})
+	}
+
+	sessionID, err := services.StringToUUID(params.DuelSessionId)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid duel session",
+		})
+	}
+	// Only the players allowed to see the result can watch the replay
+	_, err = queries.GetDuelResult(query_ctx, basepool.GetDuelResultParams{
+		UserID:    user_id,
+		SessionID: sessionID,
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "invalid duel session",
+		})
+	} else if err != nil {
+		slog.Error("failed to get duel result", "error", err, "SessionID", params.DuelSessionId)
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to load replay",
+		})
+	}
+
+	replay, content, err := replays.Open(query_ctx, db, store, params.DuelSessionId)
+	if errors.Is(err, replays.ErrReplayNotFound) {
+		return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "no replay for this duel session",
+		})
+	} else if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to load replay",
+		})
+	}
+
+	ctx.Set(fiber.HeaderContentType, "application/gzip")
+	ctx.Set(fiber.HeaderContentDisposition, `attachment; filename="`+params.DuelSessionId+`.replay.gz"`)
+	return ctx.Status(fiber.StatusOK).SendStream(content, int(replay.Size))
+}
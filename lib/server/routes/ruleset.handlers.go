@@ -0,0 +1,27 @@
+package routes
+
+import (
+	"backend/lib/rulesets"
+
+	basepool "github.com/ciphrpool/base-pool/gen"
+	"github.com/gofiber/fiber/v2"
+)
+
+type ListRulesetsParams struct {
+	DuelType string `query:"duel_type"`
+}
+
+// ListRulesetsHandler returns the ruleset presets available for a duel type
+func ListRulesetsHandler(params ListRulesetsParams, ctx *fiber.Ctx) error {
+	presets := rulesets.Presets(basepool.DuelType(params.DuelType))
+	if len(presets) == 0 {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "unknown duel type",
+		})
+	}
+	return ctx.JSON(fiber.Map{
+		"duel_type": params.DuelType,
+		"default":   rulesets.DEFAULT_PRESET,
+		"rulesets":  presets,
+	})
+}
@@ -0,0 +1,125 @@
+package routes
+
+import (
+	"backend/lib/seasons"
+	"backend/lib/services"
+	"context"
+	"errors"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func ListSeasonsHandler(ctx *fiber.Ctx, db *services.Database) error {
+	query_ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	season_list, err := services.ListSeasons(query_ctx, db.Pool)
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to list seasons",
+		})
+	}
+
+	return ctx.JSON(fiber.Map{
+		"seasons": season_list,
+	})
+}
+
+type GetSeasonStandingsParams struct {
+	SeasonId string `query:"season_id"`
+	Cursor   int64  `query:"cursor"`
+	Limit    int64  `query:"limit"`
+}
+
+func GetSeasonStandingsHandler(params GetSeasonStandingsParams, ctx *fiber.Ctx, db *services.Database) error {
+	query_ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if params.SeasonId == "" {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "season_id is required",
+		})
+	}
+	if params.Cursor < 0 {
+		params.Cursor = 0
+	}
+	if params.Limit <= 0 || params.Limit > LEADERBOARD_MAX_LIMIT {
+		params.Limit = LEADERBOARD_DEFAULT_LIMIT
+	}
+
+	season, err := services.GetSeason(query_ctx, db.Pool, params.SeasonId)
+	if errors.Is(err, services.ErrSeasonNotFound) {
+		return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "season not found",
+		})
+	} else if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to get season",
+		})
+	}
+	if !season.Archived {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "season standings are not archived yet",
+		})
+	}
+
+	standings, next_cursor, err := services.GetSeasonStandings(query_ctx, db.Pool, season.ID, params.Cursor, params.Limit)
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to get season standings",
+		})
+	}
+
+	return ctx.JSON(fiber.Map{
+		"season":      season,
+		"standings":   standings,
+		"next_cursor": next_cursor,
+	})
+}
+
+type StartSeasonData struct {
+	Name    string                  `json:"name"`
+	EndAt   time.Time               `json:"end_at"`
+	Rewards []services.SeasonReward `json:"rewards"`
+}
+
+func StartSeasonHandler(data StartSeasonData, ctx *fiber.Ctx, cache *services.Cache, db *services.Database) error {
+	query_ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	season, err := seasons.Start(query_ctx, cache, db, data.Name, data.EndAt, data.Rewards)
+	if errors.Is(err, seasons.ErrSeasonActive) || errors.Is(err, seasons.ErrInvalidSeasonEnd) {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	} else if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to start season",
+		})
+	}
+
+	return ctx.JSON(fiber.Map{
+		"season": season,
+	})
+}
+
+func EndSeasonHandler(ctx *fiber.Ctx, cache *services.Cache, db *services.Database) error {
+	query_ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	err := seasons.End(query_ctx, cache, db)
+	if errors.Is(err, seasons.ErrNoActiveSeason) {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	} else if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to end season",
+		})
+	}
+
+	return ctx.JSON(fiber.Map{
+		"message": "season ended",
+	})
+}
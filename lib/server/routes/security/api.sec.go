@@ -27,7 +27,7 @@ func InitApiSecurityHandler(ctx *fiber.Ctx, manager *v.VaultManager, on_complete
 
 	manager.Api.SetToken(data.VaultApiToken)
 
-	if err := manager.LoadApiKeys("SERVICES_INIT_KEY", "NEXUSPOOL_INIT_KEY", "NEXUSPOOL_ADM_KEY", "JWT_KEY"); err != nil {
+	if err := manager.LoadApiKeys("SERVICES_INIT_KEY", "NEXUSPOOL_INIT_KEY", "NEXUSPOOL_ADM_KEY", "MCS_ADM_KEY", "JWT_KEY"); err != nil {
 		slog.Error("api could not be loaded", "error", err)
 		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error": "api could not be loaded",
@@ -0,0 +1,246 @@
+package routes
+
+import (
+	"backend/lib/server/middleware"
+	"backend/lib/server/routes/security"
+	"backend/lib/services"
+	"backend/lib/vault"
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	basepool "github.com/ciphrpool/base-pool/gen"
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// canSpectate checks that both players of a duel accept the spectator
+func canSpectate(ctx context.Context, queries *basepool.Queries, db *services.Database, spectator_id pgtype.UUID, session_data services.DuelSessionData) (bool, error) {
+	for _, player_id := range []pgtype.UUID{session_data.P1.PID, session_data.P2.PID} {
+		if player_id == spectator_id {
+			// Players join their duel through the prepare route
+			return false, nil
+		}
+
+		privacy, err := services.GetSpectatePrivacy(ctx, db.Pool, player_id)
+		if err != nil {
+			return false, err
+		}
+		switch privacy {
+		case services.SpectateEveryone:
+			continue
+		case services.SpectateFriends:
+			friends, err := queries.GetAllFriends(ctx, player_id)
+			if err != nil {
+				return false, err
+			}
+			is_friend := false
+			for _, friend := range friends {
+				if friend.ID == spectator_id {
+					is_friend = true
+					break
+				}
+			}
+			if !is_friend {
+				return false, nil
+			}
+		default:
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+type GetLiveDuelParams struct {
+	UserTag string `query:"user_tag"`
+}
+
+func GetLiveDuelHandler(params GetLiveDuelParams, ctx *fiber.Ctx, cache *services.Cache, db *services.Database) error {
+	query_ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	queries := basepool.New(db.Pool)
+
+	user_id, err := middleware.GetUserID(ctx)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "unknown user",
+		})
+	}
+	player, err := queries.GetUserIDByTag(query_ctx, params.UserTag)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "cannot find this user",
+		})
+	}
+
+	duel_session_id, err := cache.GetPlayerDuelSession(player.ID)
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to get the duel of this user",
+		})
+	}
+	if duel_session_id == "" {
+		return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+			"duel": nil,
+		})
+	}
+	session_data, err := cache.GetDuelSession(duel_session_id)
+	if err != nil || session_data.IsClosed() {
+		return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+			"duel": nil,
+		})
+	}
+
+	allowed, err := canSpectate(query_ctx, queries, db, user_id, session_data)
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to check spectate privacy",
+		})
+	}
+	if !allowed {
+		return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+			"duel": nil,
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"duel": fiber.Map{
+			"duel_session_id": duel_session_id,
+			"duel_type":       session_data.DuelType,
+			"p1": services.DuelPlayerSummaryDataExtern{
+				Elo:      session_data.P1.Elo,
+				Tag:      session_data.P1.Tag,
+				Username: session_data.P1.Username,
+			},
+			"p2": services.DuelPlayerSummaryDataExtern{
+				Elo:      session_data.P2.Elo,
+				Tag:      session_data.P2.Tag,
+				Username: session_data.P2.Username,
+			},
+		},
+	})
+}
+
+type SpectatePrepareParams struct {
+	DuelSessionId string `query:"duel_session_id"`
+}
+
+func SpectatePrepareHandler(params SpectatePrepareParams, ctx *fiber.Ctx, cache *services.Cache, db *services.Database, vault *vault.VaultManager) error {
+	query_ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	queries := basepool.New(db.Pool)
+
+	session_data, err := cache.GetDuelSession(params.DuelSessionId)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid duel session",
+		})
+	}
+	if session_data.IsClosed() {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "duel session is already finished",
+		})
+	}
+
+	user_id, err := middleware.GetUserID(ctx)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "unknown user",
+		})
+	}
+
+	allowed, err := canSpectate(query_ctx, queries, db, user_id, session_data)
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to check spectate privacy",
+		})
+	}
+	if !allowed {
+		return ctx.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "you are not allowed to spectate this duel",
+		})
+	}
+
+	nexuspool, err := cache.SearchAliveNexusPool()
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "No running nexuspool",
+		})
+	}
+
+	// Spectators only receive the events of the duel, they are never given a websocket
+	spectator_session_json, err := json.Marshal(fiber.Map{
+		"session_id": params.DuelSessionId,
+		"user_id":    user_id,
+		"role":       "spectator",
+		"read_only":  true,
+	})
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to marshal spectator data",
+		})
+	}
+	spectator_crypted_session_payload, err := security.EncryptAESUrlSafe(string(spectator_session_json[:]), vault.OpenNexusAESKey)
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "cannot spectate the game",
+		})
+	}
+
+	return ctx.JSON(fiber.Map{
+		"sse_url":                   fmt.Sprintf("%s/sse/duel/", nexuspool.Url),
+		"encrypted_session_context": spectator_crypted_session_payload,
+	})
+}
+
+func GetSpectatePrivacyHandler(ctx *fiber.Ctx, db *services.Database) error {
+	query_ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	user_id, err := middleware.GetUserID(ctx)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "unknown user",
+		})
+	}
+
+	privacy, err := services.GetSpectatePrivacy(query_ctx, db.Pool, user_id)
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to get spectate privacy",
+		})
+	}
+	return ctx.JSON(fiber.Map{
+		"privacy": privacy,
+	})
+}
+
+type SetSpectatePrivacyData struct {
+	Privacy string `json:"privacy"`
+}
+
+func SetSpectatePrivacyHandler(data SetSpectatePrivacyData, ctx *fiber.Ctx, db *services.Database) error {
+	query_ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	user_id, err := middleware.GetUserID(ctx)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "unknown user",
+		})
+	}
+
+	privacy := services.SpectatePrivacy(data.Privacy)
+	if !privacy.IsValid() {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "privacy must be one of everyone, friends or nobody",
+		})
+	}
+	if err := services.SetSpectatePrivacy(query_ctx, db.Pool, user_id, privacy); err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to set spectate privacy",
+		})
+	}
+	return ctx.SendStatus(fiber.StatusOK)
+}
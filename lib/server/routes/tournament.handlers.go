@@ -0,0 +1,225 @@
+package routes
+
+import (
+	"backend/lib/notifications"
+	"backend/lib/server/middleware"
+	"backend/lib/services"
+	"backend/lib/tournaments"
+	"context"
+	"errors"
+	"time"
+
+	basepool "github.com/ciphrpool/base-pool/gen"
+	"github.com/gofiber/fiber/v2"
+)
+
+func tournamentErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, tournaments.ErrNotHost):
+		return fiber.StatusUnauthorized
+	case errors.Is(err, services.ErrTournamentNotFound):
+		return fiber.StatusNotFound
+	case errors.Is(err, tournaments.ErrInvalidStatus),
+		errors.Is(err, tournaments.ErrInvalidFormat),
+		errors.Is(err, tournaments.ErrTournamentFull),
+		errors.Is(err, tournaments.ErrAlreadyRegistered),
+		errors.Is(err, tournaments.ErrNotRegistered),
+		errors.Is(err, tournaments.ErrNotEnoughPlayers):
+		return fiber.StatusBadRequest
+	default:
+		return fiber.StatusInternalServerError
+	}
+}
+
+type CreateTournamentData struct {
+	Name        string `json:"name"`
+	Format      string `json:"format"`
+	MaxPlayers  int    `json:"max_players"`
+	SwissRounds int    `json:"swiss_rounds"`
+}
+
+func CreateTournamentHandler(data CreateTournamentData, ctx *fiber.Ctx, db *services.Database) error {
+	query_ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	user_id, err := middleware.GetUserID(ctx)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "unknown user",
+		})
+	}
+	if data.Name == "" {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "name is required",
+		})
+	}
+
+	tournament_id, err := tournaments.Create(query_ctx, db, user_id, tournaments.CreateTournamentParams{
+		Name:        data.Name,
+		Format:      services.TournamentFormat(data.Format),
+		MaxPlayers:  data.MaxPlayers,
+		SwissRounds: data.SwissRounds,
+	})
+	if err != nil {
+		return ctx.Status(tournamentErrorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"tournament_id": tournament_id,
+	})
+}
+
+type TournamentActionData struct {
+	TournamentId string `json:"tournament_id"`
+}
+
+func RegisterTournamentHandler(data TournamentActionData, ctx *fiber.Ctx, db *services.Database) error {
+	query_ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	queries := basepool.New(db.Pool)
+
+	user_id, err := middleware.GetUserID(ctx)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "unknown user",
+		})
+	}
+	duel_summary_data, err := queries.GetUserDuelSummaryDataById(query_ctx, user_id)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "cannot find this user",
+		})
+	}
+
+	err = tournaments.Register(query_ctx, db, data.TournamentId, services.TournamentPlayerData{
+		PID:      duel_summary_data.ID,
+		Elo:      uint(duel_summary_data.Elo),
+		Tag:      duel_summary_data.Tag,
+		Username: duel_summary_data.Username,
+	})
+	if err != nil {
+		return ctx.Status(tournamentErrorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	return ctx.SendStatus(fiber.StatusOK)
+}
+
+func OpenTournamentCheckInHandler(data TournamentActionData, ctx *fiber.Ctx, db *services.Database, notify *notifications.NotificationService) error {
+	user_id, err := middleware.GetUserID(ctx)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "unknown user",
+		})
+	}
+
+	err = tournaments.OpenCheckIn(ctx.Context(), db, notify, data.TournamentId, user_id)
+	if err != nil {
+		return ctx.Status(tournamentErrorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	return ctx.SendStatus(fiber.StatusOK)
+}
+
+func CheckInTournamentHandler(data TournamentActionData, ctx *fiber.Ctx, db *services.Database) error {
+	user_id, err := middleware.GetUserID(ctx)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "unknown user",
+		})
+	}
+
+	err = tournaments.CheckIn(ctx.Context(), db, data.TournamentId, user_id)
+	if err != nil {
+		return ctx.Status(tournamentErrorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	return ctx.SendStatus(fiber.StatusOK)
+}
+
+func StartTournamentHandler(data TournamentActionData, ctx *fiber.Ctx, cache *services.Cache, db *services.Database, notify *notifications.NotificationService) error {
+	user_id, err := middleware.GetUserID(ctx)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "unknown user",
+		})
+	}
+
+	err = tournaments.Start(ctx.Context(), cache, db, notify, data.TournamentId, user_id)
+	if err != nil {
+		return ctx.Status(tournamentErrorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	return ctx.SendStatus(fiber.StatusOK)
+}
+
+type ListTournamentsParams struct {
+	Status string `query:"status"`
+	Offset int    `query:"offset"`
+	Limit  int    `query:"limit"`
+}
+
+func ListTournamentsHandler(params ListTournamentsParams, ctx *fiber.Ctx, db *services.Database) error {
+	query_ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if params.Offset < 0 {
+		params.Offset = 0
+	}
+	if params.Limit <= 0 || params.Limit > 100 {
+		params.Limit = 20 // Default limit
+	}
+
+	tournament_list, total, err := services.ListTournaments(query_ctx, db.Pool, services.TournamentStatus(params.Status), params.Offset, params.Limit)
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to fetch tournaments",
+		})
+	}
+
+	summaries := make([]fiber.Map, 0, len(tournament_list))
+	for _, tournament := range tournament_list {
+		summaries = append(summaries, fiber.Map{
+			"id":          tournament.ID,
+			"name":        tournament.Name,
+			"format":      tournament.Format,
+			"status":      tournament.Status,
+			"players":     len(tournament.Players),
+			"max_players": tournament.MaxPlayers,
+			"created_at":  tournament.CreatedAt,
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"tournaments": summaries,
+		"total":       total,
+		"offset":      params.Offset,
+		"limit":       params.Limit,
+	})
+}
+
+type GetTournamentBracketParams struct {
+	TournamentId string `query:"tournament_id"`
+}
+
+func GetTournamentBracketHandler(params GetTournamentBracketParams, ctx *fiber.Ctx, db *services.Database) error {
+	query_ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	tournament, err := services.GetTournament(query_ctx, db.Pool, params.TournamentId)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid tournament",
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"tournament": tournament,
+		"standings":  tournaments.Standings(tournament.Players),
+	})
+}
@@ -3,6 +3,7 @@ package routes
 import (
 	"backend/lib/server/middleware"
 	"backend/lib/services"
+	"backend/lib/stats"
 	"context"
 	"time"
 
@@ -100,3 +101,31 @@ func GetSelfHandler(params GetSelfParams, ctx *fiber.Ctx, db *services.Database)
 		"user": user,
 	})
 }
+
+type GetUserStatsParams struct {
+	Tag string `query:"tag"`
+}
+
+func GetUserStatsHandler(params GetUserStatsParams, ctx *fiber.Ctx, cache *services.Cache, db *services.Database) error {
+	query_ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	queries := basepool.New(db.Pool)
+
+	user, err := queries.GetUserIDByTag(query_ctx, params.Tag)
+	if err != nil {
+		return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "user not found",
+		})
+	}
+
+	user_stats, err := stats.Get(query_ctx, cache, db, user.ID)
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to get user stats",
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"stats": user_stats,
+	})
+}
@@ -5,8 +5,11 @@ import (
 	"backend/lib/duels"
 	"backend/lib/maintenance"
 	"backend/lib/notifications"
+	"backend/lib/replays"
+	"backend/lib/seasons"
 	"backend/lib/server/middleware"
 	"backend/lib/services"
+	"backend/lib/tournaments"
 	"backend/lib/vault"
 	"context"
 	"log/slog"
@@ -21,15 +24,21 @@ import (
 
 type MaintenanceServer struct {
 	*fiber.App
-	Db              services.Database
-	Cache           services.Cache
-	Notifications   *notifications.NotificationService
-	Sessions        *session.Store
-	VaultManager    vault.VaultManager
-	SecurityManager maintenance.SecurityManager
-	StateMachine    maintenance.StateMachine
-	AuthService     *authentication.AuthService
-	DuelSupervisor  *duels.DuelSupervisor
+	Db                   services.Database
+	Cache                services.Cache
+	Notifications        *notifications.NotificationService
+	Sessions             *session.Store
+	VaultManager         vault.VaultManager
+	SecurityManager      maintenance.SecurityManager
+	StateMachine         maintenance.StateMachine
+	AuthService          *authentication.AuthService
+	DuelSupervisor       *duels.DuelSupervisor
+	Matchmaker           *duels.Matchmaker
+	SessionSweeper       *duels.SessionSweeper
+	SeasonScheduler      *seasons.Scheduler
+	ReplayStore          replays.Store
+	ReplayJanitor        *replays.Janitor
+	TournamentSupervisor *tournaments.Supervisor
 }
 
 func New() (*MaintenanceServer, error) {
@@ -62,14 +71,20 @@ func New() (*MaintenanceServer, error) {
 	}
 
 	server := MaintenanceServer{
-		App:             fiber.New(),
-		Db:              services.DefaultDatabase(),
-		Cache:           cache,
-		Notifications:   notifications,
-		VaultManager:    vault_manager,
-		SecurityManager: security_manager,
-		StateMachine:    maintenance.NewStateMachine(),
-		DuelSupervisor:  duel_supervisor,
+		App:                  fiber.New(),
+		Db:                   services.DefaultDatabase(),
+		Cache:                cache,
+		Notifications:        notifications,
+		VaultManager:         vault_manager,
+		SecurityManager:      security_manager,
+		StateMachine:         maintenance.NewStateMachine(),
+		DuelSupervisor:       duel_supervisor,
+		Matchmaker:           duels.NewMatchmaker(),
+		SessionSweeper:       duels.NewSessionSweeper(),
+		SeasonScheduler:      seasons.NewScheduler(),
+		ReplayStore:          replays.NewLocalStore(),
+		ReplayJanitor:        replays.NewJanitor(),
+		TournamentSupervisor: tournaments.NewSupervisor(),
 	}
 
 	return &server, nil
@@ -146,6 +161,16 @@ func (server *MaintenanceServer) Start() {
 				slog.Error("Db connection failed", "error", err)
 				return
 			}
+			if err := server.Db.Migrate(context.Background()); err != nil {
+				// raise fault
+				slog.Error("Db migration failed", "error", err)
+				return
+			}
+			if err := duels.LoadBots(context.Background(), &server.Cache, &server.Db); err != nil {
+				// raise fault
+				slog.Error("Bot catalogue could not be loaded", "error", err)
+				return
+			}
 
 			auth_config, err := authentication.BuildAuthConfig(&server.VaultManager)
 			if err != nil {
@@ -167,12 +192,42 @@ func (server *MaintenanceServer) Start() {
 				return
 			}
 
-			if err := server.DuelSupervisor.Start(context.Background(), &server.Cache, &server.Db); err != nil {
+			if err := server.DuelSupervisor.Start(context.Background(), &server.Cache, &server.Db, &server.VaultManager, server.Notifications); err != nil {
 				// raise fault
 				slog.Error("DuelSupervisor could not start", "error", err)
 				return
 			}
 
+			if err := server.Matchmaker.Start(context.Background(), &server.Cache, &server.Db, server.Notifications); err != nil {
+				// raise fault
+				slog.Error("Matchmaker could not start", "error", err)
+				return
+			}
+
+			if err := server.SessionSweeper.Start(context.Background(), &server.Cache, &server.Db, &server.VaultManager, server.Notifications); err != nil {
+				// raise fault
+				slog.Error("SessionSweeper could not start", "error", err)
+				return
+			}
+
+			if err := server.SeasonScheduler.Start(context.Background(), &server.Cache, &server.Db); err != nil {
+				// raise fault
+				slog.Error("SeasonScheduler could not start", "error", err)
+				return
+			}
+
+			if err := server.ReplayJanitor.Start(context.Background(), &server.Db, server.ReplayStore); err != nil {
+				// raise fault
+				slog.Error("ReplayJanitor could not start", "error", err)
+				return
+			}
+
+			if err := server.TournamentSupervisor.Start(context.Background(), &server.Cache, &server.Db, server.Notifications); err != nil {
+				// raise fault
+				slog.Error("TournamentSupervisor could not start", "error", err)
+				return
+			}
+
 			server.StateMachine.To(maintenance.MODE_INIT, maintenance.STATE_CONFIGURING, maintenance.SUBSTATE_CONFIGURING_SECURITY)
 		})
 
@@ -0,0 +1,111 @@
+package server
+
+import (
+	m "backend/lib/maintenance"
+	"backend/lib/server/middleware"
+	"backend/lib/server/routes"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func (server *MaintenanceServer) RegisterTournamentRoutes() {
+	tournament_group := server.App.Group("/tournament")
+	tournament_group.Use(
+		middleware.OnMSS(m.MODE_OPERATIONAL, m.STATE_RUNNING, m.SUBSTATE_SAFE),
+	)
+	tournament_group.Use(middleware.Protected(&server.AuthService))
+
+	tournament_group.Get("/list",
+		func(c *fiber.Ctx) error {
+			var params routes.ListTournamentsParams
+			if err := c.QueryParser(&params); err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error": "invalid query parameters",
+				})
+			}
+			return routes.ListTournamentsHandler(params, c, &server.Db)
+		},
+	)
+
+	tournament_group.Get("/bracket",
+		func(c *fiber.Ctx) error {
+			var params routes.GetTournamentBracketParams
+			if err := c.QueryParser(&params); err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error": "invalid query parameters",
+				})
+			}
+			return routes.GetTournamentBracketHandler(params, c, &server.Db)
+		},
+	)
+
+	tournament_group.Post("/create",
+		middleware.RequireSession(&server.AuthService, server.Sessions),
+		func(c *fiber.Ctx) error {
+			var data routes.CreateTournamentData
+
+			if err := c.BodyParser(&data); err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error": "invalid request body",
+				})
+			}
+			return routes.CreateTournamentHandler(data, c, &server.Db)
+		},
+	)
+
+	tournament_group.Post("/register",
+		middleware.RequireSession(&server.AuthService, server.Sessions),
+		func(c *fiber.Ctx) error {
+			var data routes.TournamentActionData
+
+			if err := c.BodyParser(&data); err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error": "invalid request body",
+				})
+			}
+			return routes.RegisterTournamentHandler(data, c, &server.Db)
+		},
+	)
+
+	tournament_group.Post("/check_in/open",
+		middleware.RequireSession(&server.AuthService, server.Sessions),
+		func(c *fiber.Ctx) error {
+			var data routes.TournamentActionData
+
+			if err := c.BodyParser(&data); err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error": "invalid request body",
+				})
+			}
+			return routes.OpenTournamentCheckInHandler(data, c, &server.Db, server.Notifications)
+		},
+	)
+
+	tournament_group.Post("/check_in",
+		middleware.RequireSession(&server.AuthService, server.Sessions),
+		func(c *fiber.Ctx) error {
+			var data routes.TournamentActionData
+
+			if err := c.BodyParser(&data); err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error": "invalid request body",
+				})
+			}
+			return routes.CheckInTournamentHandler(data, c, &server.Db)
+		},
+	)
+
+	tournament_group.Post("/start",
+		middleware.RequireSession(&server.AuthService, server.Sessions),
+		func(c *fiber.Ctx) error {
+			var data routes.TournamentActionData
+
+			if err := c.BodyParser(&data); err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error": "invalid request body",
+				})
+			}
+			return routes.StartTournamentHandler(data, c, &server.Cache, &server.Db, server.Notifications)
+		},
+	)
+}
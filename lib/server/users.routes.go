@@ -41,6 +41,17 @@ func (server *MaintenanceServer) RegisterUserRoutes() {
 			return routes.GetUserByTagHandler(params, c, &server.Db)
 		},
 	)
+	public_group.Get("/stats",
+		func(c *fiber.Ctx) error {
+			var params routes.GetUserStatsParams
+			if err := c.QueryParser(&params); err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error": "invalid query parameters",
+				})
+			}
+			return routes.GetUserStatsHandler(params, c, &server.Cache, &server.Db)
+		},
+	)
 
 	private_group.Get("/me",
 		func(c *fiber.Ctx) error {
@@ -0,0 +1,69 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const ABORTED_DUELS_INDEX_KEY = "duel:aborted"
+
+// Aborted duels are kept long enough to investigate unreliable nexuspools or players
+const ABORTED_DUEL_TTL = 30 * 24 * time.Hour
+
+type AbortedDuelData struct {
+	SessionID  string            `json:"session_id"`
+	Session    DuelSessionData   `json:"session"`
+	LastStatus DuelSessionStatus `json:"last_status"`
+	Reason     string            `json:"reason"`
+	AbortedAt  time.Time         `json:"aborted_at"`
+}
+
+func (cache *Cache) AddAbortedDuel(aborted AbortedDuelData) error {
+	ctx := context.Background()
+
+	aborted_json, err := json.Marshal(aborted)
+	if err != nil {
+		return fmt.Errorf("failed to marshal aborted duel: %w", err)
+	}
+
+	pipe := cache.Db.TxPipeline()
+	pipe.Set(ctx, fmt.Sprintf("duel:aborted:%s", aborted.SessionID), aborted_json, ABORTED_DUEL_TTL)
+	pipe.ZAdd(ctx, ABORTED_DUELS_INDEX_KEY, redis.Z{Score: float64(aborted.AbortedAt.UnixMilli()), Member: aborted.SessionID})
+	pipe.ZRemRangeByScore(ctx, ABORTED_DUELS_INDEX_KEY, "-inf", fmt.Sprintf("%d", aborted.AbortedAt.Add(-ABORTED_DUEL_TTL).UnixMilli()))
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to store aborted duel: %w", err)
+	}
+	return nil
+}
+
+// ListAbortedDuels returns the aborted duels from the most recent one
+func (cache *Cache) ListAbortedDuels(offset int, limit int) ([]AbortedDuelData, int64, error) {
+	ctx := context.Background()
+
+	total, err := cache.Db.ZCard(ctx, ABORTED_DUELS_INDEX_KEY).Result()
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to count aborted duels: %w", err)
+	}
+	ids, err := cache.Db.ZRevRange(ctx, ABORTED_DUELS_INDEX_KEY, int64(offset), int64(offset+limit-1)).Result()
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list aborted duels: %w", err)
+	}
+
+	aborted_duels := make([]AbortedDuelData, 0, len(ids))
+	for _, id := range ids {
+		aborted_json, err := cache.Db.Get(ctx, fmt.Sprintf("duel:aborted:%s", id)).Result()
+		if err != nil {
+			continue // Skip if we can't get this aborted duel
+		}
+		var aborted AbortedDuelData
+		if err := json.Unmarshal([]byte(aborted_json), &aborted); err != nil {
+			continue
+		}
+		aborted_duels = append(aborted_duels, aborted)
+	}
+	return aborted_duels, total, nil
+}
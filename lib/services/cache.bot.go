@@ -0,0 +1,130 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/redis/go-redis/v9"
+)
+
+type BotDifficulty string
+
+const (
+	BotEasy   BotDifficulty = "easy"
+	BotMedium BotDifficulty = "medium"
+	BotHard   BotDifficulty = "hard"
+)
+
+// The catalogue is stored in the database and rebuilt in this hash on startup
+const BOTS_KEY = "duel:bots"
+
+var botDifficultyOrder = map[BotDifficulty]int{
+	BotEasy:   0,
+	BotMedium: 1,
+	BotHard:   2,
+}
+
+func (difficulty BotDifficulty) IsValid() bool {
+	_, ok := botDifficultyOrder[difficulty]
+	return ok
+}
+
+// BotData is a house bot, a server owned account whose active module plays the practice duels
+type BotData struct {
+	ID          string        `json:"id"`
+	Name        string        `json:"name"`
+	Description string        `json:"description"`
+	Difficulty  BotDifficulty `json:"difficulty"`
+	UserID      pgtype.UUID   `json:"user_id"`
+	Tag         string        `json:"tag"`
+	Username    string        `json:"username"`
+	Elo         uint          `json:"elo"`
+}
+
+func (cache *Cache) SetBot(bot BotData) error {
+	ctx := context.Background()
+
+	bot_json, err := json.Marshal(bot)
+	if err != nil {
+		return fmt.Errorf("failed to marshal bot: %w", err)
+	}
+	if err := cache.Db.HSet(ctx, BOTS_KEY, bot.ID, bot_json).Err(); err != nil {
+		return fmt.Errorf("failed to store bot: %w", err)
+	}
+	return nil
+}
+
+// GetBot returns a house bot, nil if it does not exist
+func (cache *Cache) GetBot(bot_id string) (*BotData, error) {
+	ctx := context.Background()
+
+	bot_json, err := cache.Db.HGet(ctx, BOTS_KEY, bot_id).Result()
+	if err == redis.Nil {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get bot: %w", err)
+	}
+	var bot BotData
+	if err := json.Unmarshal([]byte(bot_json), &bot); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal bot: %w", err)
+	}
+	return &bot, nil
+}
+
+// ListBots returns the house bots from the easiest one
+func (cache *Cache) ListBots() ([]BotData, error) {
+	ctx := context.Background()
+
+	values, err := cache.Db.HGetAll(ctx, BOTS_KEY).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list bots: %w", err)
+	}
+
+	bots := make([]BotData, 0, len(values))
+	for _, bot_json := range values {
+		var bot BotData
+		if err := json.Unmarshal([]byte(bot_json), &bot); err != nil {
+			continue
+		}
+		bots = append(bots, bot)
+	}
+	sort.Slice(bots, func(i, j int) bool {
+		if bots[i].Difficulty != bots[j].Difficulty {
+			return botDifficultyOrder[bots[i].Difficulty] < botDifficultyOrder[bots[j].Difficulty]
+		}
+		return bots[i].Name < bots[j].Name
+	})
+	return bots, nil
+}
+
+// ReplaceBots rebuilds the cached catalogue from the given bots
+func (cache *Cache) ReplaceBots(bots []BotData) error {
+	ctx := context.Background()
+
+	pipe := cache.Db.TxPipeline()
+	pipe.Del(ctx, BOTS_KEY)
+	for _, bot := range bots {
+		bot_json, err := json.Marshal(bot)
+		if err != nil {
+			return fmt.Errorf("failed to marshal bot: %w", err)
+		}
+		pipe.HSet(ctx, BOTS_KEY, bot.ID, bot_json)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to rebuild bots: %w", err)
+	}
+	return nil
+}
+
+func (cache *Cache) DeleteBot(bot_id string) (bool, error) {
+	ctx := context.Background()
+
+	deleted, err := cache.Db.HDel(ctx, BOTS_KEY, bot_id).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to delete bot: %w", err)
+	}
+	return deleted > 0, nil
+}
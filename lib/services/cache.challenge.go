@@ -0,0 +1,160 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/redis/go-redis/v9"
+)
+
+const CHALLENGES_INDEX_KEY = "duel:challenges"
+
+// A challenge outlives its waiting room so that its expiry can still be notified
+const CHALLENGE_RETENTION = time.Hour
+
+type ChallengePlayerData struct {
+	PID      pgtype.UUID `json:"pid"`
+	Tag      string      `json:"tag"`
+	Username string      `json:"username"`
+}
+
+// ChallengeData describes the friendly challenge pending in a waiting room
+type ChallengeData struct {
+	WaitingRoomID     string              `json:"waiting_room_id"`
+	Challenger        ChallengePlayerData `json:"challenger"`
+	Opponent          ChallengePlayerData `json:"opponent"`
+	PreviousSessionID string              `json:"previous_session_id,omitempty"`
+	CreatedAt         time.Time           `json:"created_at"`
+	ExpiresAt         time.Time           `json:"expires_at"`
+}
+
+func challengeKey(waiting_room_id string) string {
+	return fmt.Sprintf("duel:challenge:%s", waiting_room_id)
+}
+
+func outgoingChallengesKey(user_id pgtype.UUID) string {
+	return fmt.Sprintf("duel:challenges:outgoing:%s", UUIDToString(user_id))
+}
+
+func incomingChallengesKey(user_id pgtype.UUID) string {
+	return fmt.Sprintf("duel:challenges:incoming:%s", UUIDToString(user_id))
+}
+
+// AddChallenge records the challenge of a new waiting room, a challenge sent again is kept as is
+func (cache *Cache) AddChallenge(challenge ChallengeData) error {
+	ctx := context.Background()
+
+	challenge_json, err := json.Marshal(challenge)
+	if err != nil {
+		return fmt.Errorf("failed to marshal challenge: %w", err)
+	}
+
+	expires_at := redis.Z{Score: float64(challenge.ExpiresAt.UnixMilli()), Member: challenge.WaitingRoomID}
+	pipe := cache.Db.TxPipeline()
+	pipe.SetNX(ctx, challengeKey(challenge.WaitingRoomID), challenge_json, WAITING_ROOM_TTL+CHALLENGE_RETENTION)
+	pipe.ZAddNX(ctx, CHALLENGES_INDEX_KEY, expires_at)
+	pipe.ZAddNX(ctx, outgoingChallengesKey(challenge.Challenger.PID), expires_at)
+	pipe.ZAddNX(ctx, incomingChallengesKey(challenge.Opponent.PID), expires_at)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to store challenge: %w", err)
+	}
+	return nil
+}
+
+// GetChallenge returns the challenge of a waiting room, nil if there is none
+func (cache *Cache) GetChallenge(waiting_room_id string) (*ChallengeData, error) {
+	ctx := context.Background()
+
+	challenge_json, err := cache.Db.Get(ctx, challengeKey(waiting_room_id)).Result()
+	if err == redis.Nil {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get challenge: %w", err)
+	}
+	var challenge ChallengeData
+	if err := json.Unmarshal([]byte(challenge_json), &challenge); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal challenge: %w", err)
+	}
+	return &challenge, nil
+}
+
+// removeChallenge queues the removal of a challenge from every index
+func removeChallenge(ctx context.Context, pipe redis.Pipeliner, waiting_room_id string, challenger_id pgtype.UUID, opponent_id pgtype.UUID) {
+	pipe.Del(ctx, challengeKey(waiting_room_id))
+	pipe.ZRem(ctx, CHALLENGES_INDEX_KEY, waiting_room_id)
+	pipe.ZRem(ctx, outgoingChallengesKey(challenger_id), waiting_room_id)
+	pipe.ZRem(ctx, incomingChallengesKey(opponent_id), waiting_room_id)
+}
+
+// ListPendingChallenges returns the challenges sent and received by a user which have not expired yet
+func (cache *Cache) ListPendingChallenges(user_id pgtype.UUID) (incoming []ChallengeData, outgoing []ChallengeData, err error) {
+	ctx := context.Background()
+
+	now := fmt.Sprintf("(%d", time.Now().UnixMilli())
+	list := func(key string) ([]ChallengeData, error) {
+		ids, err := cache.Db.ZRangeByScore(ctx, key, &redis.ZRangeBy{Min: now, Max: "+inf"}).Result()
+		if err != nil {
+			return nil, fmt.Errorf("failed to list challenges: %w", err)
+		}
+		challenges := make([]ChallengeData, 0, len(ids))
+		for _, id := range ids {
+			challenge, err := cache.GetChallenge(id)
+			if err != nil || challenge == nil {
+				continue // Skip if we can't get this challenge
+			}
+			challenges = append(challenges, *challenge)
+		}
+		return challenges, nil
+	}
+
+	if incoming, err = list(incomingChallengesKey(user_id)); err != nil {
+		return nil, nil, err
+	}
+	if outgoing, err = list(outgoingChallengesKey(user_id)); err != nil {
+		return nil, nil, err
+	}
+	return incoming, outgoing, nil
+}
+
+// ListExpiredChallenges returns the waiting rooms whose challenge has expired
+func (cache *Cache) ListExpiredChallenges(now time.Time, limit int64) ([]string, error) {
+	ctx := context.Background()
+
+	ids, err := cache.Db.ZRangeByScore(ctx, CHALLENGES_INDEX_KEY, &redis.ZRangeBy{
+		Min:   "-inf",
+		Max:   fmt.Sprintf("%d", now.UnixMilli()),
+		Count: limit,
+	}).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list expired challenges: %w", err)
+	}
+	return ids, nil
+}
+
+// ExpireChallenge removes an expired challenge, nil if it was already settled or expired by someone else
+func (cache *Cache) ExpireChallenge(waiting_room_id string) (*ChallengeData, error) {
+	ctx := context.Background()
+
+	claimed, err := cache.Db.ZRem(ctx, CHALLENGES_INDEX_KEY, waiting_room_id).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to expire challenge: %w", err)
+	}
+	if claimed == 0 {
+		return nil, nil
+	}
+
+	challenge, err := cache.GetChallenge(waiting_room_id)
+	if err != nil || challenge == nil {
+		return nil, err
+	}
+	pipe := cache.Db.TxPipeline()
+	removeChallenge(ctx, pipe, waiting_room_id, challenge.Challenger.PID, challenge.Opponent.PID)
+	pipe.Del(ctx, fmt.Sprintf("duel:rematch:%s", waiting_room_id))
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, fmt.Errorf("failed to expire challenge: %w", err)
+	}
+	return challenge, nil
+}
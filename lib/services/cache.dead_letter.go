@@ -0,0 +1,98 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+const DEAD_LETTERS_INDEX_KEY = "duel:dead_letters"
+
+type DeadLetterData struct {
+	ID        string          `json:"id"`
+	SessionID string          `json:"session_id"`
+	Payload   json.RawMessage `json:"payload"`
+	Reason    string          `json:"reason"`
+	Attempts  int             `json:"attempts"`
+	FailedAt  time.Time       `json:"failed_at"`
+}
+
+func (cache *Cache) AddDeadLetter(dead_letter *DeadLetterData) (string, error) {
+	ctx := context.Background()
+
+	dead_letter.ID = uuid.New().String()
+	if dead_letter.FailedAt.IsZero() {
+		dead_letter.FailedAt = time.Now()
+	}
+	dead_letter_json, err := json.Marshal(dead_letter)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal dead letter: %w", err)
+	}
+
+	pipe := cache.Db.TxPipeline()
+	pipe.Set(ctx, fmt.Sprintf("duel:dead_letter:%s", dead_letter.ID), dead_letter_json, 0)
+	pipe.ZAdd(ctx, DEAD_LETTERS_INDEX_KEY, redis.Z{Score: float64(dead_letter.FailedAt.UnixMilli()), Member: dead_letter.ID})
+	if _, err := pipe.Exec(ctx); err != nil {
+		return "", fmt.Errorf("failed to store dead letter: %w", err)
+	}
+	return dead_letter.ID, nil
+}
+
+func (cache *Cache) GetDeadLetter(dead_letter_id string) (DeadLetterData, error) {
+	ctx := context.Background()
+
+	var dead_letter DeadLetterData
+	dead_letter_json, err := cache.Db.Get(ctx, fmt.Sprintf("duel:dead_letter:%s", dead_letter_id)).Result()
+	if err == redis.Nil {
+		return dead_letter, fmt.Errorf("dead letter %s does not exist", dead_letter_id)
+	} else if err != nil {
+		return dead_letter, fmt.Errorf("failed to get dead letter: %w", err)
+	}
+	if err := json.Unmarshal([]byte(dead_letter_json), &dead_letter); err != nil {
+		return dead_letter, fmt.Errorf("failed to unmarshal dead letter: %w", err)
+	}
+	return dead_letter, nil
+}
+
+// ListDeadLetters returns the dead letters from the most recent one
+func (cache *Cache) ListDeadLetters(offset int, limit int) ([]DeadLetterData, int64, error) {
+	ctx := context.Background()
+
+	total, err := cache.Db.ZCard(ctx, DEAD_LETTERS_INDEX_KEY).Result()
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to count dead letters: %w", err)
+	}
+	ids, err := cache.Db.ZRevRange(ctx, DEAD_LETTERS_INDEX_KEY, int64(offset), int64(offset+limit-1)).Result()
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list dead letters: %w", err)
+	}
+
+	dead_letters := make([]DeadLetterData, 0, len(ids))
+	for _, id := range ids {
+		dead_letter, err := cache.GetDeadLetter(id)
+		if err != nil {
+			continue // Skip if we can't get this dead letter
+		}
+		dead_letters = append(dead_letters, dead_letter)
+	}
+	return dead_letters, total, nil
+}
+
+func (cache *Cache) DeleteDeadLetter(dead_letter_id string) error {
+	ctx := context.Background()
+
+	pipe := cache.Db.TxPipeline()
+	deleted := pipe.Del(ctx, fmt.Sprintf("duel:dead_letter:%s", dead_letter_id))
+	pipe.ZRem(ctx, DEAD_LETTERS_INDEX_KEY, dead_letter_id)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to delete dead letter: %w", err)
+	}
+	if deleted.Val() == 0 {
+		return fmt.Errorf("dead letter %s does not exist", dead_letter_id)
+	}
+	return nil
+}
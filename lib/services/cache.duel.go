@@ -1,8 +1,10 @@
 package services
 
 import (
+	"backend/lib/rulesets"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"time"
 
@@ -19,6 +21,27 @@ type WaitingRoomData struct {
 
 const WAITING_ROOM_TTL = 10 * time.Minute
 
+const (
+	// A duel that is not started by its nexuspool in time is abandoned
+	DUEL_SESSION_START_TIMEOUT = 10 * time.Minute
+	// A running duel that does not report its result in time is abandoned
+	DUEL_SESSION_RUN_TIMEOUT = time.Hour
+)
+
+// An open duel session outlives the sweeper timeouts so that it is aborted before it expires
+const DUEL_SESSION_TTL = 2 * time.Hour
+
+// A closed duel session is kept to reject results published again for it
+const DUEL_SESSION_CLOSED_TTL = 24 * time.Hour
+
+// The open duel sessions are scored by the time at which they are abandoned
+const DUEL_SESSIONS_OPEN_INDEX_KEY = "duel:sessions:open"
+
+var (
+	ErrInvalidTransition = errors.New("invalid duel session transition")
+	ErrSessionConflict   = errors.New("duel session was modified concurrently")
+)
+
 func (cache *Cache) UpsertDuelWaitingRoom(waiting_room WaitingRoomData) (string, error) {
 	ctx := context.Background()
 
@@ -60,16 +83,61 @@ func (cache *Cache) DeleteDuelWaitingRoom(waiting_room WaitingRoomData) error {
 	ctx := context.Background()
 
 	waiting_room_key := fmt.Sprintf("duel:waiting_room:id:%s&%s", UUIDToString(waiting_room.Player1ID), UUIDToString(waiting_room.Player2ID))
-	_, err := cache.Db.Del(ctx, waiting_room_key).Result()
-	if err == nil {
+	waiting_room_id, err := cache.Db.Get(ctx, waiting_room_key).Result()
+	if err == redis.Nil {
 		return nil
-	} else if err != redis.Nil {
-		// An error occurred other than key not existing
+	} else if err != nil {
 		return fmt.Errorf("failed to check existing waiting_room: %w", err)
 	}
+
+	// The challenge of the waiting room is settled
+	pipe := cache.Db.TxPipeline()
+	pipe.Del(ctx, waiting_room_key)
+	removeChallenge(ctx, pipe, waiting_room_id, waiting_room.Player1ID, waiting_room.Player2ID)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to delete waiting_room: %w", err)
+	}
+	return nil
+}
+
+// RematchData sets the sides of the duel started from a rematch waiting room
+type RematchData struct {
+	P1ID              pgtype.UUID `json:"p1_id"`
+	P2ID              pgtype.UUID `json:"p2_id"`
+	PreviousSessionID string      `json:"previous_session_id"`
+}
+
+func (cache *Cache) SetRematch(waiting_room_id string, rematch RematchData) error {
+	ctx := context.Background()
+
+	rematch_json, err := json.Marshal(rematch)
+	if err != nil {
+		return fmt.Errorf("failed to marshal rematch: %w", err)
+	}
+	err = cache.Db.Set(ctx, fmt.Sprintf("duel:rematch:%s", waiting_room_id), rematch_json, WAITING_ROOM_TTL).Err()
+	if err != nil {
+		return fmt.Errorf("failed to create rematch: %w", err)
+	}
 	return nil
 }
 
+// GetRematch returns the rematch of a waiting room, nil if the waiting room is not a rematch
+func (cache *Cache) GetRematch(waiting_room_id string) (*RematchData, error) {
+	ctx := context.Background()
+
+	rematch_json, err := cache.Db.Get(ctx, fmt.Sprintf("duel:rematch:%s", waiting_room_id)).Result()
+	if err == redis.Nil {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get rematch: %w", err)
+	}
+	var rematch RematchData
+	if err := json.Unmarshal([]byte(rematch_json), &rematch); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal rematch: %w", err)
+	}
+	return &rematch, nil
+}
+
 type DuelPlayerSummaryData struct {
 	PID      pgtype.UUID `json:"pid"`
 	Elo      uint        `json:"elo"`
@@ -77,10 +145,58 @@ type DuelPlayerSummaryData struct {
 	Username string      `json:"username"`
 }
 
+type DuelSessionStatus string
+
+const (
+	DuelSessionCreated  DuelSessionStatus = "created"
+	DuelSessionPrepared DuelSessionStatus = "prepared"
+	DuelSessionRunning  DuelSessionStatus = "running"
+	DuelSessionFinished DuelSessionStatus = "finished"
+	DuelSessionAborted  DuelSessionStatus = "aborted"
+)
+
+var duelSessionTransitions = map[DuelSessionStatus][]DuelSessionStatus{
+	DuelSessionCreated:  {DuelSessionPrepared, DuelSessionRunning, DuelSessionFinished, DuelSessionAborted},
+	DuelSessionPrepared: {DuelSessionRunning, DuelSessionFinished, DuelSessionAborted},
+	DuelSessionRunning:  {DuelSessionFinished, DuelSessionAborted},
+}
+
+// CanTransition tells if a duel session can go from one status to another
+func (status DuelSessionStatus) CanTransition(to DuelSessionStatus) bool {
+	for _, allowed := range duelSessionTransitions[status] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
 type DuelSessionData struct {
-	P1       DuelPlayerSummaryData `json:"p1"`
-	P2       DuelPlayerSummaryData `json:"p2"`
-	DuelType basepool.DuelType     `json:"duel_type"`
+	P1           DuelPlayerSummaryData `json:"p1"`
+	P2           DuelPlayerSummaryData `json:"p2"`
+	DuelType     basepool.DuelType     `json:"duel_type"`
+	TournamentID string                `json:"tournament_id,omitempty"`
+	MatchID      string                `json:"match_id,omitempty"`
+	SeasonID     string                `json:"season_id,omitempty"`
+	BotID        string                `json:"bot_id,omitempty"` // P2 is a house bot in practice duels
+	Ruleset      *rulesets.Ruleset     `json:"ruleset"`
+	Status       DuelSessionStatus     `json:"status"`
+	CreatedAt    time.Time             `json:"created_at"`
+	UpdatedAt    time.Time             `json:"updated_at"`
+	StartedAt    time.Time             `json:"started_at,omitempty"`
+}
+
+// IsClosed tells if the duel session has reached its end, whether finished or aborted
+func (session_data DuelSessionData) IsClosed() bool {
+	return session_data.Status == DuelSessionFinished || session_data.Status == DuelSessionAborted
+}
+
+// AbandonAt returns the time at which an open duel session is abandoned if it does not progress
+func (session_data DuelSessionData) AbandonAt() time.Time {
+	if session_data.Status == DuelSessionRunning {
+		return session_data.UpdatedAt.Add(DUEL_SESSION_RUN_TIMEOUT)
+	}
+	return session_data.UpdatedAt.Add(DUEL_SESSION_START_TIMEOUT)
 }
 
 type DuelPlayerSummaryDataExtern struct {
@@ -93,6 +209,7 @@ type DuelSessionDataExtern struct {
 	P1       DuelPlayerSummaryDataExtern `json:"p1"`
 	P2       DuelPlayerSummaryDataExtern `json:"p2"`
 	DuelType basepool.DuelType           `json:"duel_type"`
+	Ruleset  *rulesets.Ruleset           `json:"ruleset"`
 }
 
 func (cache *Cache) CreateDuelSession(session_data *DuelSessionData) (string, error) {
@@ -102,18 +219,54 @@ func (cache *Cache) CreateDuelSession(session_data *DuelSessionData) (string, er
 
 	duel_session_key := fmt.Sprintf("duel:session:%s", duel_session_id)
 
+	// Every duel is played with a known ruleset
+	if session_data.Ruleset == nil {
+		ruleset := rulesets.Default(session_data.DuelType)
+		session_data.Ruleset = &ruleset
+	}
+	if err := session_data.Ruleset.Validate(); err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	session_data.Status = DuelSessionCreated
+	session_data.CreatedAt = now
+	session_data.UpdatedAt = now
+
 	session_data_json, err := json.Marshal(session_data)
 	if err != nil {
 		return "", fmt.Errorf("failed to marshal duel session data: %w", err)
 	}
-	err = cache.Db.Set(ctx, duel_session_key, session_data_json, 1*time.Hour).Err()
-	if err != nil {
+	// Each player points to its ongoing duel so that it can be found by spectators
+	pipe := cache.Db.TxPipeline()
+	pipe.Set(ctx, duel_session_key, session_data_json, DUEL_SESSION_TTL)
+	pipe.Set(ctx, playerDuelSessionKey(session_data.P1.PID), duel_session_id, DUEL_SESSION_TTL)
+	pipe.Set(ctx, playerDuelSessionKey(session_data.P2.PID), duel_session_id, DUEL_SESSION_TTL)
+	pipe.ZAdd(ctx, DUEL_SESSIONS_OPEN_INDEX_KEY, redis.Z{Score: float64(session_data.AbandonAt().UnixMilli()), Member: duel_session_id})
+	if _, err := pipe.Exec(ctx); err != nil {
 		return "", fmt.Errorf("failed to create duel session cache data: %w", err)
 	}
 
 	return duel_session_id, nil
 }
 
+func playerDuelSessionKey(user_id pgtype.UUID) string {
+	return fmt.Sprintf("duel:session:player:%s", UUIDToString(user_id))
+}
+
+// GetPlayerDuelSession returns the ongoing duel session of a player, empty if the player is not in a duel
+func (cache *Cache) GetPlayerDuelSession(user_id pgtype.UUID) (string, error) {
+	ctx := context.Background()
+
+	duel_session_id, err := cache.Db.Get(ctx, playerDuelSessionKey(user_id)).Result()
+	if err == redis.Nil {
+		return "", nil
+	} else if err != nil {
+		return "", fmt.Errorf("failed to get player duel session: %w", err)
+	}
+	return duel_session_id, nil
+}
+
 func (cache *Cache) GetDuelSession(duel_session_id string) (DuelSessionData, error) {
 	ctx := context.Background()
 
@@ -131,3 +284,115 @@ func (cache *Cache) GetDuelSession(duel_session_id string) (DuelSessionData, err
 	}
 	return session_data, nil
 }
+
+// UpdateDuelSession applies a status change to a duel session atomically.
+// The update is given the current session and returns the status to move to.
+func (cache *Cache) UpdateDuelSession(duel_session_id string, update func(session_data DuelSessionData) (DuelSessionStatus, error)) (DuelSessionData, error) {
+	ctx := context.Background()
+
+	duel_session_key := fmt.Sprintf("duel:session:%s", duel_session_id)
+
+	var session_data DuelSessionData
+	transaction := func(tx *redis.Tx) error {
+		session_data_json, err := tx.Get(ctx, duel_session_key).Result()
+		if err != nil {
+			return fmt.Errorf("failed to get duel session: %w", err)
+		}
+		if err := json.Unmarshal([]byte(session_data_json), &session_data); err != nil {
+			return fmt.Errorf("failed to unmarshal duel session data: %w", err)
+		}
+
+		to, err := update(session_data)
+		if err != nil {
+			return err
+		}
+		if to == session_data.Status {
+			return nil
+		}
+		if !session_data.Status.CanTransition(to) {
+			return fmt.Errorf("%w: %s to %s", ErrInvalidTransition, session_data.Status, to)
+		}
+
+		// The players may already be in another duel
+		var stale_pointers []string
+		if to == DuelSessionFinished || to == DuelSessionAborted {
+			for _, pid := range []pgtype.UUID{session_data.P1.PID, session_data.P2.PID} {
+				current, err := tx.Get(ctx, playerDuelSessionKey(pid)).Result()
+				if err != nil && err != redis.Nil {
+					return fmt.Errorf("failed to get player duel session: %w", err)
+				}
+				if current == duel_session_id {
+					stale_pointers = append(stale_pointers, playerDuelSessionKey(pid))
+				}
+			}
+		}
+
+		session_data.Status = to
+		session_data.UpdatedAt = time.Now()
+		if to == DuelSessionRunning {
+			session_data.StartedAt = session_data.UpdatedAt
+		}
+		updated_json, err := json.Marshal(session_data)
+		if err != nil {
+			return fmt.Errorf("failed to marshal duel session data: %w", err)
+		}
+
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			if session_data.IsClosed() {
+				pipe.Set(ctx, duel_session_key, updated_json, DUEL_SESSION_CLOSED_TTL)
+				pipe.ZRem(ctx, DUEL_SESSIONS_OPEN_INDEX_KEY, duel_session_id)
+				for _, key := range stale_pointers {
+					pipe.Del(ctx, key)
+				}
+			} else {
+				pipe.Set(ctx, duel_session_key, updated_json, DUEL_SESSION_TTL)
+				pipe.ZAdd(ctx, DUEL_SESSIONS_OPEN_INDEX_KEY, redis.Z{Score: float64(session_data.AbandonAt().UnixMilli()), Member: duel_session_id})
+			}
+			return nil
+		})
+		return err
+	}
+
+	for i := 0; i < 10; i++ {
+		err := cache.Db.Watch(ctx, transaction, duel_session_key)
+		if err == nil {
+			return session_data, nil
+		}
+		if err != redis.TxFailedErr {
+			return session_data, err
+		}
+	}
+	return session_data, ErrSessionConflict
+}
+
+// TransitionDuelSession moves a duel session to the given status
+func (cache *Cache) TransitionDuelSession(duel_session_id string, to DuelSessionStatus) (DuelSessionData, error) {
+	return cache.UpdateDuelSession(duel_session_id, func(session_data DuelSessionData) (DuelSessionStatus, error) {
+		return to, nil
+	})
+}
+
+// ListOpenDuelSessions returns a page of the open duel sessions abandoned by the given time, from the oldest one
+func (cache *Cache) ListOpenDuelSessions(abandoned_by time.Time, offset int64, limit int64) ([]string, error) {
+	ctx := context.Background()
+
+	duel_session_ids, err := cache.Db.ZRangeByScore(ctx, DUEL_SESSIONS_OPEN_INDEX_KEY, &redis.ZRangeBy{
+		Min:    "-inf",
+		Max:    fmt.Sprintf("%d", abandoned_by.UnixMilli()),
+		Offset: offset,
+		Count:  limit,
+	}).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list open duel sessions: %w", err)
+	}
+	return duel_session_ids, nil
+}
+
+// ForgetOpenDuelSession removes an expired duel session from the open sessions
+func (cache *Cache) ForgetOpenDuelSession(duel_session_id string) error {
+	ctx := context.Background()
+	if err := cache.Db.ZRem(ctx, DUEL_SESSIONS_OPEN_INDEX_KEY, duel_session_id).Err(); err != nil {
+		return fmt.Errorf("failed to forget duel session: %w", err)
+	}
+	return nil
+}
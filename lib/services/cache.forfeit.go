@@ -0,0 +1,122 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/redis/go-redis/v9"
+)
+
+const DUEL_DISCONNECTS_INDEX_KEY = "duel:disconnects"
+
+type DisconnectData struct {
+	SessionID string      `json:"session_id"`
+	UserID    pgtype.UUID `json:"user_id"`
+	At        time.Time   `json:"at"`
+	Deadline  time.Time   `json:"deadline"`
+}
+
+func disconnectMember(session_id string, user_id pgtype.UUID) string {
+	return fmt.Sprintf("%s:%s", session_id, UUIDToString(user_id))
+}
+
+// ClaimForfeit reserves the forfeit of a duel session for the given winner, false if it was already claimed
+func (cache *Cache) ClaimForfeit(session_id string, winner string) (bool, error) {
+	ctx := context.Background()
+
+	claimed, err := cache.Db.SetNX(ctx, fmt.Sprintf("duel:forfeit:%s", session_id), winner, DUEL_SESSION_CLOSED_TTL).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to claim forfeit: %w", err)
+	}
+	return claimed, nil
+}
+
+// GetForfeitClaim returns the winner the forfeit of a duel session was claimed for, empty if there is none
+func (cache *Cache) GetForfeitClaim(session_id string) (string, error) {
+	ctx := context.Background()
+
+	winner, err := cache.Db.Get(ctx, fmt.Sprintf("duel:forfeit:%s", session_id)).Result()
+	if err == redis.Nil {
+		return "", nil
+	} else if err != nil {
+		return "", fmt.Errorf("failed to get forfeit claim: %w", err)
+	}
+	return winner, nil
+}
+
+// ReleaseForfeit frees the forfeit of a duel session when it could not be published
+func (cache *Cache) ReleaseForfeit(session_id string) error {
+	ctx := context.Background()
+	if err := cache.Db.Del(ctx, fmt.Sprintf("duel:forfeit:%s", session_id)).Err(); err != nil {
+		return fmt.Errorf("failed to release forfeit: %w", err)
+	}
+	return nil
+}
+
+// AddDisconnect records a player disconnected from its duel until the given deadline
+func (cache *Cache) AddDisconnect(disconnect DisconnectData) error {
+	ctx := context.Background()
+
+	member := disconnectMember(disconnect.SessionID, disconnect.UserID)
+	disconnect_json, err := json.Marshal(disconnect)
+	if err != nil {
+		return fmt.Errorf("failed to marshal disconnect: %w", err)
+	}
+
+	// A disconnect reported again keeps its first deadline
+	pipe := cache.Db.TxPipeline()
+	pipe.SetNX(ctx, fmt.Sprintf("duel:disconnect:%s", member), disconnect_json, DUEL_SESSION_TTL)
+	pipe.ZAddNX(ctx, DUEL_DISCONNECTS_INDEX_KEY, redis.Z{Score: float64(disconnect.Deadline.UnixMilli()), Member: member})
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to store disconnect: %w", err)
+	}
+	return nil
+}
+
+func (cache *Cache) RemoveDisconnect(session_id string, user_id pgtype.UUID) error {
+	ctx := context.Background()
+
+	member := disconnectMember(session_id, user_id)
+	pipe := cache.Db.TxPipeline()
+	pipe.Del(ctx, fmt.Sprintf("duel:disconnect:%s", member))
+	pipe.ZRem(ctx, DUEL_DISCONNECTS_INDEX_KEY, member)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to remove disconnect: %w", err)
+	}
+	return nil
+}
+
+// ListExpiredDisconnects returns the disconnects whose deadline has passed
+func (cache *Cache) ListExpiredDisconnects(now time.Time, limit int64) ([]DisconnectData, error) {
+	ctx := context.Background()
+
+	members, err := cache.Db.ZRangeByScore(ctx, DUEL_DISCONNECTS_INDEX_KEY, &redis.ZRangeBy{
+		Min:   "-inf",
+		Max:   fmt.Sprintf("%d", now.UnixMilli()),
+		Count: limit,
+	}).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list expired disconnects: %w", err)
+	}
+
+	disconnects := make([]DisconnectData, 0, len(members))
+	for _, member := range members {
+		disconnect_json, err := cache.Db.Get(ctx, fmt.Sprintf("duel:disconnect:%s", member)).Result()
+		if err == redis.Nil {
+			// The disconnect expired with its session
+			cache.Db.ZRem(ctx, DUEL_DISCONNECTS_INDEX_KEY, member)
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to get disconnect: %w", err)
+		}
+		var disconnect DisconnectData
+		if err := json.Unmarshal([]byte(disconnect_json), &disconnect); err != nil {
+			continue
+		}
+		disconnects = append(disconnects, disconnect)
+	}
+	return disconnects, nil
+}
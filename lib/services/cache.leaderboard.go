@@ -0,0 +1,239 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	LEADERBOARD_GLOBAL_KEY   = "leaderboard:global"
+	LEADERBOARD_PROFILES_KEY = "leaderboard:profiles"
+)
+
+func LeaderboardCountryKey(country string) string {
+	return fmt.Sprintf("leaderboard:country:%s", country)
+}
+
+type LeaderboardPlayer struct {
+	UserID   string `json:"user_id"`
+	Username string `json:"username"`
+	Tag      string `json:"tag"`
+	Country  string `json:"country"`
+	Elo      int    `json:"-"`
+}
+
+type LeaderboardEntry struct {
+	Rank     int64  `json:"rank"`
+	Elo      int64  `json:"elo"`
+	Username string `json:"username"`
+	Tag      string `json:"tag"`
+	Country  string `json:"country"`
+	UserID   string `json:"-"`
+}
+
+// UpsertLeaderboardPlayer sets the rating of a player in the global and country leaderboards
+func (cache *Cache) UpsertLeaderboardPlayer(player LeaderboardPlayer) error {
+	ctx := context.Background()
+
+	// A player moving to another country leaves the previous country leaderboard
+	previous, err := cache.getLeaderboardProfiles(ctx, []string{player.UserID})
+	if err != nil {
+		return err
+	}
+
+	player_json, err := json.Marshal(player)
+	if err != nil {
+		return fmt.Errorf("failed to marshal leaderboard player: %w", err)
+	}
+
+	pipe := cache.Db.TxPipeline()
+	if profile, ok := previous[player.UserID]; ok && profile.Country != player.Country && profile.Country != "" {
+		pipe.ZRem(ctx, LeaderboardCountryKey(profile.Country), player.UserID)
+	}
+	pipe.HSet(ctx, LEADERBOARD_PROFILES_KEY, player.UserID, player_json)
+	pipe.ZAdd(ctx, LEADERBOARD_GLOBAL_KEY, redis.Z{Score: float64(player.Elo), Member: player.UserID})
+	if player.Country != "" {
+		pipe.ZAdd(ctx, LeaderboardCountryKey(player.Country), redis.Z{Score: float64(player.Elo), Member: player.UserID})
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to update leaderboard: %w", err)
+	}
+	return nil
+}
+
+func (cache *Cache) getLeaderboardProfiles(ctx context.Context, user_ids []string) (map[string]LeaderboardPlayer, error) {
+	profiles := make(map[string]LeaderboardPlayer, len(user_ids))
+	if len(user_ids) == 0 {
+		return profiles, nil
+	}
+
+	values, err := cache.Db.HMGet(ctx, LEADERBOARD_PROFILES_KEY, user_ids...).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get leaderboard profiles: %w", err)
+	}
+	for i, value := range values {
+		profile_json, ok := value.(string)
+		if !ok {
+			continue
+		}
+		var profile LeaderboardPlayer
+		if err := json.Unmarshal([]byte(profile_json), &profile); err != nil {
+			continue
+		}
+		profiles[user_ids[i]] = profile
+	}
+	return profiles, nil
+}
+
+func (cache *Cache) toLeaderboardEntries(ctx context.Context, scores []redis.Z, first_rank int64) ([]LeaderboardEntry, error) {
+	user_ids := make([]string, len(scores))
+	for i, score := range scores {
+		user_ids[i], _ = score.Member.(string)
+	}
+	profiles, err := cache.getLeaderboardProfiles(ctx, user_ids)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]LeaderboardEntry, len(scores))
+	for i, score := range scores {
+		profile := profiles[user_ids[i]]
+		entries[i] = LeaderboardEntry{
+			Rank:     first_rank + int64(i),
+			Elo:      int64(score.Score),
+			Username: profile.Username,
+			Tag:      profile.Tag,
+			Country:  profile.Country,
+			UserID:   user_ids[i],
+		}
+	}
+	return entries, nil
+}
+
+// GetLeaderboard returns a page of a leaderboard starting at the cursor, the next cursor is 0 on the last page
+func (cache *Cache) GetLeaderboard(key string, cursor int64, limit int64) ([]LeaderboardEntry, int64, error) {
+	ctx := context.Background()
+
+	scores, err := cache.Db.ZRevRangeWithScores(ctx, key, cursor, cursor+limit).Result()
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to get leaderboard: %w", err)
+	}
+
+	// One more entry is fetched to know if there is a next page
+	var next_cursor int64
+	if int64(len(scores)) > limit {
+		scores = scores[:limit]
+		next_cursor = cursor + limit
+	}
+
+	entries, err := cache.toLeaderboardEntries(ctx, scores, cursor+1)
+	if err != nil {
+		return nil, 0, err
+	}
+	return entries, next_cursor, nil
+}
+
+// GetLeaderboardRank returns the entry of a user in a leaderboard, nil if the user is not ranked
+func (cache *Cache) GetLeaderboardRank(key string, user_id string) (*LeaderboardEntry, error) {
+	ctx := context.Background()
+
+	rank, err := cache.Db.ZRevRank(ctx, key, user_id).Result()
+	if err == redis.Nil {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get leaderboard rank: %w", err)
+	}
+	elo, err := cache.Db.ZScore(ctx, key, user_id).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get leaderboard rank: %w", err)
+	}
+
+	entries, err := cache.toLeaderboardEntries(ctx, []redis.Z{{Score: elo, Member: user_id}}, rank+1)
+	if err != nil {
+		return nil, err
+	}
+	return &entries[0], nil
+}
+
+// GetLeaderboardAmong ranks the given users between them using the global leaderboard
+func (cache *Cache) GetLeaderboardAmong(user_ids []string) ([]LeaderboardEntry, error) {
+	ctx := context.Background()
+	if len(user_ids) == 0 {
+		return []LeaderboardEntry{}, nil
+	}
+
+	// ZMScore turns missing members into 0, the raw reply keeps them nil so that unranked users are left out
+	args := []interface{}{"ZMSCORE", LEADERBOARD_GLOBAL_KEY}
+	for _, user_id := range user_ids {
+		args = append(args, user_id)
+	}
+	elos, err := cache.Db.Do(ctx, args...).Slice()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get leaderboard scores: %w", err)
+	}
+
+	scores := make([]redis.Z, 0, len(user_ids))
+	for i, user_id := range user_ids {
+		var elo float64
+		switch value := elos[i].(type) {
+		case nil:
+			continue
+		case float64:
+			elo = value
+		case string:
+			if elo, err = strconv.ParseFloat(value, 64); err != nil {
+				return nil, fmt.Errorf("failed to parse leaderboard score: %w", err)
+			}
+		default:
+			continue
+		}
+		scores = append(scores, redis.Z{Score: elo, Member: user_id})
+	}
+	sort.SliceStable(scores, func(i, j int) bool {
+		return scores[i].Score > scores[j].Score
+	})
+
+	return cache.toLeaderboardEntries(ctx, scores, 1)
+}
+
+// RebuildLeaderboards replaces every leaderboard with the given players
+func (cache *Cache) RebuildLeaderboards(players []LeaderboardPlayer) error {
+	ctx := context.Background()
+
+	var country_keys []string
+	var cursor uint64
+	for {
+		keys, next_cursor, err := cache.Db.Scan(ctx, cursor, LeaderboardCountryKey("*"), 100).Result()
+		if err != nil {
+			return fmt.Errorf("failed to list country leaderboards: %w", err)
+		}
+		country_keys = append(country_keys, keys...)
+		if next_cursor == 0 {
+			break
+		}
+		cursor = next_cursor
+	}
+
+	pipe := cache.Db.TxPipeline()
+	pipe.Del(ctx, append(country_keys, LEADERBOARD_GLOBAL_KEY, LEADERBOARD_PROFILES_KEY)...)
+	for _, player := range players {
+		player_json, err := json.Marshal(player)
+		if err != nil {
+			return fmt.Errorf("failed to marshal leaderboard player: %w", err)
+		}
+		pipe.HSet(ctx, LEADERBOARD_PROFILES_KEY, player.UserID, player_json)
+		pipe.ZAdd(ctx, LEADERBOARD_GLOBAL_KEY, redis.Z{Score: float64(player.Elo), Member: player.UserID})
+		if player.Country != "" {
+			pipe.ZAdd(ctx, LeaderboardCountryKey(player.Country), redis.Z{Score: float64(player.Elo), Member: player.UserID})
+		}
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to rebuild leaderboards: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,195 @@
+package services
+
+import (
+	"backend/lib/rulesets"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	ErrLobbyNotFound = errors.New("lobby does not exist")
+	ErrLobbyConflict = errors.New("lobby was modified concurrently")
+)
+
+type LobbyStatus string
+
+const (
+	LobbyOpen    LobbyStatus = "open"
+	LobbyStarted LobbyStatus = "started"
+)
+
+const (
+	LOBBY_TTL         = 30 * time.Minute
+	LOBBY_CODE_LENGTH = 6
+	// Ambiguous characters are left out so that codes can be read aloud
+	LOBBY_CODE_ALPHABET = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+)
+
+type LobbyData struct {
+	Code      string                 `json:"code"`
+	Host      DuelPlayerSummaryData  `json:"host"`
+	Guest     *DuelPlayerSummaryData `json:"guest,omitempty"`
+	Ruleset   rulesets.Ruleset       `json:"ruleset"`
+	Status    LobbyStatus            `json:"status"`
+	SessionID string                 `json:"session_id,omitempty"`
+	CreatedAt time.Time              `json:"created_at"`
+}
+
+func lobbyKey(code string) string {
+	return fmt.Sprintf("duel:lobby:%s", code)
+}
+
+func hostLobbyKey(user_id pgtype.UUID) string {
+	return fmt.Sprintf("duel:lobby:host:%s", UUIDToString(user_id))
+}
+
+func newLobbyCode() (string, error) {
+	code := make([]byte, LOBBY_CODE_LENGTH)
+	for i := range code {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(LOBBY_CODE_ALPHABET))))
+		if err != nil {
+			return "", err
+		}
+		code[i] = LOBBY_CODE_ALPHABET[n.Int64()]
+	}
+	return string(code), nil
+}
+
+// CreateLobby opens a lobby with a new code, a host has at most one lobby
+func (cache *Cache) CreateLobby(lobby *LobbyData) (string, error) {
+	ctx := context.Background()
+
+	if code, err := cache.GetHostLobby(lobby.Host.PID); err != nil {
+		return "", err
+	} else if code != "" {
+		if err := cache.DeleteLobby(code); err != nil {
+			return "", err
+		}
+	}
+
+	lobby.Status = LobbyOpen
+	lobby.CreatedAt = time.Now()
+	for i := 0; i < 10; i++ {
+		code, err := newLobbyCode()
+		if err != nil {
+			return "", fmt.Errorf("failed to generate lobby code: %w", err)
+		}
+		lobby.Code = code
+
+		lobby_json, err := json.Marshal(lobby)
+		if err != nil {
+			return "", fmt.Errorf("failed to marshal lobby: %w", err)
+		}
+		created, err := cache.Db.SetNX(ctx, lobbyKey(code), lobby_json, LOBBY_TTL).Result()
+		if err != nil {
+			return "", fmt.Errorf("failed to create lobby: %w", err)
+		}
+		if !created {
+			continue // The code is already taken
+		}
+		if err := cache.Db.Set(ctx, hostLobbyKey(lobby.Host.PID), code, LOBBY_TTL).Err(); err != nil {
+			return "", fmt.Errorf("failed to create lobby: %w", err)
+		}
+		return code, nil
+	}
+	return "", fmt.Errorf("failed to generate a free lobby code")
+}
+
+// GetHostLobby returns the code of the lobby hosted by a user, empty if there is none
+func (cache *Cache) GetHostLobby(user_id pgtype.UUID) (string, error) {
+	ctx := context.Background()
+
+	code, err := cache.Db.Get(ctx, hostLobbyKey(user_id)).Result()
+	if err == redis.Nil {
+		return "", nil
+	} else if err != nil {
+		return "", fmt.Errorf("failed to get hosted lobby: %w", err)
+	}
+	return code, nil
+}
+
+func (cache *Cache) GetLobby(code string) (LobbyData, error) {
+	ctx := context.Background()
+
+	var lobby LobbyData
+	lobby_json, err := cache.Db.Get(ctx, lobbyKey(code)).Result()
+	if err == redis.Nil {
+		return lobby, ErrLobbyNotFound
+	} else if err != nil {
+		return lobby, fmt.Errorf("failed to get lobby: %w", err)
+	}
+	if err := json.Unmarshal([]byte(lobby_json), &lobby); err != nil {
+		return lobby, fmt.Errorf("failed to unmarshal lobby: %w", err)
+	}
+	return lobby, nil
+}
+
+// UpdateLobby applies a change to a lobby atomically, the lobby keeps its expiry
+func (cache *Cache) UpdateLobby(code string, update func(lobby *LobbyData) error) (LobbyData, error) {
+	ctx := context.Background()
+
+	var lobby LobbyData
+	transaction := func(tx *redis.Tx) error {
+		lobby_json, err := tx.Get(ctx, lobbyKey(code)).Result()
+		if err == redis.Nil {
+			return ErrLobbyNotFound
+		} else if err != nil {
+			return fmt.Errorf("failed to get lobby: %w", err)
+		}
+		lobby = LobbyData{}
+		if err := json.Unmarshal([]byte(lobby_json), &lobby); err != nil {
+			return fmt.Errorf("failed to unmarshal lobby: %w", err)
+		}
+
+		if err := update(&lobby); err != nil {
+			return err
+		}
+		updated_json, err := json.Marshal(lobby)
+		if err != nil {
+			return fmt.Errorf("failed to marshal lobby: %w", err)
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.SetArgs(ctx, lobbyKey(code), updated_json, redis.SetArgs{KeepTTL: true})
+			return nil
+		})
+		return err
+	}
+
+	for i := 0; i < 10; i++ {
+		err := cache.Db.Watch(ctx, transaction, lobbyKey(code))
+		if err == nil {
+			return lobby, nil
+		}
+		if err != redis.TxFailedErr {
+			return lobby, err
+		}
+	}
+	return lobby, ErrLobbyConflict
+}
+
+func (cache *Cache) DeleteLobby(code string) error {
+	ctx := context.Background()
+
+	lobby, err := cache.GetLobby(code)
+	if errors.Is(err, ErrLobbyNotFound) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	pipe := cache.Db.TxPipeline()
+	pipe.Del(ctx, lobbyKey(code))
+	pipe.Del(ctx, hostLobbyKey(lobby.Host.PID))
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to delete lobby: %w", err)
+	}
+	return nil
+}
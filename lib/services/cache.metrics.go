@@ -0,0 +1,43 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+)
+
+const DUEL_METRICS_KEY = "duel:metrics"
+
+const (
+	DUEL_METRIC_DUPLICATES = "duplicates"
+	DUEL_METRIC_REJECTED   = "rejected"
+	DUEL_METRIC_ABORTED    = "aborted"
+	DUEL_METRIC_FLAGGED    = "flagged"
+)
+
+func (cache *Cache) IncrDuelMetric(name string) error {
+	ctx := context.Background()
+	if err := cache.Db.HIncrBy(ctx, DUEL_METRICS_KEY, name, 1).Err(); err != nil {
+		return fmt.Errorf("failed to increment duel metric %s: %w", name, err)
+	}
+	return nil
+}
+
+func (cache *Cache) GetDuelMetrics() (map[string]int64, error) {
+	ctx := context.Background()
+
+	values, err := cache.Db.HGetAll(ctx, DUEL_METRICS_KEY).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get duel metrics: %w", err)
+	}
+
+	metrics := make(map[string]int64, len(values))
+	for name, value := range values {
+		count, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			continue
+		}
+		metrics[name] = count
+	}
+	return metrics, nil
+}
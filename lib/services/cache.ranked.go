@@ -0,0 +1,153 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	RANKED_QUEUE_KEY        = "duel:ranked:queue"
+	RANKED_QUEUE_JOINED_KEY = "duel:ranked:queue:joined_at"
+)
+
+type RankedQueueEntry struct {
+	UserID   pgtype.UUID `json:"-"`
+	Elo      uint        `json:"elo"`
+	JoinedAt time.Time   `json:"joined_at"`
+}
+
+func (cache *Cache) JoinRankedQueue(user_id pgtype.UUID, elo uint) error {
+	ctx := context.Background()
+	member := UUIDToString(user_id)
+
+	pipe := cache.Db.TxPipeline()
+	pipe.ZAdd(ctx, RANKED_QUEUE_KEY, redis.Z{Score: float64(elo), Member: member})
+	pipe.HSetNX(ctx, RANKED_QUEUE_JOINED_KEY, member, time.Now().UnixMilli())
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to join the ranked queue: %w", err)
+	}
+	return nil
+}
+
+func (cache *Cache) LeaveRankedQueue(user_id pgtype.UUID) error {
+	ctx := context.Background()
+	member := UUIDToString(user_id)
+
+	pipe := cache.Db.TxPipeline()
+	pipe.ZRem(ctx, RANKED_QUEUE_KEY, member)
+	pipe.HDel(ctx, RANKED_QUEUE_JOINED_KEY, member)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to leave the ranked queue: %w", err)
+	}
+	return nil
+}
+
+func (cache *Cache) GetRankedQueueEntry(user_id pgtype.UUID) (RankedQueueEntry, error) {
+	ctx := context.Background()
+	member := UUIDToString(user_id)
+
+	elo, err := cache.Db.ZScore(ctx, RANKED_QUEUE_KEY, member).Result()
+	if err == redis.Nil {
+		return RankedQueueEntry{}, fmt.Errorf("user is not in the ranked queue")
+	} else if err != nil {
+		return RankedQueueEntry{}, fmt.Errorf("failed to get ranked queue entry: %w", err)
+	}
+	joined_at, err := cache.Db.HGet(ctx, RANKED_QUEUE_JOINED_KEY, member).Int64()
+	if err != nil && err != redis.Nil {
+		return RankedQueueEntry{}, fmt.Errorf("failed to get ranked queue entry: %w", err)
+	}
+
+	return RankedQueueEntry{
+		UserID:   user_id,
+		Elo:      uint(elo),
+		JoinedAt: time.UnixMilli(joined_at),
+	}, nil
+}
+
+// ListRankedQueue returns every player waiting in the ranked queue ordered by elo
+func (cache *Cache) ListRankedQueue() ([]RankedQueueEntry, error) {
+	ctx := context.Background()
+
+	members, err := cache.Db.ZRangeWithScores(ctx, RANKED_QUEUE_KEY, 0, -1).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the ranked queue: %w", err)
+	}
+	if len(members) == 0 {
+		return []RankedQueueEntry{}, nil
+	}
+
+	ids := make([]string, len(members))
+	for i, member := range members {
+		ids[i] = member.Member.(string)
+	}
+	joined_at, err := cache.Db.HMGet(ctx, RANKED_QUEUE_JOINED_KEY, ids...).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the ranked queue: %w", err)
+	}
+
+	entries := make([]RankedQueueEntry, 0, len(members))
+	for i, member := range members {
+		user_id, err := StringToUUID(ids[i])
+		if err != nil {
+			continue
+		}
+		entry := RankedQueueEntry{
+			UserID:   user_id,
+			Elo:      uint(member.Score),
+			JoinedAt: time.Now(),
+		}
+		if raw, ok := joined_at[i].(string); ok {
+			if millis, err := strconv.ParseInt(raw, 10, 64); err == nil {
+				entry.JoinedAt = time.UnixMilli(millis)
+			}
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// claimRankedPairScript removes both players only if they are both still waiting in the queue
+var claimRankedPairScript = redis.NewScript(`
+if redis.call("ZSCORE", KEYS[1], ARGV[1]) and redis.call("ZSCORE", KEYS[1], ARGV[2]) then
+	redis.call("ZREM", KEYS[1], ARGV[1], ARGV[2])
+	redis.call("HDEL", KEYS[2], ARGV[1], ARGV[2])
+	return 1
+end
+return 0
+`)
+
+// ClaimRankedPair removes both players from the ranked queue, it fails if one of them was already claimed
+func (cache *Cache) ClaimRankedPair(p1_id pgtype.UUID, p2_id pgtype.UUID) (bool, error) {
+	ctx := context.Background()
+
+	claimed, err := claimRankedPairScript.Run(
+		ctx,
+		cache.Db,
+		[]string{RANKED_QUEUE_KEY, RANKED_QUEUE_JOINED_KEY},
+		UUIDToString(p1_id),
+		UUIDToString(p2_id),
+	).Int()
+	if err != nil {
+		return false, fmt.Errorf("failed to claim ranked pair: %w", err)
+	}
+	return claimed == 1, nil
+}
+
+// RequeueRanked puts back a claimed player in the ranked queue with its original waiting time
+func (cache *Cache) RequeueRanked(entry RankedQueueEntry) error {
+	ctx := context.Background()
+	member := UUIDToString(entry.UserID)
+
+	pipe := cache.Db.TxPipeline()
+	pipe.ZAddNX(ctx, RANKED_QUEUE_KEY, redis.Z{Score: float64(entry.Elo), Member: member})
+	pipe.HSetNX(ctx, RANKED_QUEUE_JOINED_KEY, member, entry.JoinedAt.UnixMilli())
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to requeue ranked player: %w", err)
+	}
+	return nil
+}
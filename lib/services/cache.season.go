@@ -0,0 +1,54 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const SEASON_CURRENT_CACHE_KEY = "season:current:cache"
+
+// The seasons are stored in the database, the current one is read on every ranked duel
+const SEASON_CURRENT_TTL = time.Minute
+
+// GetCachedCurrentSeason returns the cached current season, nil when it is not cached
+func (cache *Cache) GetCachedCurrentSeason() (*SeasonData, error) {
+	ctx := context.Background()
+
+	season_json, err := cache.Db.Get(ctx, SEASON_CURRENT_CACHE_KEY).Result()
+	if err == redis.Nil {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get current season: %w", err)
+	}
+	var season SeasonData
+	if err := json.Unmarshal([]byte(season_json), &season); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal season: %w", err)
+	}
+	return &season, nil
+}
+
+func (cache *Cache) SetCachedCurrentSeason(season *SeasonData) error {
+	ctx := context.Background()
+
+	season_json, err := json.Marshal(season)
+	if err != nil {
+		return fmt.Errorf("failed to marshal season: %w", err)
+	}
+	if err := cache.Db.Set(ctx, SEASON_CURRENT_CACHE_KEY, season_json, SEASON_CURRENT_TTL).Err(); err != nil {
+		return fmt.Errorf("failed to cache current season: %w", err)
+	}
+	return nil
+}
+
+// ClearCachedCurrentSeason drops the cached current season once it changed
+func (cache *Cache) ClearCachedCurrentSeason() error {
+	ctx := context.Background()
+	if err := cache.Db.Del(ctx, SEASON_CURRENT_CACHE_KEY).Err(); err != nil {
+		return fmt.Errorf("failed to clear current season: %w", err)
+	}
+	return nil
+}
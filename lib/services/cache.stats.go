@@ -0,0 +1,154 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var ErrUserStatsConflict = errors.New("user stats were modified concurrently")
+
+func userStatsKey(user_id string) string {
+	return fmt.Sprintf("stats:user:%s", user_id)
+}
+
+// userStatsSessionsKey is the set of the duels counted in the aggregate of a user
+func userStatsSessionsKey(user_id string) string {
+	return fmt.Sprintf("stats:user:%s:sessions", user_id)
+}
+
+// userStatsPendingKey holds the duels recorded before the aggregate of a user was initialized
+func userStatsPendingKey(user_id string) string {
+	return fmt.Sprintf("stats:user:%s:pending", user_id)
+}
+
+// A duel is counted once. Until the aggregate is initialized its counters are kept pending
+// so that the initialization can add the duels stored after it listed them.
+var incrUserStatsScript = redis.NewScript(`
+if redis.call("SADD", KEYS[2], ARGV[1]) == 0 then
+	return 0
+end
+if redis.call("EXISTS", KEYS[1]) == 0 then
+	redis.call("HSET", KEYS[3], ARGV[1], ARGV[2])
+	return 0
+end
+for i = 3, #ARGV, 2 do
+	redis.call("HINCRBY", KEYS[1], ARGV[i], ARGV[i + 1])
+end
+return 1
+`)
+
+func (cache *Cache) IncrUserStats(user_id string, session_id string, counters map[string]int64) error {
+	ctx := context.Background()
+
+	counters_json, err := json.Marshal(counters)
+	if err != nil {
+		return fmt.Errorf("failed to marshal user stats: %w", err)
+	}
+	args := make([]interface{}, 0, len(counters)*2+2)
+	args = append(args, session_id, counters_json)
+	for field, value := range counters {
+		args = append(args, field, value)
+	}
+	keys := []string{userStatsKey(user_id), userStatsSessionsKey(user_id), userStatsPendingKey(user_id)}
+	if err := incrUserStatsScript.Run(ctx, cache.Db, keys, args...).Err(); err != nil {
+		return fmt.Errorf("failed to update user stats: %w", err)
+	}
+	return nil
+}
+
+// InitUserStats stores the aggregate of a user built from the given stored duels, adding the duels recorded
+// since they were listed. The aggregate already stored is returned when another initialization won the race.
+func (cache *Cache) InitUserStats(user_id string, counters map[string]int64, session_ids []string) (map[string]int64, error) {
+	ctx := context.Background()
+	key := userStatsKey(user_id)
+	pending_key := userStatsPendingKey(user_id)
+
+	var aggregate map[string]int64
+	transaction := func(tx *redis.Tx) error {
+		existing, err := tx.HGetAll(ctx, key).Result()
+		if err != nil {
+			return fmt.Errorf("failed to get user stats: %w", err)
+		}
+		if len(existing) > 0 {
+			aggregate = parseUserStats(existing)
+			return nil
+		}
+		pending, err := tx.HGetAll(ctx, pending_key).Result()
+		if err != nil {
+			return fmt.Errorf("failed to get pending user stats: %w", err)
+		}
+
+		listed := make(map[string]bool, len(session_ids))
+		for _, session_id := range session_ids {
+			listed[session_id] = true
+		}
+		aggregate = make(map[string]int64, len(counters))
+		for field, value := range counters {
+			aggregate[field] = value
+		}
+		for session_id, pending_json := range pending {
+			if listed[session_id] {
+				continue
+			}
+			var pending_counters map[string]int64
+			if err := json.Unmarshal([]byte(pending_json), &pending_counters); err != nil {
+				continue
+			}
+			for field, value := range pending_counters {
+				aggregate[field] += value
+			}
+		}
+
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.HSet(ctx, key, aggregate)
+			for _, session_id := range session_ids {
+				pipe.SAdd(ctx, userStatsSessionsKey(user_id), session_id)
+			}
+			pipe.Del(ctx, pending_key)
+			return nil
+		})
+		return err
+	}
+
+	for i := 0; i < 10; i++ {
+		err := cache.Db.Watch(ctx, transaction, key, pending_key)
+		if err == nil {
+			return aggregate, nil
+		}
+		if err != redis.TxFailedErr {
+			return nil, err
+		}
+	}
+	return nil, ErrUserStatsConflict
+}
+
+func parseUserStats(values map[string]string) map[string]int64 {
+	counters := make(map[string]int64, len(values))
+	for field, value := range values {
+		count, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			continue
+		}
+		counters[field] = count
+	}
+	return counters
+}
+
+// GetUserStats returns the counters of a user, nil if they were never initialized
+func (cache *Cache) GetUserStats(user_id string) (map[string]int64, error) {
+	ctx := context.Background()
+
+	values, err := cache.Db.HGetAll(ctx, userStatsKey(user_id)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user stats: %w", err)
+	}
+	if len(values) == 0 {
+		return nil, nil
+	}
+	return parseUserStats(values), nil
+}
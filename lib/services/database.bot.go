@@ -0,0 +1,79 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	basepool "github.com/ciphrpool/base-pool/gen"
+)
+
+var ErrBotAccountNotFound = errors.New("bot account not found")
+
+// The bot account is marked in the same statement, it stays marked once the bot is removed
+const upsertBot = `
+WITH account AS (
+	UPDATE users SET is_bot = true WHERE id = $5 RETURNING id
+)
+INSERT INTO bots (id, name, description, difficulty, user_id)
+SELECT $1, $2, $3, $4, account.id FROM account
+ON CONFLICT (id) DO UPDATE
+SET name = EXCLUDED.name, description = EXCLUDED.description, difficulty = EXCLUDED.difficulty, user_id = EXCLUDED.user_id
+`
+
+// UpsertBot stores a house bot in the catalogue and marks its account as a bot
+func UpsertBot(ctx context.Context, db basepool.DBTX, bot BotData) error {
+	tag, err := db.Exec(ctx, upsertBot, bot.ID, bot.Name, bot.Description, string(bot.Difficulty), bot.UserID)
+	if err != nil {
+		return fmt.Errorf("failed to store bot: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrBotAccountNotFound
+	}
+	return nil
+}
+
+const listBots = `
+SELECT b.id, b.name, b.description, b.difficulty, u.id, u.tag, u.username, u.elo
+FROM bots b
+JOIN users u ON u.id = b.user_id
+ORDER BY array_position(ARRAY['easy', 'medium', 'hard'], b.difficulty), b.name
+`
+
+// ListBots returns the house bots from the easiest one, with the current profile of their account
+func ListBots(ctx context.Context, db basepool.DBTX) ([]BotData, error) {
+	rows, err := db.Query(ctx, listBots)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list bots: %w", err)
+	}
+	defer rows.Close()
+
+	var bots []BotData
+	for rows.Next() {
+		var bot BotData
+		var difficulty string
+		var elo int32
+		if err := rows.Scan(&bot.ID, &bot.Name, &bot.Description, &difficulty, &bot.UserID, &bot.Tag, &bot.Username, &elo); err != nil {
+			return nil, fmt.Errorf("failed to scan bot: %w", err)
+		}
+		bot.Difficulty = BotDifficulty(difficulty)
+		bot.Elo = uint(elo)
+		bots = append(bots, bot)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list bots: %w", err)
+	}
+	return bots, nil
+}
+
+const deleteBot = `
+DELETE FROM bots WHERE id = $1
+`
+
+func DeleteBot(ctx context.Context, db basepool.DBTX, bot_id string) (bool, error) {
+	tag, err := db.Exec(ctx, deleteBot, bot_id)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete bot: %w", err)
+	}
+	return tag.RowsAffected() > 0, nil
+}
@@ -0,0 +1,126 @@
+package services
+
+import (
+	"backend/lib/rulesets"
+	"context"
+	"encoding/json"
+	"fmt"
+
+	basepool "github.com/ciphrpool/base-pool/gen"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const countUserDuelsByType = `
+SELECT COUNT(*) FROM duel_results
+WHERE duel_type = $2 AND (p1_id = $1 OR p2_id = $1)
+`
+
+// CountUserDuelsByType returns the number of duels of the given type played by a user
+func CountUserDuelsByType(ctx context.Context, db basepool.DBTX, user_id pgtype.UUID, duel_type basepool.DuelType) (int, error) {
+	var count int64
+	err := db.QueryRow(ctx, countUserDuelsByType, user_id, duel_type).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count user duels: %w", err)
+	}
+	return int(count), nil
+}
+
+const lockDuelResult = `
+SELECT pg_advisory_xact_lock(hashtext($1))
+`
+
+const duelResultExists = `
+SELECT EXISTS(SELECT 1 FROM duel_results WHERE session_id = $1::uuid)
+`
+
+// LockDuelResult serializes the processing of a duel session until the end of the transaction
+// and tells if its result has already been stored
+func LockDuelResult(ctx context.Context, tx basepool.DBTX, session_id string) (bool, error) {
+	if _, err := tx.Exec(ctx, lockDuelResult, session_id); err != nil {
+		return false, fmt.Errorf("failed to lock duel result: %w", err)
+	}
+
+	var exists bool
+	if err := tx.QueryRow(ctx, duelResultExists, session_id).Scan(&exists); err != nil {
+		return false, fmt.Errorf("failed to check duel result: %w", err)
+	}
+	return exists, nil
+}
+
+type HeadToHeadRow struct {
+	SessionID     pgtype.UUID
+	Date          pgtype.Timestamptz
+	DuelType      string
+	DuelOutcome   string
+	WinningMethod string
+	Duration      int32
+	IsP1          bool
+}
+
+const listHeadToHead = `
+SELECT session_id, date, duel_type::text, duel_outcome::text, winning_method::text, duration, p1_id = $1
+FROM duel_results
+WHERE (p1_id = $1 AND p2_id = $2) OR (p1_id = $2 AND p2_id = $1)
+ORDER BY date DESC
+`
+
+// ListHeadToHead returns every duel played between two users from the most recent one
+func ListHeadToHead(ctx context.Context, db basepool.DBTX, user_id pgtype.UUID, opponent_id pgtype.UUID) ([]HeadToHeadRow, error) {
+	rows, err := db.Query(ctx, listHeadToHead, user_id, opponent_id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list head to head duels: %w", err)
+	}
+	defer rows.Close()
+
+	var duels []HeadToHeadRow
+	for rows.Next() {
+		var row HeadToHeadRow
+		if err := rows.Scan(&row.SessionID, &row.Date, &row.DuelType, &row.DuelOutcome, &row.WinningMethod, &row.Duration, &row.IsP1); err != nil {
+			return nil, fmt.Errorf("failed to scan head to head duel: %w", err)
+		}
+		duels = append(duels, row)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list head to head duels: %w", err)
+	}
+	return duels, nil
+}
+
+// DuelResultExists tells if the result of a duel session has been stored
+func DuelResultExists(ctx context.Context, db basepool.DBTX, session_id string) (bool, error) {
+	var exists bool
+	if err := db.QueryRow(ctx, duelResultExists, session_id).Scan(&exists); err != nil {
+		return false, fmt.Errorf("failed to check duel result: %w", err)
+	}
+	return exists, nil
+}
+
+const updateDuelResultEloDeltas = `
+UPDATE duel_results SET p1_elo_delta = $2, p2_elo_delta = $3 WHERE session_id = $1::uuid
+`
+
+// UpdateDuelResultEloDeltas records the rating changes applied after a duel result was stored
+func UpdateDuelResultEloDeltas(ctx context.Context, db basepool.DBTX, session_id string, p1_elo_delta int, p2_elo_delta int) error {
+	if _, err := db.Exec(ctx, updateDuelResultEloDeltas, session_id, p1_elo_delta, p2_elo_delta); err != nil {
+		return fmt.Errorf("failed to update duel result elo deltas: %w", err)
+	}
+	return nil
+}
+
+const insertDuelResultRuleset = `
+INSERT INTO duel_result_rulesets (session_id, ruleset)
+VALUES ($1::uuid, $2::jsonb)
+ON CONFLICT (session_id) DO NOTHING
+`
+
+// InsertDuelResultRuleset stores the ruleset a duel was played with alongside its result
+func InsertDuelResultRuleset(ctx context.Context, db basepool.DBTX, session_id string, ruleset rulesets.Ruleset) error {
+	data, err := json.Marshal(ruleset)
+	if err != nil {
+		return fmt.Errorf("failed to marshal duel ruleset: %w", err)
+	}
+	if _, err := db.Exec(ctx, insertDuelResultRuleset, session_id, string(data)); err != nil {
+		return fmt.Errorf("failed to store duel ruleset: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	basepool "github.com/ciphrpool/base-pool/gen"
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	ErrFlaggedDuelNotFound = errors.New("flagged duel not found")
+)
+
+type FlaggedDuelReview string
+
+const (
+	FlaggedDuelApproved  FlaggedDuelReview = "approved"
+	FlaggedDuelDismissed FlaggedDuelReview = "dismissed"
+)
+
+type FlaggedDuelData struct {
+	SessionID string                `json:"session_id"`
+	DuelType  basepool.DuelType     `json:"duel_type"`
+	P1        DuelPlayerSummaryData `json:"p1"`
+	P2        DuelPlayerSummaryData `json:"p2"`
+	Result    json.RawMessage       `json:"result"`
+	Anomalies []string              `json:"anomalies"`
+	FlaggedAt time.Time             `json:"flagged_at"`
+}
+
+// The players are read from the session data embedded in the flagged result
+func (flagged *FlaggedDuelData) readPlayers() error {
+	var result struct {
+		SessionData DuelSessionData `json:"session_data"`
+	}
+	if err := json.Unmarshal(flagged.Result, &result); err != nil {
+		return fmt.Errorf("failed to unmarshal flagged duel result: %w", err)
+	}
+	flagged.P1 = result.SessionData.P1
+	flagged.P2 = result.SessionData.P2
+	return nil
+}
+
+const insertFlaggedDuel = `
+INSERT INTO duel_result_flags (session_id, duel_type, result, anomalies, flagged_at)
+VALUES ($1::uuid, $2, $3::jsonb, $4, $5)
+ON CONFLICT (session_id) DO NOTHING
+`
+
+// InsertFlaggedDuel stores a suspicious result for moderation alongside the result itself
+// and tells if it was not flagged yet
+func InsertFlaggedDuel(ctx context.Context, db basepool.DBTX, flagged FlaggedDuelData) (bool, error) {
+	tag, err := db.Exec(ctx, insertFlaggedDuel, flagged.SessionID, flagged.DuelType, string(flagged.Result), flagged.Anomalies, flagged.FlaggedAt)
+	if err != nil {
+		return false, fmt.Errorf("failed to store flagged duel: %w", err)
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+const countPendingFlaggedDuels = `
+SELECT COUNT(*) FROM duel_result_flags WHERE review IS NULL
+`
+
+const listPendingFlaggedDuels = `
+SELECT session_id::text, duel_type, result::text, anomalies, flagged_at
+FROM duel_result_flags
+WHERE review IS NULL
+ORDER BY flagged_at DESC, session_id
+OFFSET $1 LIMIT $2
+`
+
+// ListFlaggedDuels returns the flagged duels awaiting a review from the most recent one
+func ListFlaggedDuels(ctx context.Context, db basepool.DBTX, offset int, limit int) ([]FlaggedDuelData, int64, error) {
+	var total int64
+	if err := db.QueryRow(ctx, countPendingFlaggedDuels).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count flagged duels: %w", err)
+	}
+
+	rows, err := db.Query(ctx, listPendingFlaggedDuels, offset, limit)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list flagged duels: %w", err)
+	}
+	defer rows.Close()
+
+	flagged_duels := []FlaggedDuelData{}
+	for rows.Next() {
+		flagged, err := scanFlaggedDuel(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		flagged_duels = append(flagged_duels, flagged)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("failed to list flagged duels: %w", err)
+	}
+	return flagged_duels, total, nil
+}
+
+const lockPendingFlaggedDuel = `
+SELECT session_id::text, duel_type, result::text, anomalies, flagged_at
+FROM duel_result_flags
+WHERE session_id = $1::uuid AND review IS NULL
+FOR UPDATE
+`
+
+// LockFlaggedDuel returns a flagged duel awaiting a review and locks it until the end of the transaction
+func LockFlaggedDuel(ctx context.Context, tx basepool.DBTX, session_id string) (FlaggedDuelData, error) {
+	flagged, err := scanFlaggedDuel(tx.QueryRow(ctx, lockPendingFlaggedDuel, session_id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return FlaggedDuelData{}, ErrFlaggedDuelNotFound
+	}
+	return flagged, err
+}
+
+func scanFlaggedDuel(row pgx.Row) (FlaggedDuelData, error) {
+	var flagged FlaggedDuelData
+	var result string
+	if err := row.Scan(&flagged.SessionID, &flagged.DuelType, &result, &flagged.Anomalies, &flagged.FlaggedAt); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return flagged, err
+		}
+		return flagged, fmt.Errorf("failed to scan flagged duel: %w", err)
+	}
+	flagged.Result = json.RawMessage(result)
+	if err := flagged.readPlayers(); err != nil {
+		return flagged, err
+	}
+	return flagged, nil
+}
+
+const reviewFlaggedDuel = `
+UPDATE duel_result_flags SET review = $2, reviewed_at = now()
+WHERE session_id = $1::uuid AND review IS NULL
+`
+
+// ReviewFlaggedDuel closes the moderation of a flagged duel
+func ReviewFlaggedDuel(ctx context.Context, db basepool.DBTX, session_id string, review FlaggedDuelReview) error {
+	tag, err := db.Exec(ctx, reviewFlaggedDuel, session_id, string(review))
+	if err != nil {
+		return fmt.Errorf("failed to review flagged duel: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrFlaggedDuelNotFound
+	}
+	return nil
+}
@@ -0,0 +1,190 @@
+package services
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	basepool "github.com/ciphrpool/base-pool/gen"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+var ErrInvalidCursor = errors.New("invalid history cursor")
+
+type HistoryOutcome string
+
+const (
+	HistoryWon  HistoryOutcome = "won"
+	HistoryLost HistoryOutcome = "lost"
+	HistoryDraw HistoryOutcome = "draw"
+)
+
+// HistoryCursor is the position of the last duel of a history page
+type HistoryCursor struct {
+	Date      time.Time
+	SessionID pgtype.UUID
+}
+
+// Encode returns the opaque form of the cursor given to clients
+func (cursor HistoryCursor) Encode() string {
+	raw := fmt.Sprintf("%d:%s", cursor.Date.UnixMicro(), UUIDToString(cursor.SessionID))
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func DecodeHistoryCursor(encoded string) (HistoryCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return HistoryCursor{}, ErrInvalidCursor
+	}
+	micros, session_id, found := strings.Cut(string(raw), ":")
+	if !found {
+		return HistoryCursor{}, ErrInvalidCursor
+	}
+	value, err := strconv.ParseInt(micros, 10, 64)
+	if err != nil {
+		return HistoryCursor{}, ErrInvalidCursor
+	}
+	id, err := StringToUUID(session_id)
+	if err != nil {
+		return HistoryCursor{}, ErrInvalidCursor
+	}
+	return HistoryCursor{Date: time.UnixMicro(value), SessionID: id}, nil
+}
+
+// DuelHistoryFilter selects the duels of a user, unset fields do not filter
+type DuelHistoryFilter struct {
+	UserID        pgtype.UUID
+	OpponentID    pgtype.UUID
+	DuelType      string
+	Outcome       HistoryOutcome
+	WinningMethod string
+	From          time.Time
+	To            time.Time
+}
+
+// DuelHistoryRow is the JSON contract of the duels returned by /duel/history, keep its fields stable
+type DuelHistoryRow struct {
+	SessionID     pgtype.UUID        `json:"session_id"`
+	Date          pgtype.Timestamptz `json:"date"`
+	DuelType      string             `json:"duel_type"`
+	DuelOutcome   string             `json:"duel_outcome"`
+	WinningMethod string             `json:"winning_method"`
+	Duration      int32              `json:"duration"`
+	P1EloDelta    int32              `json:"p1_elo_delta"`
+	P2EloDelta    int32              `json:"p2_elo_delta"`
+	P1Tag         string             `json:"p1_tag"`
+	P1Username    string             `json:"p1_username"`
+	P2Tag         string             `json:"p2_tag"`
+	P2Username    string             `json:"p2_username"`
+	Ruleset       json.RawMessage    `json:"ruleset,omitempty"`
+}
+
+// The outcome is matched with the duel outcome expected when the user played p1 ($5) or p2 ($6)
+const duelHistoryFilter = `
+WHERE (r.p1_id = $1 OR r.p2_id = $1)
+	AND ($2::uuid IS NULL OR r.p1_id = $2 OR r.p2_id = $2)
+	AND ($3::text IS NULL OR r.duel_type::text = $3)
+	AND ($4::text IS NULL OR r.winning_method::text = $4)
+	AND ($5::text IS NULL OR (r.p1_id = $1 AND r.duel_outcome::text = $5) OR (r.p2_id = $1 AND r.duel_outcome::text = $6::text))
+	AND ($7::timestamptz IS NULL OR r.date >= $7)
+	AND ($8::timestamptz IS NULL OR r.date < $8)
+`
+
+const listDuelHistory = `
+SELECT r.session_id, r.date, r.duel_type::text, r.duel_outcome::text, r.winning_method::text, r.duration,
+	r.p1_elo_delta, r.p2_elo_delta, p1.tag, p1.username, p2.tag, p2.username, rr.ruleset::text
+FROM duel_results r
+JOIN users p1 ON p1.id = r.p1_id
+JOIN users p2 ON p2.id = r.p2_id
+LEFT JOIN duel_result_rulesets rr ON rr.session_id = r.session_id
+` + duelHistoryFilter + `
+	AND ($9::timestamptz IS NULL OR (r.date, r.session_id) < ($9, $10::uuid))
+ORDER BY r.date DESC, r.session_id DESC
+LIMIT $11
+`
+
+const countDuelHistory = `
+SELECT COUNT(*) FROM duel_results r
+` + duelHistoryFilter
+
+func optionalText(value string) pgtype.Text {
+	return pgtype.Text{String: value, Valid: value != ""}
+}
+
+func optionalTime(value time.Time) pgtype.Timestamptz {
+	return pgtype.Timestamptz{Time: value, Valid: !value.IsZero()}
+}
+
+func (filter DuelHistoryFilter) args() []interface{} {
+	var p1_outcome, p2_outcome string
+	switch filter.Outcome {
+	case HistoryWon:
+		p1_outcome, p2_outcome = string(basepool.DuelOutcomeP1WON), string(basepool.DuelOutcomeP2WON)
+	case HistoryLost:
+		p1_outcome, p2_outcome = string(basepool.DuelOutcomeP2WON), string(basepool.DuelOutcomeP1WON)
+	case HistoryDraw:
+		p1_outcome, p2_outcome = string(basepool.DuelOutcomeDraw), string(basepool.DuelOutcomeDraw)
+	}
+	return []interface{}{
+		filter.UserID,
+		filter.OpponentID,
+		optionalText(filter.DuelType),
+		optionalText(filter.WinningMethod),
+		optionalText(p1_outcome),
+		optionalText(p2_outcome),
+		optionalTime(filter.From),
+		optionalTime(filter.To),
+	}
+}
+
+// ListDuelHistory returns a page of the duels of a user from the most recent one.
+// Duels are ordered by date then session id so that a cursor never skips nor repeats a duel.
+func ListDuelHistory(ctx context.Context, db basepool.DBTX, filter DuelHistoryFilter, cursor *HistoryCursor, limit int) ([]DuelHistoryRow, error) {
+	args := filter.args()
+	if cursor != nil {
+		args = append(args, optionalTime(cursor.Date), cursor.SessionID)
+	} else {
+		args = append(args, pgtype.Timestamptz{}, pgtype.UUID{})
+	}
+	args = append(args, limit)
+
+	rows, err := db.Query(ctx, listDuelHistory, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list duel history: %w", err)
+	}
+	defer rows.Close()
+
+	duels := []DuelHistoryRow{}
+	for rows.Next() {
+		var row DuelHistoryRow
+		var ruleset pgtype.Text
+		err := rows.Scan(&row.SessionID, &row.Date, &row.DuelType, &row.DuelOutcome, &row.WinningMethod, &row.Duration,
+			&row.P1EloDelta, &row.P2EloDelta, &row.P1Tag, &row.P1Username, &row.P2Tag, &row.P2Username, &ruleset)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan duel history: %w", err)
+		}
+		// Duels stored before rulesets existed have none
+		if ruleset.Valid {
+			row.Ruleset = json.RawMessage(ruleset.String)
+		}
+		duels = append(duels, row)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list duel history: %w", err)
+	}
+	return duels, nil
+}
+
+// CountDuelHistory returns the number of duels of a user matching the filter
+func CountDuelHistory(ctx context.Context, db basepool.DBTX, filter DuelHistoryFilter) (int64, error) {
+	var total int64
+	if err := db.QueryRow(ctx, countDuelHistory, filter.args()...).Scan(&total); err != nil {
+		return 0, fmt.Errorf("failed to count duel history: %w", err)
+	}
+	return total, nil
+}
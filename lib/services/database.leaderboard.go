@@ -0,0 +1,57 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	basepool "github.com/ciphrpool/base-pool/gen"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// rankedPlayer is the rule deciding who appears in the leaderboards, users become ranked with their first ranked duel
+// and the house bots never are
+const rankedPlayer = `
+NOT u.is_bot AND EXISTS (SELECT 1 FROM duel_results r WHERE r.duel_type::text = 'ranked' AND (r.p1_id = u.id OR r.p2_id = u.id))
+`
+
+const isRankedPlayer = `
+SELECT EXISTS (SELECT 1 FROM users u WHERE u.id = $1 AND ` + rankedPlayer + `)
+`
+
+// IsRankedPlayer tells if a user belongs in the leaderboards
+func IsRankedPlayer(ctx context.Context, db basepool.DBTX, user_id pgtype.UUID) (bool, error) {
+	var ranked bool
+	if err := db.QueryRow(ctx, isRankedPlayer, user_id).Scan(&ranked); err != nil {
+		return false, fmt.Errorf("failed to check ranked player: %w", err)
+	}
+	return ranked, nil
+}
+
+const listLeaderboardPlayers = `
+SELECT u.id, u.username, u.tag, u.country, u.elo FROM users u WHERE ` + rankedPlayer
+
+// ListLeaderboardPlayers returns every ranked player with its current rating
+func ListLeaderboardPlayers(ctx context.Context, db basepool.DBTX) ([]LeaderboardPlayer, error) {
+	rows, err := db.Query(ctx, listLeaderboardPlayers)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list leaderboard players: %w", err)
+	}
+	defer rows.Close()
+
+	var players []LeaderboardPlayer
+	for rows.Next() {
+		var player LeaderboardPlayer
+		var id pgtype.UUID
+		var elo int32
+		if err := rows.Scan(&id, &player.Username, &player.Tag, &player.Country, &elo); err != nil {
+			return nil, fmt.Errorf("failed to scan leaderboard player: %w", err)
+		}
+		player.UserID = UUIDToString(id)
+		player.Elo = int(elo)
+		players = append(players, player)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list leaderboard players: %w", err)
+	}
+	return players, nil
+}
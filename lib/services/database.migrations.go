@@ -0,0 +1,90 @@
+package services
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"sort"
+)
+
+// The schema generated by base-pool is extended by the tables and enum values this service owns
+//
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+const createSchemaMigrations = `
+CREATE TABLE IF NOT EXISTS mcs_schema_migrations (
+	name text PRIMARY KEY,
+	applied_at timestamptz NOT NULL DEFAULT now()
+)
+`
+
+const lockSchemaMigrations = `
+SELECT pg_advisory_xact_lock(hashtext('mcs_schema_migrations'))
+`
+
+const schemaMigrationApplied = `
+SELECT EXISTS(SELECT 1 FROM mcs_schema_migrations WHERE name = $1)
+`
+
+const insertSchemaMigration = `
+INSERT INTO mcs_schema_migrations (name) VALUES ($1)
+`
+
+// Migrate applies the migrations not applied yet in the order of their names.
+// Each migration runs in its own transaction, concurrent servers wait for each other.
+func (db *Database) Migrate(ctx context.Context) error {
+	if _, err := db.Pool.Exec(ctx, createSchemaMigrations); err != nil {
+		return fmt.Errorf("failed to create the migrations table: %w", err)
+	}
+
+	names, err := fs.Glob(migrations, "migrations/*.sql")
+	if err != nil {
+		return fmt.Errorf("failed to list migrations: %w", err)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if err := db.migrate(ctx, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (db *Database) migrate(ctx context.Context, name string) error {
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, lockSchemaMigrations); err != nil {
+		return fmt.Errorf("failed to lock migrations: %w", err)
+	}
+	var applied bool
+	if err := tx.QueryRow(ctx, schemaMigrationApplied, name).Scan(&applied); err != nil {
+		return fmt.Errorf("failed to check migration %s: %w", name, err)
+	}
+	if applied {
+		return nil
+	}
+
+	migration, err := migrations.ReadFile(name)
+	if err != nil {
+		return fmt.Errorf("failed to read migration %s: %w", name, err)
+	}
+	if _, err := tx.Exec(ctx, string(migration)); err != nil {
+		return fmt.Errorf("failed to apply migration %s: %w", name, err)
+	}
+	if _, err := tx.Exec(ctx, insertSchemaMigration, name); err != nil {
+		return fmt.Errorf("failed to record migration %s: %w", name, err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit migration %s: %w", name, err)
+	}
+	slog.Info("Db migration applied", "migration", name)
+	return nil
+}
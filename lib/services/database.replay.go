@@ -0,0 +1,85 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	basepool "github.com/ciphrpool/base-pool/gen"
+	"github.com/jackc/pgx/v5"
+)
+
+type ReplayData struct {
+	SessionID  string    `json:"session_id"`
+	Size       int64     `json:"size"`
+	UploadedAt time.Time `json:"uploaded_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+const upsertReplay = `
+INSERT INTO duel_replays (session_id, size, uploaded_at, expires_at)
+VALUES ($1::uuid, $2, $3, $4)
+ON CONFLICT (session_id) DO UPDATE
+SET size = EXCLUDED.size, uploaded_at = EXCLUDED.uploaded_at, expires_at = EXCLUDED.expires_at
+`
+
+func UpsertReplay(ctx context.Context, db basepool.DBTX, replay ReplayData) error {
+	if _, err := db.Exec(ctx, upsertReplay, replay.SessionID, replay.Size, replay.UploadedAt, replay.ExpiresAt); err != nil {
+		return fmt.Errorf("failed to store replay: %w", err)
+	}
+	return nil
+}
+
+const getReplay = `
+SELECT session_id::text, size, uploaded_at, expires_at FROM duel_replays WHERE session_id = $1::uuid
+`
+
+// GetReplay returns the replay of a duel session, nil if there is none
+func GetReplay(ctx context.Context, db basepool.DBTX, session_id string) (*ReplayData, error) {
+	var replay ReplayData
+	err := db.QueryRow(ctx, getReplay, session_id).Scan(&replay.SessionID, &replay.Size, &replay.UploadedAt, &replay.ExpiresAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get replay: %w", err)
+	}
+	return &replay, nil
+}
+
+const listExpiredReplays = `
+SELECT session_id::text FROM duel_replays WHERE expires_at <= $1 ORDER BY expires_at LIMIT $2
+`
+
+// ListExpiredReplays returns the sessions whose replay expired before the given time
+func ListExpiredReplays(ctx context.Context, db basepool.DBTX, before time.Time, limit int64) ([]string, error) {
+	rows, err := db.Query(ctx, listExpiredReplays, before, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list expired replays: %w", err)
+	}
+	defer rows.Close()
+
+	var session_ids []string
+	for rows.Next() {
+		var session_id string
+		if err := rows.Scan(&session_id); err != nil {
+			return nil, fmt.Errorf("failed to scan expired replay: %w", err)
+		}
+		session_ids = append(session_ids, session_id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list expired replays: %w", err)
+	}
+	return session_ids, nil
+}
+
+const deleteReplay = `
+DELETE FROM duel_replays WHERE session_id = $1::uuid
+`
+
+func DeleteReplay(ctx context.Context, db basepool.DBTX, session_id string) error {
+	if _, err := db.Exec(ctx, deleteReplay, session_id); err != nil {
+		return fmt.Errorf("failed to delete replay: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,319 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	basepool "github.com/ciphrpool/base-pool/gen"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+type SeasonStatus string
+
+const (
+	SeasonStatusActive   SeasonStatus = "active"
+	SeasonStatusClosing  SeasonStatus = "closing"
+	SeasonStatusFinished SeasonStatus = "finished"
+)
+
+type SeasonReward struct {
+	Name   string `json:"name"`
+	MinElo int    `json:"min_elo"`
+}
+
+type SeasonData struct {
+	ID           string         `json:"id"`
+	Number       int64          `json:"number"`
+	Name         string         `json:"name"`
+	Status       SeasonStatus   `json:"status"`
+	StartAt      time.Time      `json:"start_at"`
+	EndAt        time.Time      `json:"end_at"`
+	EndedAt      time.Time      `json:"ended_at,omitempty"`
+	Rewards      []SeasonReward `json:"rewards"`
+	Archived     bool           `json:"archived"`
+	RatingsReset bool           `json:"ratings_reset"`
+}
+
+type SeasonStanding struct {
+	Rank     int64  `json:"rank"`
+	UserID   string `json:"user_id"`
+	Username string `json:"username"`
+	Tag      string `json:"tag"`
+	Country  string `json:"country"`
+	Elo      int64  `json:"elo"`
+	PeakElo  int64  `json:"peak_elo"`
+	Reward   string `json:"reward,omitempty"`
+}
+
+var ErrSeasonNotFound = errors.New("season not found")
+
+const seasonColumns = `
+id::text, number, name, status, start_at, end_at, ended_at, rewards::text, archived, ratings_reset
+`
+
+func scanSeason(row pgx.Row) (SeasonData, error) {
+	var season SeasonData
+	var status string
+	var ended_at pgtype.Timestamptz
+	var rewards_json string
+	err := row.Scan(&season.ID, &season.Number, &season.Name, &status, &season.StartAt, &season.EndAt, &ended_at, &rewards_json, &season.Archived, &season.RatingsReset)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return season, ErrSeasonNotFound
+		}
+		return season, fmt.Errorf("failed to get season: %w", err)
+	}
+	season.Status = SeasonStatus(status)
+	if ended_at.Valid {
+		season.EndedAt = ended_at.Time
+	}
+	if err := json.Unmarshal([]byte(rewards_json), &season.Rewards); err != nil {
+		return season, fmt.Errorf("failed to unmarshal season rewards: %w", err)
+	}
+	return season, nil
+}
+
+// The number follows the last season and an unnamed season is named after it
+const insertSeason = `
+WITH next AS (SELECT COALESCE(MAX(number), 0) + 1 AS number FROM seasons)
+INSERT INTO seasons (id, number, name, status, start_at, end_at, rewards)
+SELECT $1::uuid, next.number, COALESCE(NULLIF($2, ''), 'Season ' || next.number), $3, $4, $5, $6::jsonb
+FROM next
+RETURNING number, name
+`
+
+// CreateSeason stores a new season, which becomes the current one
+func CreateSeason(ctx context.Context, db basepool.DBTX, season *SeasonData) error {
+	rewards_json, err := json.Marshal(season.Rewards)
+	if err != nil {
+		return fmt.Errorf("failed to marshal season rewards: %w", err)
+	}
+	season.ID = uuid.New().String()
+	err = db.QueryRow(ctx, insertSeason, season.ID, season.Name, string(season.Status), season.StartAt, season.EndAt, string(rewards_json)).Scan(&season.Number, &season.Name)
+	if err != nil {
+		return fmt.Errorf("failed to create season: %w", err)
+	}
+	return nil
+}
+
+const getSeason = `
+SELECT ` + seasonColumns + ` FROM seasons WHERE id = $1::uuid
+`
+
+func GetSeason(ctx context.Context, db basepool.DBTX, season_id string) (SeasonData, error) {
+	if _, err := uuid.Parse(season_id); err != nil {
+		return SeasonData{}, ErrSeasonNotFound
+	}
+	return scanSeason(db.QueryRow(ctx, getSeason, season_id))
+}
+
+const getCurrentSeason = `
+SELECT ` + seasonColumns + ` FROM seasons WHERE status <> 'finished'
+`
+
+// GetCurrentSeason returns the season being played or being closed, nil between two seasons
+func GetCurrentSeason(ctx context.Context, db basepool.DBTX) (*SeasonData, error) {
+	season, err := scanSeason(db.QueryRow(ctx, getCurrentSeason))
+	if errors.Is(err, ErrSeasonNotFound) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return &season, nil
+}
+
+const updateSeason = `
+UPDATE seasons SET status = $2, ended_at = $3, archived = $4, ratings_reset = $5 WHERE id = $1::uuid
+`
+
+// UpdateSeason records the progress of a season, its name, dates and rewards are fixed at its creation
+func UpdateSeason(ctx context.Context, db basepool.DBTX, season *SeasonData) error {
+	if _, err := db.Exec(ctx, updateSeason, season.ID, string(season.Status), optionalTime(season.EndedAt), season.Archived, season.RatingsReset); err != nil {
+		return fmt.Errorf("failed to update season: %w", err)
+	}
+	return nil
+}
+
+const countSeasons = `
+SELECT COUNT(*) FROM seasons
+`
+
+// CountSeasons returns how many seasons were ever opened
+func CountSeasons(ctx context.Context, db basepool.DBTX) (int64, error) {
+	var count int64
+	if err := db.QueryRow(ctx, countSeasons).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count seasons: %w", err)
+	}
+	return count, nil
+}
+
+const listSeasons = `
+SELECT ` + seasonColumns + ` FROM seasons ORDER BY number DESC
+`
+
+// ListSeasons returns every season from the most recent one
+func ListSeasons(ctx context.Context, db basepool.DBTX) ([]SeasonData, error) {
+	rows, err := db.Query(ctx, listSeasons)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list seasons: %w", err)
+	}
+	defer rows.Close()
+
+	seasons := []SeasonData{}
+	for rows.Next() {
+		season, err := scanSeason(rows)
+		if err != nil {
+			return nil, err
+		}
+		seasons = append(seasons, season)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list seasons: %w", err)
+	}
+	return seasons, nil
+}
+
+const recordSeasonPeak = `
+INSERT INTO season_peaks (season_id, user_id, elo)
+VALUES ($1::uuid, $2, $3)
+ON CONFLICT (season_id, user_id) DO UPDATE SET elo = GREATEST(season_peaks.elo, EXCLUDED.elo)
+`
+
+// RecordSeasonPeak keeps the highest rating reached by a player during a season
+func RecordSeasonPeak(ctx context.Context, db basepool.DBTX, season_id string, user_id pgtype.UUID, elo int) error {
+	if _, err := db.Exec(ctx, recordSeasonPeak, season_id, user_id, elo); err != nil {
+		return fmt.Errorf("failed to record season peak: %w", err)
+	}
+	return nil
+}
+
+const getSeasonPeaks = `
+SELECT user_id::text, elo FROM season_peaks WHERE season_id = $1::uuid
+`
+
+func GetSeasonPeaks(ctx context.Context, db basepool.DBTX, season_id string) (map[string]int64, error) {
+	rows, err := db.Query(ctx, getSeasonPeaks, season_id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get season peaks: %w", err)
+	}
+	defer rows.Close()
+
+	peaks := make(map[string]int64)
+	for rows.Next() {
+		var user_id string
+		var peak int64
+		if err := rows.Scan(&user_id, &peak); err != nil {
+			return nil, fmt.Errorf("failed to scan season peak: %w", err)
+		}
+		peaks[user_id] = peak
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get season peaks: %w", err)
+	}
+	return peaks, nil
+}
+
+const deleteSeasonStandings = `
+DELETE FROM season_standings WHERE season_id = $1::uuid
+`
+
+const insertSeasonStandings = `
+INSERT INTO season_standings (season_id, rank, user_id, username, tag, country, elo, peak_elo, reward)
+SELECT $1::uuid, s.rank, s.user_id::uuid, s.username, s.tag, s.country, s.elo, s.peak_elo, s.reward
+FROM unnest($2::bigint[], $3::text[], $4::text[], $5::text[], $6::text[], $7::bigint[], $8::bigint[], $9::text[])
+	AS s(rank, user_id, username, tag, country, elo, peak_elo, reward)
+`
+
+// SetSeasonStandings archives the final standings of a season, replacing a previous attempt
+func (db *Database) SetSeasonStandings(ctx context.Context, season_id string, standings []SeasonStanding) error {
+	ranks := make([]int64, len(standings))
+	user_ids := make([]string, len(standings))
+	usernames := make([]string, len(standings))
+	tags := make([]string, len(standings))
+	countries := make([]string, len(standings))
+	elos := make([]int64, len(standings))
+	peaks := make([]int64, len(standings))
+	rewards := make([]string, len(standings))
+	for i, standing := range standings {
+		ranks[i] = standing.Rank
+		user_ids[i] = standing.UserID
+		usernames[i] = standing.Username
+		tags[i] = standing.Tag
+		countries[i] = standing.Country
+		elos[i] = standing.Elo
+		peaks[i] = standing.PeakElo
+		rewards[i] = standing.Reward
+	}
+
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, deleteSeasonStandings, season_id); err != nil {
+		return fmt.Errorf("failed to archive season standings: %w", err)
+	}
+	if _, err := tx.Exec(ctx, insertSeasonStandings, season_id, ranks, user_ids, usernames, tags, countries, elos, peaks, rewards); err != nil {
+		return fmt.Errorf("failed to archive season standings: %w", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+const getSeasonStandings = `
+SELECT rank, user_id::text, username, tag, country, elo, peak_elo, reward
+FROM season_standings
+WHERE season_id = $1::uuid
+ORDER BY rank
+OFFSET $2 LIMIT $3
+`
+
+// GetSeasonStandings returns a page of the archived standings, the next cursor is 0 on the last page
+func GetSeasonStandings(ctx context.Context, db basepool.DBTX, season_id string, cursor int64, limit int64) ([]SeasonStanding, int64, error) {
+	// One more entry is fetched to know if there is a next page
+	rows, err := db.Query(ctx, getSeasonStandings, season_id, cursor, limit+1)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to get season standings: %w", err)
+	}
+	defer rows.Close()
+
+	standings := []SeasonStanding{}
+	for rows.Next() {
+		var standing SeasonStanding
+		if err := rows.Scan(&standing.Rank, &standing.UserID, &standing.Username, &standing.Tag, &standing.Country, &standing.Elo, &standing.PeakElo, &standing.Reward); err != nil {
+			return nil, 0, fmt.Errorf("failed to scan season standing: %w", err)
+		}
+		standings = append(standings, standing)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("failed to get season standings: %w", err)
+	}
+
+	var next_cursor int64
+	if int64(len(standings)) > limit {
+		standings = standings[:limit]
+		next_cursor = cursor + limit
+	}
+	return standings, next_cursor, nil
+}
+
+const softResetRatings = `
+UPDATE users SET elo = GREATEST($3, $1 + ROUND((elo - $1) * $2::numeric))
+`
+
+// SoftResetRatings pulls every rating toward the mean, keeping the given share of the distance
+func SoftResetRatings(ctx context.Context, db basepool.DBTX, mean int, factor float64, floor int) (int64, error) {
+	tag, err := db.Exec(ctx, softResetRatings, mean, factor, floor)
+	if err != nil {
+		return 0, fmt.Errorf("failed to reset ratings: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
@@ -0,0 +1,59 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	basepool "github.com/ciphrpool/base-pool/gen"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+type SpectatePrivacy string
+
+const (
+	SpectateEveryone SpectatePrivacy = "everyone"
+	SpectateFriends  SpectatePrivacy = "friends"
+	SpectateNobody   SpectatePrivacy = "nobody"
+)
+
+const DEFAULT_SPECTATE_PRIVACY = SpectateFriends
+
+func (privacy SpectatePrivacy) IsValid() bool {
+	switch privacy {
+	case SpectateEveryone, SpectateFriends, SpectateNobody:
+		return true
+	default:
+		return false
+	}
+}
+
+const setSpectatePrivacy = `
+INSERT INTO user_spectate_settings (user_id, privacy)
+VALUES ($1, $2)
+ON CONFLICT (user_id) DO UPDATE SET privacy = EXCLUDED.privacy, updated_at = now()
+`
+
+func SetSpectatePrivacy(ctx context.Context, db basepool.DBTX, user_id pgtype.UUID, privacy SpectatePrivacy) error {
+	if _, err := db.Exec(ctx, setSpectatePrivacy, user_id, string(privacy)); err != nil {
+		return fmt.Errorf("failed to set spectate privacy: %w", err)
+	}
+	return nil
+}
+
+const getSpectatePrivacy = `
+SELECT privacy FROM user_spectate_settings WHERE user_id = $1
+`
+
+// GetSpectatePrivacy returns the spectate privacy of a user, the default one if never set
+func GetSpectatePrivacy(ctx context.Context, db basepool.DBTX, user_id pgtype.UUID) (SpectatePrivacy, error) {
+	var privacy string
+	err := db.QueryRow(ctx, getSpectatePrivacy, user_id).Scan(&privacy)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return DEFAULT_SPECTATE_PRIVACY, nil
+	} else if err != nil {
+		return "", fmt.Errorf("failed to get spectate privacy: %w", err)
+	}
+	return SpectatePrivacy(privacy), nil
+}
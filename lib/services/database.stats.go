@@ -0,0 +1,60 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	basepool "github.com/ciphrpool/base-pool/gen"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+type DuelStatsRow struct {
+	SessionID     string
+	DuelType      string
+	DuelOutcome   string
+	WinningMethod string
+	Duration      int32
+	IsP1          bool
+	P1            [6]int32
+	P2            [6]int32
+}
+
+const listUserDuelStats = `
+SELECT session_id::text, duel_type::text, duel_outcome::text, winning_method::text, duration, p1_id = $1,
+	p1_ego_count, p1_energy, p1_corrupted_data, p1_emotional_data, p1_quantum_data, p1_logical_data,
+	p2_ego_count, p2_energy, p2_corrupted_data, p2_emotional_data, p2_quantum_data, p2_logical_data
+FROM duel_results r
+WHERE (p1_id = $1 OR p2_id = $1) AND duel_type::text <> 'practice'
+	AND NOT EXISTS (SELECT 1 FROM users b WHERE b.is_bot AND b.id IN (r.p1_id, r.p2_id))
+	AND NOT EXISTS (
+		SELECT 1 FROM duel_result_flags f
+		WHERE f.session_id = r.session_id AND f.review IS DISTINCT FROM 'approved'
+	)
+`
+
+// ListUserDuelStats returns the summary of every duel played by a user, practice duels, duels of house bots
+// and flagged results aside.
+// The resources are in the order ego count, energy, corrupted, emotional, quantum and logical data.
+func ListUserDuelStats(ctx context.Context, db basepool.DBTX, user_id pgtype.UUID) ([]DuelStatsRow, error) {
+	rows, err := db.Query(ctx, listUserDuelStats, user_id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list user duels: %w", err)
+	}
+	defer rows.Close()
+
+	var stats []DuelStatsRow
+	for rows.Next() {
+		var row DuelStatsRow
+		err := rows.Scan(&row.SessionID, &row.DuelType, &row.DuelOutcome, &row.WinningMethod, &row.Duration, &row.IsP1,
+			&row.P1[0], &row.P1[1], &row.P1[2], &row.P1[3], &row.P1[4], &row.P1[5],
+			&row.P2[0], &row.P2[1], &row.P2[2], &row.P2[3], &row.P2[4], &row.P2[5])
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan user duel: %w", err)
+		}
+		stats = append(stats, row)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list user duels: %w", err)
+	}
+	return stats, nil
+}
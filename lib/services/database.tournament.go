@@ -0,0 +1,226 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	basepool "github.com/ciphrpool/base-pool/gen"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+type TournamentFormat string
+
+const (
+	TournamentFormatSingleElimination TournamentFormat = "single_elimination"
+	TournamentFormatSwiss             TournamentFormat = "swiss"
+)
+
+type TournamentStatus string
+
+const (
+	TournamentStatusRegistration TournamentStatus = "registration"
+	TournamentStatusCheckIn      TournamentStatus = "check_in"
+	TournamentStatusRunning      TournamentStatus = "running"
+	TournamentStatusFinished     TournamentStatus = "finished"
+)
+
+type TournamentMatchStatus string
+
+const (
+	TournamentMatchPending  TournamentMatchStatus = "pending"
+	TournamentMatchRunning  TournamentMatchStatus = "running"
+	TournamentMatchFinished TournamentMatchStatus = "finished"
+)
+
+type TournamentPlayerData struct {
+	PID        pgtype.UUID `json:"pid"`
+	Elo        uint        `json:"elo"`
+	Tag        string      `json:"tag"`
+	Username   string      `json:"username"`
+	CheckedIn  bool        `json:"checked_in"`
+	Score      float64     `json:"score"`
+	Eliminated bool        `json:"eliminated"`
+	HadBye     bool        `json:"had_bye"`
+}
+
+type TournamentMatchData struct {
+	ID        string                `json:"id"`
+	Round     int                   `json:"round"`
+	P1        pgtype.UUID           `json:"p1"`
+	P2        pgtype.UUID           `json:"p2"` // Invalid when P1 has a bye
+	SessionID string                `json:"session_id"`
+	Winner    pgtype.UUID           `json:"winner"` // Invalid on a swiss draw
+	Status    TournamentMatchStatus `json:"status"`
+	Deadline  time.Time             `json:"deadline,omitempty"` // Set while the duel of the match is running
+	Replays   int                   `json:"replays,omitempty"`  // Number of times a drawn single elimination match was played again
+}
+
+type TournamentRoundData struct {
+	Number  int                   `json:"number"`
+	Matches []TournamentMatchData `json:"matches"`
+}
+
+type TournamentData struct {
+	ID          string                 `json:"id"`
+	Name        string                 `json:"name"`
+	Format      TournamentFormat       `json:"format"`
+	Status      TournamentStatus       `json:"status"`
+	HostID      pgtype.UUID            `json:"host_id"`
+	MaxPlayers  int                    `json:"max_players"`
+	SwissRounds int                    `json:"swiss_rounds"`
+	Players     []TournamentPlayerData `json:"players"`
+	Rounds      []TournamentRoundData  `json:"rounds"`
+	Winner      pgtype.UUID            `json:"winner"`
+	CreatedAt   time.Time              `json:"created_at"`
+}
+
+var ErrTournamentNotFound = errors.New("tournament not found")
+
+const insertTournament = `
+INSERT INTO tournaments (id, status, data, created_at)
+VALUES ($1::uuid, $2, $3::jsonb, $4)
+`
+
+func CreateTournament(ctx context.Context, db basepool.DBTX, tournament *TournamentData) (string, error) {
+	tournament.ID = uuid.New().String()
+	tournament.CreatedAt = time.Now()
+
+	tournament_json, err := json.Marshal(tournament)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal tournament: %w", err)
+	}
+	if _, err := db.Exec(ctx, insertTournament, tournament.ID, string(tournament.Status), string(tournament_json), tournament.CreatedAt); err != nil {
+		return "", fmt.Errorf("failed to create tournament: %w", err)
+	}
+	return tournament.ID, nil
+}
+
+func scanTournament(row pgx.Row) (TournamentData, error) {
+	var tournament TournamentData
+	var tournament_json string
+	if err := row.Scan(&tournament_json); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return tournament, ErrTournamentNotFound
+		}
+		return tournament, fmt.Errorf("failed to get tournament: %w", err)
+	}
+	if err := json.Unmarshal([]byte(tournament_json), &tournament); err != nil {
+		return tournament, fmt.Errorf("failed to unmarshal tournament: %w", err)
+	}
+	return tournament, nil
+}
+
+const getTournament = `
+SELECT data::text FROM tournaments WHERE id = $1::uuid
+`
+
+func GetTournament(ctx context.Context, db basepool.DBTX, tournament_id string) (TournamentData, error) {
+	if _, err := uuid.Parse(tournament_id); err != nil {
+		return TournamentData{}, ErrTournamentNotFound
+	}
+	return scanTournament(db.QueryRow(ctx, getTournament, tournament_id))
+}
+
+const lockTournament = `
+SELECT data::text FROM tournaments WHERE id = $1::uuid FOR UPDATE
+`
+
+const updateTournament = `
+UPDATE tournaments SET status = $2, data = $3::jsonb, updated_at = now() WHERE id = $1::uuid
+`
+
+// UpdateTournament applies the update to the tournament, concurrent updates of a tournament wait for each other
+func (db *Database) UpdateTournament(ctx context.Context, tournament_id string, update func(tournament *TournamentData) error) (TournamentData, error) {
+	if _, err := uuid.Parse(tournament_id); err != nil {
+		return TournamentData{}, ErrTournamentNotFound
+	}
+
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return TournamentData{}, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	tournament, err := scanTournament(tx.QueryRow(ctx, lockTournament, tournament_id))
+	if err != nil {
+		return tournament, err
+	}
+	if err := update(&tournament); err != nil {
+		return tournament, err
+	}
+
+	tournament_json, err := json.Marshal(tournament)
+	if err != nil {
+		return tournament, fmt.Errorf("failed to marshal tournament: %w", err)
+	}
+	if _, err := tx.Exec(ctx, updateTournament, tournament_id, string(tournament.Status), string(tournament_json)); err != nil {
+		return tournament, fmt.Errorf("failed to update tournament: %w", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return tournament, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return tournament, nil
+}
+
+const countTournaments = `
+SELECT COUNT(*) FROM tournaments WHERE ($1::text IS NULL OR status = $1)
+`
+
+const listTournaments = `
+SELECT data::text FROM tournaments
+WHERE ($1::text IS NULL OR status = $1)
+ORDER BY created_at DESC, id
+OFFSET $2 LIMIT $3
+`
+
+const findTournamentBySession = `
+SELECT id::text FROM tournaments
+WHERE status = 'running'
+AND data->'rounds' @> jsonb_build_array(jsonb_build_object('matches', jsonb_build_array(jsonb_build_object('session_id', $1::text))))
+`
+
+// FindTournamentBySession returns the running tournament one of whose matches is played in a duel session
+func FindTournamentBySession(ctx context.Context, db basepool.DBTX, session_id string) (string, error) {
+	var tournament_id string
+	err := db.QueryRow(ctx, findTournamentBySession, session_id).Scan(&tournament_id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", ErrTournamentNotFound
+	} else if err != nil {
+		return "", fmt.Errorf("failed to find tournament: %w", err)
+	}
+	return tournament_id, nil
+}
+
+// ListTournaments returns a page of the tournaments from the most recent one, filtered by status when given
+func ListTournaments(ctx context.Context, db basepool.DBTX, status TournamentStatus, offset int, limit int) ([]TournamentData, int64, error) {
+	status_filter := optionalText(string(status))
+
+	var total int64
+	if err := db.QueryRow(ctx, countTournaments, status_filter).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count tournaments: %w", err)
+	}
+
+	rows, err := db.Query(ctx, listTournaments, status_filter, offset, limit)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list tournaments: %w", err)
+	}
+	defer rows.Close()
+
+	tournaments := []TournamentData{}
+	for rows.Next() {
+		tournament, err := scanTournament(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		tournaments = append(tournaments, tournament)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("failed to list tournaments: %w", err)
+	}
+	return tournaments, total, nil
+}
@@ -0,0 +1,6 @@
+-- Spectate privacy of the users, a missing row means the default privacy
+CREATE TABLE IF NOT EXISTS user_spectate_settings (
+	user_id uuid PRIMARY KEY REFERENCES users (id) ON DELETE CASCADE,
+	privacy text NOT NULL CHECK (privacy IN ('everyone', 'friends', 'nobody')),
+	updated_at timestamptz NOT NULL DEFAULT now()
+);
@@ -0,0 +1,10 @@
+-- Replays uploaded by the nexuspools, keyed by the session of their duel result.
+-- The replay may be uploaded before the result is stored, hence no foreign key.
+CREATE TABLE IF NOT EXISTS duel_replays (
+	session_id uuid PRIMARY KEY,
+	size bigint NOT NULL,
+	uploaded_at timestamptz NOT NULL,
+	expires_at timestamptz NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS duel_replays_expires_at_idx ON duel_replays (expires_at);
@@ -0,0 +1,3 @@
+-- Duels conceded or abandoned by a player are won by forfeit.
+-- The value is only used by later transactions, which postgres allows since version 12.
+ALTER TYPE winning_method ADD VALUE IF NOT EXISTS 'forfeit';
@@ -0,0 +1,3 @@
+-- Duels played against a house bot are stored as practice duels.
+-- The value is only used by later transactions, which postgres allows since version 12.
+ALTER TYPE duel_type ADD VALUE IF NOT EXISTS 'practice';
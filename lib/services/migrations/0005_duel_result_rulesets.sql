@@ -0,0 +1,5 @@
+-- Ruleset each duel result was played with, results stored before rulesets existed have none
+CREATE TABLE IF NOT EXISTS duel_result_rulesets (
+	session_id uuid PRIMARY KEY,
+	ruleset jsonb NOT NULL
+);
@@ -0,0 +1,12 @@
+-- Suspicious duel results awaiting moderation, a flagged result changes no rating until it is approved
+CREATE TABLE IF NOT EXISTS duel_result_flags (
+	session_id uuid PRIMARY KEY,
+	duel_type text NOT NULL,
+	result jsonb NOT NULL,
+	anomalies text[] NOT NULL,
+	flagged_at timestamptz NOT NULL DEFAULT now(),
+	review text CHECK (review IN ('approved', 'dismissed')),
+	reviewed_at timestamptz
+);
+
+CREATE INDEX IF NOT EXISTS duel_result_flags_pending_idx ON duel_result_flags (flagged_at) WHERE review IS NULL;
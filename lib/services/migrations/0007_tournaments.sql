@@ -0,0 +1,12 @@
+-- Tournaments along with their players and bracket, stored as the document the service works on.
+-- The status and creation date are kept in columns to list the tournaments.
+CREATE TABLE IF NOT EXISTS tournaments (
+	id uuid PRIMARY KEY,
+	status text NOT NULL,
+	data jsonb NOT NULL,
+	created_at timestamptz NOT NULL,
+	updated_at timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS tournaments_created_at_idx ON tournaments (created_at DESC, id);
+CREATE INDEX IF NOT EXISTS tournaments_status_created_at_idx ON tournaments (status, created_at DESC, id);
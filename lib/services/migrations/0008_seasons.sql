@@ -0,0 +1,37 @@
+-- Ranked seasons, at most one of them is not finished and it is the current season
+CREATE TABLE IF NOT EXISTS seasons (
+	id uuid PRIMARY KEY,
+	number bigint NOT NULL UNIQUE,
+	name text NOT NULL,
+	status text NOT NULL CHECK (status IN ('active', 'closing', 'finished')),
+	start_at timestamptz NOT NULL,
+	end_at timestamptz NOT NULL,
+	ended_at timestamptz,
+	rewards jsonb NOT NULL,
+	archived boolean NOT NULL DEFAULT false,
+	ratings_reset boolean NOT NULL DEFAULT false
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS seasons_current_idx ON seasons ((true)) WHERE status <> 'finished';
+
+-- Highest rating reached by each player during a season
+CREATE TABLE IF NOT EXISTS season_peaks (
+	season_id uuid NOT NULL REFERENCES seasons (id) ON DELETE CASCADE,
+	user_id uuid NOT NULL,
+	elo bigint NOT NULL,
+	PRIMARY KEY (season_id, user_id)
+);
+
+-- Final standings archived when a season ends
+CREATE TABLE IF NOT EXISTS season_standings (
+	season_id uuid NOT NULL REFERENCES seasons (id) ON DELETE CASCADE,
+	rank bigint NOT NULL,
+	user_id uuid NOT NULL,
+	username text NOT NULL,
+	tag text NOT NULL,
+	country text NOT NULL,
+	elo bigint NOT NULL,
+	peak_elo bigint NOT NULL,
+	reward text NOT NULL,
+	PRIMARY KEY (season_id, rank)
+);
@@ -0,0 +1,12 @@
+-- Server owned accounts playing as house bots, they stay out of the rankings and statistics
+ALTER TABLE users ADD COLUMN IF NOT EXISTS is_bot boolean NOT NULL DEFAULT false;
+
+-- Catalogue of the house bots playing the practice duels, cached in redis
+CREATE TABLE IF NOT EXISTS bots (
+	id text PRIMARY KEY,
+	name text NOT NULL,
+	description text NOT NULL DEFAULT '',
+	difficulty text NOT NULL CHECK (difficulty IN ('easy', 'medium', 'hard')),
+	user_id uuid NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+	created_at timestamptz NOT NULL DEFAULT now()
+);
@@ -0,0 +1,35 @@
+package signing
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+)
+
+func CheckHMAC(hmacKey []byte, code string, expectedHMAC string) (bool, error) {
+	// Decode the hex-encoded HMAC
+	expectedHMACBytes, err := hex.DecodeString(expectedHMAC)
+	if err != nil {
+		return false, fmt.Errorf("failed to decode HMAC hex string: %w", err)
+	}
+
+	// Create a new HMAC-SHA256 instance
+	mac := hmac.New(sha256.New, hmacKey)
+
+	mac.Write([]byte(code))
+
+	// Calculate the HMAC
+	calculatedHMAC := mac.Sum(nil)
+
+	// Compare the HMACs using a constant-time comparison
+	return hmac.Equal(calculatedHMAC, expectedHMACBytes), nil
+}
+
+func SignHMAC(hmacKey []byte, code string) string {
+	mac := hmac.New(sha256.New, hmacKey)
+
+	mac.Write([]byte(code))
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
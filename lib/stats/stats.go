@@ -0,0 +1,230 @@
+package stats
+
+import (
+	"backend/lib/services"
+	"context"
+	"fmt"
+	"strings"
+
+	basepool "github.com/ciphrpool/base-pool/gen"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+type Result string
+
+const (
+	Win  Result = "wins"
+	Loss Result = "losses"
+	Draw Result = "draws"
+)
+
+type Resources struct {
+	EgoCount      int64 `json:"ego_count"`
+	Energy        int64 `json:"energy"`
+	CorruptedData int64 `json:"corrupted_data"`
+	EmotionalData int64 `json:"emotional_data"`
+	QuantumData   int64 `json:"quantum_data"`
+	LogicalData   int64 `json:"logical_data"`
+}
+
+// Sample is a duel seen from the point of view of one player
+type Sample struct {
+	DuelType  string
+	Result    Result
+	Method    string
+	Duration  int64
+	Resources Resources
+}
+
+type TypeStats struct {
+	Played  int64   `json:"played"`
+	Wins    int64   `json:"wins"`
+	Losses  int64   `json:"losses"`
+	Draws   int64   `json:"draws"`
+	WinRate float64 `json:"win_rate"`
+}
+
+type MethodStats struct {
+	Played  int64   `json:"played"`
+	Wins    int64   `json:"wins"`
+	WinRate float64 `json:"win_rate"`
+}
+
+type ResourceAverages struct {
+	EgoCount      float64 `json:"ego_count"`
+	Energy        float64 `json:"energy"`
+	CorruptedData float64 `json:"corrupted_data"`
+	EmotionalData float64 `json:"emotional_data"`
+	QuantumData   float64 `json:"quantum_data"`
+	LogicalData   float64 `json:"logical_data"`
+}
+
+type UserStats struct {
+	TypeStats
+	AverageDuration float64                `json:"average_duration"`
+	ByType          map[string]TypeStats   `json:"by_type"`
+	ByMethod        map[string]MethodStats `json:"by_method"`
+	Averages        ResourceAverages       `json:"averages"`
+}
+
+// Counters returns the increments of the aggregate of a player for one duel
+func Counters(sample Sample) map[string]int64 {
+	counters := map[string]int64{
+		"duels":    1,
+		"duration": sample.Duration,
+		fmt.Sprintf("type:%s:%s", sample.DuelType, sample.Result): 1,
+		"res:ego_count":      sample.Resources.EgoCount,
+		"res:energy":         sample.Resources.Energy,
+		"res:corrupted_data": sample.Resources.CorruptedData,
+		"res:emotional_data": sample.Resources.EmotionalData,
+		"res:quantum_data":   sample.Resources.QuantumData,
+		"res:logical_data":   sample.Resources.LogicalData,
+	}
+	if sample.Method != "" {
+		counters[fmt.Sprintf("method:%s:played", sample.Method)] = 1
+		if sample.Result == Win {
+			counters[fmt.Sprintf("method:%s:wins", sample.Method)] = 1
+		}
+	}
+	return counters
+}
+
+func rate(part int64, total int64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(part) / float64(total)
+}
+
+// Aggregate builds the statistics of a player from its counters
+func Aggregate(counters map[string]int64) UserStats {
+	stats := UserStats{
+		ByType:   make(map[string]TypeStats),
+		ByMethod: make(map[string]MethodStats),
+	}
+	resources := make(map[string]int64)
+
+	for field, value := range counters {
+		parts := strings.Split(field, ":")
+		switch {
+		case len(parts) == 3 && parts[0] == "type":
+			type_stats := stats.ByType[parts[1]]
+			switch Result(parts[2]) {
+			case Win:
+				type_stats.Wins += value
+			case Loss:
+				type_stats.Losses += value
+			case Draw:
+				type_stats.Draws += value
+			}
+			stats.ByType[parts[1]] = type_stats
+		case len(parts) == 3 && parts[0] == "method":
+			method_stats := stats.ByMethod[parts[1]]
+			if parts[2] == "wins" {
+				method_stats.Wins += value
+			} else {
+				method_stats.Played += value
+			}
+			stats.ByMethod[parts[1]] = method_stats
+		case len(parts) == 2 && parts[0] == "res":
+			resources[parts[1]] = value
+		case field == "duels":
+			stats.Played = value
+		}
+	}
+
+	for duel_type, type_stats := range stats.ByType {
+		type_stats.Played = type_stats.Wins + type_stats.Losses + type_stats.Draws
+		type_stats.WinRate = rate(type_stats.Wins, type_stats.Played)
+		stats.ByType[duel_type] = type_stats
+
+		stats.Wins += type_stats.Wins
+		stats.Losses += type_stats.Losses
+		stats.Draws += type_stats.Draws
+	}
+	for method, method_stats := range stats.ByMethod {
+		method_stats.WinRate = rate(method_stats.Wins, method_stats.Played)
+		stats.ByMethod[method] = method_stats
+	}
+
+	stats.WinRate = rate(stats.Wins, stats.Played)
+	stats.AverageDuration = rate(counters["duration"], stats.Played)
+	stats.Averages = ResourceAverages{
+		EgoCount:      rate(resources["ego_count"], stats.Played),
+		Energy:        rate(resources["energy"], stats.Played),
+		CorruptedData: rate(resources["corrupted_data"], stats.Played),
+		EmotionalData: rate(resources["emotional_data"], stats.Played),
+		QuantumData:   rate(resources["quantum_data"], stats.Played),
+		LogicalData:   rate(resources["logical_data"], stats.Played),
+	}
+	return stats
+}
+
+// Record adds a processed duel to the aggregate of a player, a duel already counted is ignored
+func Record(cache *services.Cache, user_id pgtype.UUID, session_id string, sample Sample) error {
+	return cache.IncrUserStats(services.UUIDToString(user_id), session_id, Counters(sample))
+}
+
+func sampleFromRow(row services.DuelStatsRow) Sample {
+	own := row.P1
+	result := Draw
+	switch {
+	case row.DuelOutcome == string(basepool.DuelOutcomeP1WON):
+		result = Win
+	case row.DuelOutcome == string(basepool.DuelOutcomeP2WON):
+		result = Loss
+	}
+	if !row.IsP1 {
+		own = row.P2
+		if result == Win {
+			result = Loss
+		} else if result == Loss {
+			result = Win
+		}
+	}
+
+	return Sample{
+		DuelType: row.DuelType,
+		Result:   result,
+		Method:   row.WinningMethod,
+		Duration: int64(row.Duration),
+		Resources: Resources{
+			EgoCount:      int64(own[0]),
+			Energy:        int64(own[1]),
+			CorruptedData: int64(own[2]),
+			EmotionalData: int64(own[3]),
+			QuantumData:   int64(own[4]),
+			LogicalData:   int64(own[5]),
+		},
+	}
+}
+
+// Get returns the statistics of a player, the aggregate is initialized from the stored duels on first access
+func Get(ctx context.Context, cache *services.Cache, db *services.Database, user_id pgtype.UUID) (UserStats, error) {
+	counters, err := cache.GetUserStats(services.UUIDToString(user_id))
+	if err != nil {
+		return UserStats{}, err
+	}
+	if counters != nil {
+		return Aggregate(counters), nil
+	}
+
+	rows, err := services.ListUserDuelStats(ctx, db.Pool, user_id)
+	if err != nil {
+		return UserStats{}, err
+	}
+	counters = map[string]int64{"duels": 0}
+	session_ids := make([]string, 0, len(rows))
+	for _, row := range rows {
+		for field, value := range Counters(sampleFromRow(row)) {
+			counters[field] += value
+		}
+		session_ids = append(session_ids, row.SessionID)
+	}
+	// Duels recorded while the stored ones were listed are reconciled by their session id
+	counters, err = cache.InitUserStats(services.UUIDToString(user_id), counters, session_ids)
+	if err != nil {
+		return UserStats{}, err
+	}
+	return Aggregate(counters), nil
+}
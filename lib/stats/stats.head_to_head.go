@@ -0,0 +1,93 @@
+package stats
+
+import (
+	"backend/lib/services"
+	"time"
+
+	basepool "github.com/ciphrpool/base-pool/gen"
+)
+
+type HeadToHeadDuel struct {
+	SessionID string    `json:"session_id"`
+	Date      time.Time `json:"date"`
+	DuelType  string    `json:"duel_type"`
+	Result    Result    `json:"result"`
+	Method    string    `json:"method"`
+	Duration  int64     `json:"duration"`
+}
+
+type Streak struct {
+	Result Result `json:"result"`
+	Count  int    `json:"count"`
+}
+
+type HeadToHead struct {
+	Played          int64            `json:"played"`
+	Wins            int64            `json:"wins"`
+	Losses          int64            `json:"losses"`
+	Draws           int64            `json:"draws"`
+	AverageDuration float64          `json:"average_duration"`
+	Streak          Streak           `json:"streak"`
+	Last            []HeadToHeadDuel `json:"last"`
+}
+
+// resultOf returns the result of a stored duel from the point of view of the requesting user
+func resultOf(outcome string, is_p1 bool) Result {
+	switch {
+	case outcome == string(basepool.DuelOutcomeP1WON) && is_p1,
+		outcome == string(basepool.DuelOutcomeP2WON) && !is_p1:
+		return Win
+	case outcome == string(basepool.DuelOutcomeP1WON),
+		outcome == string(basepool.DuelOutcomeP2WON):
+		return Loss
+	default:
+		return Draw
+	}
+}
+
+// ComputeHeadToHead summarizes the duels between two players, given from the most recent one
+func ComputeHeadToHead(rows []services.HeadToHeadRow, last int) HeadToHead {
+	head_to_head := HeadToHead{
+		Last: make([]HeadToHeadDuel, 0, last),
+	}
+
+	var total_duration int64
+	streak_open := true
+	for i, row := range rows {
+		result := resultOf(row.DuelOutcome, row.IsP1)
+		head_to_head.Played++
+		total_duration += int64(row.Duration)
+		switch result {
+		case Win:
+			head_to_head.Wins++
+		case Loss:
+			head_to_head.Losses++
+		default:
+			head_to_head.Draws++
+		}
+
+		// The current streak is the run of identical results ending with the most recent duel
+		if i == 0 {
+			head_to_head.Streak = Streak{Result: result}
+		}
+		if streak_open && result == head_to_head.Streak.Result {
+			head_to_head.Streak.Count++
+		} else {
+			streak_open = false
+		}
+
+		if i < last {
+			head_to_head.Last = append(head_to_head.Last, HeadToHeadDuel{
+				SessionID: services.UUIDToString(row.SessionID),
+				Date:      row.Date.Time,
+				DuelType:  row.DuelType,
+				Result:    result,
+				Method:    row.WinningMethod,
+				Duration:  int64(row.Duration),
+			})
+		}
+	}
+
+	head_to_head.AverageDuration = rate(total_duration, head_to_head.Played)
+	return head_to_head
+}
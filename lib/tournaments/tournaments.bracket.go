@@ -0,0 +1,191 @@
+package tournaments
+
+import (
+	"backend/lib/services"
+	"fmt"
+	"sort"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// BracketSize returns the smallest power of two able to hold all the players
+func BracketSize(players int) int {
+	size := 1
+	for size < players {
+		size *= 2
+	}
+	return size
+}
+
+// SeedOrder returns the seeds (0 indexed) in bracket order so that the best seeds meet in the last rounds
+func SeedOrder(size int) []int {
+	order := []int{0}
+	for len(order) < size {
+		next := make([]int, 0, len(order)*2)
+		round_size := len(order) * 2
+		for _, seed := range order {
+			next = append(next, seed, round_size-1-seed)
+		}
+		order = next
+	}
+	return order
+}
+
+// SwissRoundsCount returns the default number of swiss rounds for the given number of players
+func SwissRoundsCount(players int) int {
+	rounds := 0
+	for size := 1; size < players; size *= 2 {
+		rounds++
+	}
+	if rounds == 0 {
+		return 1
+	}
+	return rounds
+}
+
+func matchID(round int, index int) string {
+	return fmt.Sprintf("r%d-m%d", round, index)
+}
+
+func newMatch(round int, index int, p1 pgtype.UUID, p2 pgtype.UUID) services.TournamentMatchData {
+	match := services.TournamentMatchData{
+		ID:     matchID(round, index),
+		Round:  round,
+		P1:     p1,
+		P2:     p2,
+		Status: services.TournamentMatchPending,
+	}
+	if !p2.Valid {
+		// P1 has a bye and wins the match right away
+		match.Winner = p1
+		match.Status = services.TournamentMatchFinished
+	}
+	return match
+}
+
+// Seeds returns the players ordered from the best to the worst seed
+func Seeds(players []services.TournamentPlayerData) []services.TournamentPlayerData {
+	seeds := make([]services.TournamentPlayerData, len(players))
+	copy(seeds, players)
+	sort.SliceStable(seeds, func(i, j int) bool {
+		return seeds[i].Elo > seeds[j].Elo
+	})
+	return seeds
+}
+
+// SingleEliminationFirstRound builds the first round of a seeded single elimination bracket
+func SingleEliminationFirstRound(players []services.TournamentPlayerData) services.TournamentRoundData {
+	seeds := Seeds(players)
+	size := BracketSize(len(seeds))
+	order := SeedOrder(size)
+
+	round := services.TournamentRoundData{Number: 1}
+	for i := 0; i < size; i += 2 {
+		var p1, p2 pgtype.UUID
+		if order[i] < len(seeds) {
+			p1 = seeds[order[i]].PID
+		}
+		if order[i+1] < len(seeds) {
+			p2 = seeds[order[i+1]].PID
+		}
+		if !p1.Valid {
+			p1, p2 = p2, p1
+		}
+		round.Matches = append(round.Matches, newMatch(round.Number, len(round.Matches), p1, p2))
+	}
+	return round
+}
+
+// SingleEliminationNextRound pairs the winners of consecutive matches of the previous round
+func SingleEliminationNextRound(previous services.TournamentRoundData) services.TournamentRoundData {
+	round := services.TournamentRoundData{Number: previous.Number + 1}
+	for i := 0; i+1 < len(previous.Matches); i += 2 {
+		round.Matches = append(round.Matches, newMatch(
+			round.Number,
+			len(round.Matches),
+			previous.Matches[i].Winner,
+			previous.Matches[i+1].Winner,
+		))
+	}
+	return round
+}
+
+// Standings returns the players ordered by score then by elo
+func Standings(players []services.TournamentPlayerData) []services.TournamentPlayerData {
+	standings := make([]services.TournamentPlayerData, len(players))
+	copy(standings, players)
+	sort.SliceStable(standings, func(i, j int) bool {
+		if standings[i].Score != standings[j].Score {
+			return standings[i].Score > standings[j].Score
+		}
+		return standings[i].Elo > standings[j].Elo
+	})
+	return standings
+}
+
+// SwissNextRound pairs players with the same score while avoiding rematches, the lowest ranked player without a bye gets one if needed
+func SwissNextRound(players []services.TournamentPlayerData, rounds []services.TournamentRoundData) services.TournamentRoundData {
+	round := services.TournamentRoundData{Number: len(rounds) + 1}
+	standings := Standings(players)
+
+	played := make(map[[16]byte]map[[16]byte]bool, len(players))
+	for _, previous := range rounds {
+		for _, match := range previous.Matches {
+			if !match.P2.Valid {
+				continue
+			}
+			if played[match.P1.Bytes] == nil {
+				played[match.P1.Bytes] = make(map[[16]byte]bool)
+			}
+			if played[match.P2.Bytes] == nil {
+				played[match.P2.Bytes] = make(map[[16]byte]bool)
+			}
+			played[match.P1.Bytes][match.P2.Bytes] = true
+			played[match.P2.Bytes][match.P1.Bytes] = true
+		}
+	}
+
+	paired := make([]bool, len(standings))
+	var bye *services.TournamentMatchData
+	if len(standings)%2 == 1 {
+		for i := len(standings) - 1; i >= 0; i-- {
+			if !standings[i].HadBye || i == 0 {
+				paired[i] = true
+				match := newMatch(round.Number, 0, standings[i].PID, pgtype.UUID{})
+				bye = &match
+				break
+			}
+		}
+	}
+
+	for i := range standings {
+		if paired[i] {
+			continue
+		}
+		opponent := -1
+		for j := i + 1; j < len(standings); j++ {
+			if paired[j] {
+				continue
+			}
+			if opponent == -1 {
+				opponent = j // Fallback to a rematch if no other opponent is available
+			}
+			if !played[standings[i].PID.Bytes][standings[j].PID.Bytes] {
+				opponent = j
+				break
+			}
+		}
+		if opponent == -1 {
+			continue
+		}
+		paired[i] = true
+		paired[opponent] = true
+		round.Matches = append(round.Matches, newMatch(round.Number, len(round.Matches), standings[i].PID, standings[opponent].PID))
+	}
+
+	if bye != nil {
+		bye.ID = matchID(round.Number, len(round.Matches))
+		round.Matches = append(round.Matches, *bye)
+	}
+	return round
+}
@@ -0,0 +1,500 @@
+package tournaments
+
+import (
+	"backend/lib/notifications"
+	"backend/lib/services"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	basepool "github.com/ciphrpool/base-pool/gen"
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+var (
+	ErrNotHost              = errors.New("only the host can manage the tournament")
+	ErrInvalidStatus        = errors.New("invalid tournament status for this action")
+	ErrInvalidFormat        = errors.New("invalid tournament format")
+	ErrTournamentFull       = errors.New("tournament is full")
+	ErrAlreadyRegistered    = errors.New("player is already registered")
+	ErrNotRegistered        = errors.New("player is not registered")
+	ErrNotEnoughPlayers     = errors.New("not enough checked in players")
+	ErrUnknownMatch         = errors.New("unknown tournament match")
+	ErrMatchAlreadyLaunched = errors.New("tournament match is already launched")
+)
+
+const (
+	MIN_PLAYERS = 2
+	MAX_PLAYERS = 128
+)
+
+const (
+	// A match whose duel has no result in time is resolved without it
+	MATCH_DEADLINE = 2 * time.Hour
+	// A single elimination match drawn more often than that is won by walkover
+	MAX_MATCH_REPLAYS = 2
+)
+
+type CreateTournamentParams struct {
+	Name        string
+	Format      services.TournamentFormat
+	MaxPlayers  int
+	SwissRounds int
+}
+
+// Create registers a new tournament opened to registrations
+func Create(ctx context.Context, db *services.Database, host_id pgtype.UUID, params CreateTournamentParams) (string, error) {
+	if params.Format != services.TournamentFormatSingleElimination && params.Format != services.TournamentFormatSwiss {
+		return "", ErrInvalidFormat
+	}
+	if params.MaxPlayers < MIN_PLAYERS || params.MaxPlayers > MAX_PLAYERS {
+		params.MaxPlayers = MAX_PLAYERS
+	}
+
+	return services.CreateTournament(ctx, db.Pool, &services.TournamentData{
+		Name:        params.Name,
+		Format:      params.Format,
+		Status:      services.TournamentStatusRegistration,
+		HostID:      host_id,
+		MaxPlayers:  params.MaxPlayers,
+		SwissRounds: params.SwissRounds,
+		Players:     []services.TournamentPlayerData{},
+		Rounds:      []services.TournamentRoundData{},
+	})
+}
+
+func findPlayer(tournament *services.TournamentData, user_id pgtype.UUID) *services.TournamentPlayerData {
+	for i := range tournament.Players {
+		if tournament.Players[i].PID.Bytes == user_id.Bytes {
+			return &tournament.Players[i]
+		}
+	}
+	return nil
+}
+
+func findMatchBySession(tournament *services.TournamentData, session_id string) *services.TournamentMatchData {
+	if len(tournament.Rounds) == 0 || session_id == "" {
+		return nil
+	}
+	round := &tournament.Rounds[len(tournament.Rounds)-1]
+	for i := range round.Matches {
+		if round.Matches[i].SessionID == session_id {
+			return &round.Matches[i]
+		}
+	}
+	return nil
+}
+
+func findMatch(tournament *services.TournamentData, match_id string) *services.TournamentMatchData {
+	if len(tournament.Rounds) == 0 {
+		return nil
+	}
+	round := &tournament.Rounds[len(tournament.Rounds)-1]
+	for i := range round.Matches {
+		if round.Matches[i].ID == match_id {
+			return &round.Matches[i]
+		}
+	}
+	return nil
+}
+
+// Register adds a player to a tournament opened to registrations
+func Register(ctx context.Context, db *services.Database, tournament_id string, player services.TournamentPlayerData) error {
+	_, err := db.UpdateTournament(ctx, tournament_id, func(tournament *services.TournamentData) error {
+		if tournament.Status != services.TournamentStatusRegistration {
+			return ErrInvalidStatus
+		}
+		if findPlayer(tournament, player.PID) != nil {
+			return ErrAlreadyRegistered
+		}
+		if len(tournament.Players) >= tournament.MaxPlayers {
+			return ErrTournamentFull
+		}
+		player.CheckedIn = false
+		player.Score = 0
+		player.Eliminated = false
+		player.HadBye = false
+		tournament.Players = append(tournament.Players, player)
+		return nil
+	})
+	return err
+}
+
+// OpenCheckIn closes the registrations and lets the registered players check in
+func OpenCheckIn(ctx context.Context, db *services.Database, notify *notifications.NotificationService, tournament_id string, host_id pgtype.UUID) error {
+	tournament, err := db.UpdateTournament(ctx, tournament_id, func(tournament *services.TournamentData) error {
+		if tournament.HostID.Bytes != host_id.Bytes {
+			return ErrNotHost
+		}
+		if tournament.Status != services.TournamentStatusRegistration {
+			return ErrInvalidStatus
+		}
+		tournament.Status = services.TournamentStatusCheckIn
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, player := range tournament.Players {
+		notify.Send(
+			ctx,
+			notifications.TypeAlert,
+			"tournament:check_in",
+			notifications.PriorityHigh,
+			player.PID,
+			fiber.Map{
+				"msg": fmt.Sprintf("The check in of the tournament %s is open !", tournament.Name),
+			},
+			fiber.Map{
+				"tournament_id": tournament.ID,
+			},
+		)
+	}
+	return nil
+}
+
+// CheckIn confirms the participation of a registered player
+func CheckIn(ctx context.Context, db *services.Database, tournament_id string, user_id pgtype.UUID) error {
+	_, err := db.UpdateTournament(ctx, tournament_id, func(tournament *services.TournamentData) error {
+		if tournament.Status != services.TournamentStatusCheckIn {
+			return ErrInvalidStatus
+		}
+		player := findPlayer(tournament, user_id)
+		if player == nil {
+			return ErrNotRegistered
+		}
+		player.CheckedIn = true
+		return nil
+	})
+	return err
+}
+
+// applyRound appends the round to the tournament and credits the byes
+func applyRound(tournament *services.TournamentData, round services.TournamentRoundData) {
+	for _, match := range round.Matches {
+		if match.P2.Valid {
+			continue
+		}
+		if player := findPlayer(tournament, match.P1); player != nil {
+			player.HadBye = true
+			if tournament.Format == services.TournamentFormatSwiss {
+				player.Score += 1
+			}
+		}
+	}
+	tournament.Rounds = append(tournament.Rounds, round)
+}
+
+// Start drops the players who did not check in, generates the first round and launches its duels
+func Start(ctx context.Context, cache *services.Cache, db *services.Database, notify *notifications.NotificationService, tournament_id string, host_id pgtype.UUID) error {
+	tournament, err := db.UpdateTournament(ctx, tournament_id, func(tournament *services.TournamentData) error {
+		if tournament.HostID.Bytes != host_id.Bytes {
+			return ErrNotHost
+		}
+		if tournament.Status != services.TournamentStatusCheckIn {
+			return ErrInvalidStatus
+		}
+
+		checked_in := make([]services.TournamentPlayerData, 0, len(tournament.Players))
+		for _, player := range tournament.Players {
+			if player.CheckedIn {
+				checked_in = append(checked_in, player)
+			}
+		}
+		if len(checked_in) < MIN_PLAYERS {
+			return ErrNotEnoughPlayers
+		}
+		tournament.Players = checked_in
+
+		switch tournament.Format {
+		case services.TournamentFormatSingleElimination:
+			applyRound(tournament, SingleEliminationFirstRound(tournament.Players))
+		case services.TournamentFormatSwiss:
+			if tournament.SwissRounds <= 0 || tournament.SwissRounds >= len(tournament.Players) {
+				tournament.SwissRounds = SwissRoundsCount(len(tournament.Players))
+			}
+			applyRound(tournament, SwissNextRound(tournament.Players, tournament.Rounds))
+		default:
+			return ErrInvalidFormat
+		}
+		tournament.Status = services.TournamentStatusRunning
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return launchPendingMatches(ctx, cache, db, notify, tournament)
+}
+
+// ReportResult records the outcome of a tournament duel and moves the tournament to its next round when needed,
+// an invalid winner means a draw
+func ReportResult(ctx context.Context, cache *services.Cache, db *services.Database, notify *notifications.NotificationService, tournament_id string, session_id string, winner pgtype.UUID) error {
+	tournament, err := db.UpdateTournament(ctx, tournament_id, func(tournament *services.TournamentData) error {
+		if tournament.Status != services.TournamentStatusRunning {
+			return ErrInvalidStatus
+		}
+		return RecordResult(tournament, session_id, winner)
+	})
+	if err != nil {
+		return err
+	}
+
+	if tournament.Status == services.TournamentStatusFinished {
+		notifyWinner(ctx, notify, tournament)
+		return nil
+	}
+	return launchPendingMatches(ctx, cache, db, notify, tournament)
+}
+
+// RecordResult records the outcome of the duel of a match of the current round, an invalid winner means a draw
+func RecordResult(tournament *services.TournamentData, session_id string, winner pgtype.UUID) error {
+	match := findMatchBySession(tournament, session_id)
+	if match == nil {
+		return ErrUnknownMatch
+	}
+	if match.Status != services.TournamentMatchRunning {
+		return nil // Already reported
+	}
+	resolveMatch(tournament, match, winner)
+	return nil
+}
+
+// ReportAbandoned resolves the match of an aborted tournament duel, the tournament is looked up
+// from the session when its data has expired
+func ReportAbandoned(ctx context.Context, cache *services.Cache, db *services.Database, notify *notifications.NotificationService, tournament_id string, session_id string) error {
+	if tournament_id == "" {
+		var err error
+		tournament_id, err = services.FindTournamentBySession(ctx, db.Pool, session_id)
+		if errors.Is(err, services.ErrTournamentNotFound) {
+			return nil // Not a tournament duel, or its match is already resolved
+		} else if err != nil {
+			return err
+		}
+	}
+
+	tournament, err := db.UpdateTournament(ctx, tournament_id, func(tournament *services.TournamentData) error {
+		if tournament.Status != services.TournamentStatusRunning {
+			return ErrInvalidStatus
+		}
+		return RecordAbandoned(tournament, session_id)
+	})
+	if errors.Is(err, ErrUnknownMatch) || errors.Is(err, ErrInvalidStatus) {
+		return nil // The match was resolved by its deadline
+	} else if err != nil {
+		return err
+	}
+
+	slog.Info("Abandoned tournament duel reported as a draw", "tournament_id", tournament_id, "SessionID", session_id)
+	if tournament.Status == services.TournamentStatusFinished {
+		notifyWinner(ctx, notify, tournament)
+		return nil
+	}
+	return launchPendingMatches(ctx, cache, db, notify, tournament)
+}
+
+// RecordAbandoned records an aborted duel as a draw, without knowing which player is absent :
+// a single elimination match is played again until it is won by walkover, a swiss match splits the point
+func RecordAbandoned(tournament *services.TournamentData, session_id string) error {
+	return RecordResult(tournament, session_id, pgtype.UUID{})
+}
+
+// ExpireMatches resolves the matches of the current round whose duel is past its deadline as draws
+// and returns their duel sessions, which are to be closed
+func ExpireMatches(tournament *services.TournamentData, now time.Time) []string {
+	if len(tournament.Rounds) == 0 {
+		return nil
+	}
+	rounds := len(tournament.Rounds)
+	round := &tournament.Rounds[rounds-1]
+
+	var expired []string
+	for i := range round.Matches {
+		match := &round.Matches[i]
+		if match.Status != services.TournamentMatchRunning || match.Deadline.IsZero() || now.Before(match.Deadline) {
+			continue
+		}
+		expired = append(expired, match.SessionID)
+		resolveMatch(tournament, match, pgtype.UUID{})
+		if tournament.Status == services.TournamentStatusFinished || len(tournament.Rounds) > rounds {
+			break // The round is over, its remaining matches are all finished
+		}
+	}
+	return expired
+}
+
+// resolveMatch finishes a match and moves the tournament on.
+// A drawn single elimination match is played again, then won by walkover by its first player who is the better seed.
+func resolveMatch(tournament *services.TournamentData, match *services.TournamentMatchData, winner pgtype.UUID) {
+	if !winner.Valid && tournament.Format == services.TournamentFormatSingleElimination {
+		if match.Replays < MAX_MATCH_REPLAYS {
+			match.Replays++
+			match.SessionID = ""
+			match.Deadline = time.Time{}
+			match.Status = services.TournamentMatchPending
+			return
+		}
+		winner = match.P1
+	}
+
+	if !winner.Valid {
+		for _, pid := range []pgtype.UUID{match.P1, match.P2} {
+			if player := findPlayer(tournament, pid); player != nil {
+				player.Score += 0.5
+			}
+		}
+	} else {
+		loser := match.P1
+		if loser.Bytes == winner.Bytes {
+			loser = match.P2
+		}
+		if player := findPlayer(tournament, winner); player != nil {
+			player.Score += 1
+		}
+		if player := findPlayer(tournament, loser); player != nil && tournament.Format == services.TournamentFormatSingleElimination {
+			player.Eliminated = true
+		}
+	}
+	match.Winner = winner
+	match.Status = services.TournamentMatchFinished
+	match.Deadline = time.Time{}
+
+	advance(tournament)
+}
+
+// advance generates the next round or ends the tournament once every match of the current round is finished
+func advance(tournament *services.TournamentData) {
+	current := tournament.Rounds[len(tournament.Rounds)-1]
+	for _, match := range current.Matches {
+		if match.Status != services.TournamentMatchFinished {
+			return
+		}
+	}
+
+	switch tournament.Format {
+	case services.TournamentFormatSingleElimination:
+		if len(current.Matches) == 1 {
+			tournament.Winner = current.Matches[0].Winner
+			tournament.Status = services.TournamentStatusFinished
+			return
+		}
+		applyRound(tournament, SingleEliminationNextRound(current))
+	case services.TournamentFormatSwiss:
+		if len(tournament.Rounds) >= tournament.SwissRounds {
+			tournament.Winner = Standings(tournament.Players)[0].PID
+			tournament.Status = services.TournamentStatusFinished
+			return
+		}
+		applyRound(tournament, SwissNextRound(tournament.Players, tournament.Rounds))
+	}
+}
+
+// launchPendingMatches creates the duel sessions of the pending matches of the current round
+func launchPendingMatches(ctx context.Context, cache *services.Cache, db *services.Database, notify *notifications.NotificationService, tournament services.TournamentData) error {
+	if len(tournament.Rounds) == 0 {
+		return nil
+	}
+	current := tournament.Rounds[len(tournament.Rounds)-1]
+
+	for _, match := range current.Matches {
+		if match.Status != services.TournamentMatchPending || !match.P2.Valid {
+			continue
+		}
+		p1 := findPlayer(&tournament, match.P1)
+		p2 := findPlayer(&tournament, match.P2)
+		if p1 == nil || p2 == nil {
+			return ErrNotRegistered
+		}
+
+		session_id, err := cache.CreateDuelSession(&services.DuelSessionData{
+			DuelType: basepool.DuelTypeTournament,
+			P1: services.DuelPlayerSummaryData{
+				PID:      p1.PID,
+				Elo:      p1.Elo,
+				Tag:      p1.Tag,
+				Username: p1.Username,
+			},
+			P2: services.DuelPlayerSummaryData{
+				PID:      p2.PID,
+				Elo:      p2.Elo,
+				Tag:      p2.Tag,
+				Username: p2.Username,
+			},
+			TournamentID: tournament.ID,
+			MatchID:      match.ID,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to create tournament duel session: %w", err)
+		}
+
+		_, err = db.UpdateTournament(ctx, tournament.ID, func(updated *services.TournamentData) error {
+			updated_match := findMatch(updated, match.ID)
+			if updated_match == nil {
+				return ErrUnknownMatch
+			}
+			if updated_match.Status != services.TournamentMatchPending {
+				return ErrMatchAlreadyLaunched
+			}
+			updated_match.SessionID = session_id
+			updated_match.Status = services.TournamentMatchRunning
+			updated_match.Deadline = time.Now().Add(MATCH_DEADLINE)
+			return nil
+		})
+		if errors.Is(err, ErrMatchAlreadyLaunched) {
+			continue
+		} else if err != nil {
+			return err
+		}
+		slog.Debug("Tournament match launched", "tournament_id", tournament.ID, "match_id", match.ID, "session_id", session_id)
+
+		for _, player := range []*services.TournamentPlayerData{p1, p2} {
+			opponent := p2
+			if player == p2 {
+				opponent = p1
+			}
+			notify.Send(
+				ctx,
+				notifications.TypeAlert,
+				"tournament:match:ready",
+				notifications.PriorityHigh,
+				player.PID,
+				fiber.Map{
+					"msg": fmt.Sprintf("Your %s match against %s#%s is ready !", tournament.Name, opponent.Username, opponent.Tag),
+				},
+				fiber.Map{
+					"tournament_id":   tournament.ID,
+					"match_id":        match.ID,
+					"duel_session_id": session_id,
+					"duel_type":       "tournament",
+				},
+			)
+		}
+	}
+	return nil
+}
+
+func notifyWinner(ctx context.Context, notify *notifications.NotificationService, tournament services.TournamentData) {
+	winner := findPlayer(&tournament, tournament.Winner)
+	if winner == nil {
+		return
+	}
+	for _, player := range tournament.Players {
+		notify.Send(
+			ctx,
+			notifications.TypeMessage,
+			"tournament:finished",
+			notifications.PriorityMedium,
+			player.PID,
+			fiber.Map{
+				"msg": fmt.Sprintf("%s#%s has won the tournament %s !", winner.Username, winner.Tag, tournament.Name),
+			},
+			fiber.Map{
+				"tournament_id": tournament.ID,
+			},
+		)
+	}
+}
@@ -0,0 +1,127 @@
+package tournaments
+
+import (
+	"backend/lib/notifications"
+	"backend/lib/services"
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	ErrSupervisorStarted = errors.New("tournament supervisor is already started")
+)
+
+const SUPERVISOR_TICK_INTERVAL = time.Minute
+
+type Supervisor struct {
+	tick_interval time.Duration
+	is_running    bool
+	mu            sync.Mutex
+}
+
+// NewSupervisor creates the job keeping the running tournaments moving
+func NewSupervisor() *Supervisor {
+	return &Supervisor{
+		tick_interval: SUPERVISOR_TICK_INTERVAL,
+		is_running:    false,
+	}
+}
+
+// Start launches the matches left pending and resolves the ones past their deadline until the context is cancelled
+func (s *Supervisor) Start(ctx context.Context, cache *services.Cache, db *services.Database, notify *notifications.NotificationService) error {
+	s.mu.Lock()
+	if s.is_running {
+		s.mu.Unlock()
+		return ErrSupervisorStarted
+	}
+	s.is_running = true
+	s.mu.Unlock()
+
+	slog.Debug("Starting the tournament supervisor", "tick_interval", s.tick_interval)
+	go func() {
+		ticker := time.NewTicker(s.tick_interval)
+		defer ticker.Stop()
+		for {
+			if err := s.tick(ctx, cache, db, notify); err != nil {
+				slog.Error("tournament supervision failed", "error", err)
+			}
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				s.mu.Lock()
+				s.is_running = false
+				s.mu.Unlock()
+				slog.Info("context cancelled, stopping tournament supervisor")
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+func (s *Supervisor) tick(ctx context.Context, cache *services.Cache, db *services.Database, notify *notifications.NotificationService) error {
+	const page_size = 100
+
+	var running []string
+	for offset := 0; ; offset += page_size {
+		page, _, err := services.ListTournaments(ctx, db.Pool, services.TournamentStatusRunning, offset, page_size)
+		if err != nil {
+			return err
+		}
+		for _, tournament := range page {
+			running = append(running, tournament.ID)
+		}
+		if len(page) < page_size {
+			break
+		}
+	}
+
+	now := time.Now()
+	for _, tournament_id := range running {
+		if err := supervise(ctx, cache, db, notify, tournament_id, now); err != nil {
+			slog.Error("failed to supervise tournament", "error", err, "tournament_id", tournament_id)
+		}
+	}
+	return nil
+}
+
+// supervise resolves the expired matches of a running tournament then launches its pending ones,
+// including those whose launch failed previously
+func supervise(ctx context.Context, cache *services.Cache, db *services.Database, notify *notifications.NotificationService, tournament_id string, now time.Time) error {
+	var expired []string
+	tournament, err := db.UpdateTournament(ctx, tournament_id, func(tournament *services.TournamentData) error {
+		if tournament.Status != services.TournamentStatusRunning {
+			return nil
+		}
+		expired = ExpireMatches(tournament, now)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// A late result of an expired match is rejected with its session
+	for _, session_id := range expired {
+		slog.Info("Tournament match expired", "tournament_id", tournament_id, "SessionID", session_id)
+		_, err := cache.TransitionDuelSession(session_id, services.DuelSessionAborted)
+		if err != nil && !errors.Is(err, redis.Nil) && !errors.Is(err, services.ErrInvalidTransition) {
+			slog.Error("failed to abort expired tournament duel session", "error", err, "SessionID", session_id)
+		}
+	}
+
+	switch tournament.Status {
+	case services.TournamentStatusFinished:
+		if len(expired) > 0 {
+			notifyWinner(ctx, notify, tournament)
+		}
+		return nil
+	case services.TournamentStatusRunning:
+		return launchPendingMatches(ctx, cache, db, notify, tournament)
+	}
+	return nil
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"log/slog"
 	"os"
+	"sync"
 
 	v "github.com/hashicorp/vault/api"
 )
@@ -16,9 +17,12 @@ type VaultManager struct {
 	Api       *Vault
 	Services  *Vault
 
-	OpenNexusAESKey  string
-	OpenNexusHMACKey string
-	OpenAPIKey       map[string]string
+	OpenNexusAESKey string
+	OpenAPIKey      map[string]string
+
+	// The hmac key is rotated by the nexuspool handshake while background jobs read it
+	open_nexus_hmac_key string
+	hmac_mu             *sync.RWMutex
 }
 
 func NewVaultManager() (VaultManager, error) {
@@ -49,6 +53,7 @@ func NewVaultManager() (VaultManager, error) {
 		Services:        services,
 		OpenNexusAESKey: "",
 		OpenAPIKey:      open_api_key,
+		hmac_mu:         &sync.RWMutex{},
 	}
 	return vault_manager, nil
 }
@@ -100,11 +105,20 @@ func (manager *VaultManager) StoreNexusPoolHMACKey(id string, key string) error
 	if err != nil {
 		return fmt.Errorf("failed to store key in Vault: %w", err)
 	}
-	manager.OpenNexusHMACKey = key
+	manager.hmac_mu.Lock()
+	manager.open_nexus_hmac_key = key
+	manager.hmac_mu.Unlock()
 
 	return err
 }
 
+// NexusHMACKey returns the hmac key shared with the nexuspools, empty until the handshake is done
+func (manager *VaultManager) NexusHMACKey() string {
+	manager.hmac_mu.RLock()
+	defer manager.hmac_mu.RUnlock()
+	return manager.open_nexus_hmac_key
+}
+
 func (manager *VaultManager) GetNexusPoolAESKey(id string) (string, error) {
 	kvv2 := manager.NexusPool.KVv2("nexuspool")
 	path := "aes"
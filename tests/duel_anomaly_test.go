@@ -0,0 +1,69 @@
+package tests
+
+import (
+	"backend/lib/duels"
+	"backend/lib/rulesets"
+	"backend/lib/schema"
+	"backend/lib/services"
+	"reflect"
+	"testing"
+
+	basepool "github.com/ciphrpool/base-pool/gen"
+)
+
+func TestDuelResultAnomalies(t *testing.T) {
+	blitz, err := rulesets.Preset(basepool.DuelTypeFriendly, "blitz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	blitz.ResourceCaps.Energy = 500
+
+	valid := func() duels.DuelResult {
+		return duels.DuelResult{
+			P1Summary: duels.Summary{EgoCount: 2, Energy: 300},
+			P2Summary: duels.Summary{EgoCount: 0, Energy: 120},
+			Outcome:   duels.Outcome{Winner: duels.P1, Method: "elimination", Duration: 150},
+			SessionData: services.DuelSessionData{
+				DuelType: basepool.DuelTypeFriendly,
+				Ruleset:  &blitz,
+			},
+		}
+	}
+
+	tests := []struct {
+		name     string
+		alter    func(result *duels.DuelResult)
+		expected []duels.Anomaly
+	}{
+		{"valid", func(result *duels.DuelResult) {}, []duels.Anomaly{}},
+		{"negative duration", func(result *duels.DuelResult) { result.Outcome.Duration = -1 }, []duels.Anomaly{duels.AnomalyNegativeDuration}},
+		{"duration past the time limit", func(result *duels.DuelResult) {
+			result.Outcome.Duration = int64(blitz.TimeLimit + rulesets.DURATION_TOLERANCE + 1)
+		}, []duels.Anomaly{duels.AnomalyDurationExceeded}},
+		{"capped resource", func(result *duels.DuelResult) { result.P2Summary.Energy = 501 }, []duels.Anomaly{duels.AnomalyResourceExceeded}},
+		{"negative resource", func(result *duels.DuelResult) { result.P1Summary.QuantumData = -5 }, []duels.Anomaly{duels.AnomalyNegativeResource}},
+		{"eliminated winner", func(result *duels.DuelResult) { result.Outcome.Winner = duels.P2 }, []duels.Anomaly{duels.AnomalyEliminatedWinner}},
+		{"unknown winner", func(result *duels.DuelResult) { result.Outcome.Winner = "p3" }, []duels.Anomaly{duels.AnomalyUnknownWinner}},
+		// Only the forfeits claimed by the server skip the checks, the method alone proves nothing
+		{"reported forfeit", func(result *duels.DuelResult) {
+			result.P1Summary = duels.Summary{}
+			result.Outcome.Method = schema.WinningMethodForfeit
+		}, []duels.Anomaly{duels.AnomalyEliminatedWinner}},
+	}
+
+	for _, tt := range tests {
+		result := valid()
+		tt.alter(&result)
+		if anomalies := duels.DetectAnomalies(&result); !reflect.DeepEqual(anomalies, tt.expected) {
+			t.Errorf("%s: expected %v; got %v", tt.name, tt.expected, anomalies)
+		}
+	}
+
+	// Results of sessions without a ruleset are checked against the default one
+	legacy := valid()
+	legacy.SessionData.Ruleset = nil
+	legacy.Outcome.Duration = int64(rulesets.Default(basepool.DuelTypeFriendly).TimeLimit)
+	if anomalies := duels.DetectAnomalies(&legacy); len(anomalies) != 0 {
+		t.Errorf("expected no anomaly with the default ruleset; got %v", anomalies)
+	}
+}
@@ -0,0 +1,27 @@
+package tests
+
+import (
+	"backend/lib/services"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestHistoryCursor(t *testing.T) {
+	session_id, _ := services.StringToUUID("3f1c8a52-8a4e-4c1b-9d1e-0c1f2a3b4c5d")
+	cursor := services.HistoryCursor{Date: time.Date(2024, 5, 1, 12, 30, 15, 123456000, time.UTC), SessionID: session_id}
+
+	decoded, err := services.DecodeHistoryCursor(cursor.Encode())
+	if err != nil {
+		t.Fatalf("expected a valid cursor; got %v", err)
+	}
+	if !decoded.Date.Equal(cursor.Date) || decoded.SessionID != cursor.SessionID {
+		t.Errorf("expected %v; got %v", cursor, decoded)
+	}
+
+	for _, invalid := range []string{"2024-05-01T12:30:15Z", "bm90LWEtY3Vyc29y", ""} {
+		if _, err := services.DecodeHistoryCursor(invalid); !errors.Is(err, services.ErrInvalidCursor) {
+			t.Errorf("expected ErrInvalidCursor for %q; got %v", invalid, err)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package tests
+
+import (
+	"backend/lib/services"
+	"testing"
+	"time"
+)
+
+func TestDuelSessionTransitions(t *testing.T) {
+	tests := []struct {
+		from     services.DuelSessionStatus
+		to       services.DuelSessionStatus
+		expected bool
+	}{
+		{services.DuelSessionCreated, services.DuelSessionPrepared, true},
+		{services.DuelSessionPrepared, services.DuelSessionRunning, true},
+		{services.DuelSessionCreated, services.DuelSessionFinished, true},
+		{services.DuelSessionRunning, services.DuelSessionAborted, true},
+		{services.DuelSessionRunning, services.DuelSessionPrepared, false},
+		{services.DuelSessionFinished, services.DuelSessionAborted, false},
+		{services.DuelSessionAborted, services.DuelSessionFinished, false},
+	}
+
+	for _, tt := range tests {
+		if got := tt.from.CanTransition(tt.to); got != tt.expected {
+			t.Errorf("%s to %s: expected %v; got %v", tt.from, tt.to, tt.expected, got)
+		}
+	}
+
+	session_data := services.DuelSessionData{Status: services.DuelSessionAborted}
+	if !session_data.IsClosed() {
+		t.Errorf("expected an aborted session to be closed")
+	}
+}
+
+func TestDuelSessionAbandonAt(t *testing.T) {
+	updated_at := time.Now()
+	tests := []struct {
+		status   services.DuelSessionStatus
+		expected time.Time
+	}{
+		{services.DuelSessionCreated, updated_at.Add(services.DUEL_SESSION_START_TIMEOUT)},
+		{services.DuelSessionPrepared, updated_at.Add(services.DUEL_SESSION_START_TIMEOUT)},
+		{services.DuelSessionRunning, updated_at.Add(services.DUEL_SESSION_RUN_TIMEOUT)},
+	}
+
+	for _, tt := range tests {
+		session_data := services.DuelSessionData{Status: tt.status, UpdatedAt: updated_at}
+		if got := session_data.AbandonAt(); !got.Equal(tt.expected) {
+			t.Errorf("%s: expected to be abandoned at %v; got %v", tt.status, tt.expected, got)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package tests
+
+import (
+	"backend/lib/duels"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+func signedMessage(key string, session_id string, signed_at time.Time, received_at time.Time, payload string) redis.XMessage {
+	timestamp, signature := duels.SignResult(key, session_id, signed_at, payload)
+	return redis.XMessage{
+		ID: fmt.Sprintf("%d-0", received_at.UnixMilli()),
+		Values: map[string]interface{}{
+			"session_id": session_id,
+			"timestamp":  timestamp,
+			"payload":    payload,
+			"hmac":       signature,
+		},
+	}
+}
+
+func TestDuelResultSignature(t *testing.T) {
+	key := "secret"
+	session_id := "3f1c8a52-8a4e-4c1b-9d1e-0c1f2a3b4c5d"
+	payload := `{"outcome":{"winner":"p1"}}`
+	now := time.Now()
+
+	message := signedMessage(key, session_id, now, now, payload)
+	if err := duels.VerifyResult(key, message, session_id, payload); err != nil {
+		t.Errorf("expected a valid signature; got %v", err)
+	}
+
+	// A redelivered result is still valid long after it was received
+	message = signedMessage(key, session_id, now.Add(-time.Hour), now.Add(-time.Hour), payload)
+	if err := duels.VerifyResult(key, message, session_id, payload); err != nil {
+		t.Errorf("expected a redelivered result to be valid; got %v", err)
+	}
+
+	message = signedMessage(key, session_id, now.Add(-time.Hour), now, payload)
+	if err := duels.VerifyResult(key, message, session_id, payload); !errors.Is(err, duels.ErrStaleResult) {
+		t.Errorf("expected a stale result; got %v", err)
+	}
+
+	message = signedMessage(key, session_id, now, now, payload)
+	if err := duels.VerifyResult(key, message, session_id, `{"outcome":{"winner":"p2"}}`); !errors.Is(err, duels.ErrInvalidResult) {
+		t.Errorf("expected a tampered payload to be rejected; got %v", err)
+	}
+	if err := duels.VerifyResult(key, message, "another-session", payload); !errors.Is(err, duels.ErrInvalidResult) {
+		t.Errorf("expected a tampered session id to be rejected; got %v", err)
+	}
+	if err := duels.VerifyResult("another key", message, session_id, payload); !errors.Is(err, duels.ErrInvalidResult) {
+		t.Errorf("expected a result signed with another key to be rejected; got %v", err)
+	}
+
+	delete(message.Values, "hmac")
+	if err := duels.VerifyResult(key, message, session_id, payload); !errors.Is(err, duels.ErrUnsignedResult) {
+		t.Errorf("expected an unsigned result to be rejected; got %v", err)
+	}
+}
@@ -0,0 +1,57 @@
+package tests
+
+import (
+	"backend/lib/rating"
+	"testing"
+)
+
+func TestRatingDeltas(t *testing.T) {
+	p1 := rating.Player{Rating: 1000, GamesPlayed: 50}
+	p2 := rating.Player{Rating: 1000, GamesPlayed: 50}
+
+	// Equal players : the winner takes half the K-factor
+	p1_delta, p2_delta := rating.Deltas(p1, p2, rating.Win)
+	if p1_delta != 10 || p2_delta != -10 {
+		t.Errorf("expected deltas 10/-10; got %d/%d", p1_delta, p2_delta)
+	}
+
+	// Equal players : a draw changes nothing
+	p1_delta, p2_delta = rating.Deltas(p1, p2, rating.Draw)
+	if p1_delta != 0 || p2_delta != 0 {
+		t.Errorf("expected deltas 0/0; got %d/%d", p1_delta, p2_delta)
+	}
+
+	// A draw against a stronger player is a gain
+	strong := rating.Player{Rating: 1400, GamesPlayed: 50}
+	p1_delta, p2_delta = rating.Deltas(p1, strong, rating.Draw)
+	if p1_delta <= 0 || p2_delta >= 0 {
+		t.Errorf("expected positive/negative deltas; got %d/%d", p1_delta, p2_delta)
+	}
+}
+
+func TestRatingKFactorTiers(t *testing.T) {
+	tests := []struct {
+		player   rating.Player
+		expected int
+	}{
+		{rating.Player{Rating: 1000, GamesPlayed: 0}, rating.K_PROVISIONAL},
+		{rating.Player{Rating: 2500, GamesPlayed: 10}, rating.K_PROVISIONAL},
+		{rating.Player{Rating: 1000, GamesPlayed: 30}, rating.K_STANDARD},
+		{rating.Player{Rating: 2400, GamesPlayed: 30}, rating.K_MASTER},
+	}
+	for _, test := range tests {
+		if k := rating.KFactor(test.player); k != test.expected {
+			t.Errorf("expected K-factor %d for %+v; got %d", test.expected, test.player, k)
+		}
+	}
+}
+
+func TestRatingFloor(t *testing.T) {
+	p1 := rating.Player{Rating: rating.MIN_RATING + 5, GamesPlayed: 0}
+	p2 := rating.Player{Rating: rating.MIN_RATING, GamesPlayed: 0}
+
+	p1_delta, _ := rating.Deltas(p1, p2, rating.Loss)
+	if p1.Rating+p1_delta != rating.MIN_RATING {
+		t.Errorf("expected rating to be floored at %d; got %d", rating.MIN_RATING, p1.Rating+p1_delta)
+	}
+}
@@ -0,0 +1,61 @@
+package tests
+
+import (
+	"backend/lib/replays"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestReplayLocalStore(t *testing.T) {
+	t.Setenv("REPLAYS_DIR", t.TempDir())
+	store := replays.NewLocalStore()
+	ctx := context.Background()
+	session_id := "3f1c8a52-8a4e-4c1b-9d1e-0c1f2a3b4c5d"
+	content := []byte{0x1f, 0x8b, 0x08, 0x00}
+
+	size, err := store.Put(ctx, session_id, bytes.NewReader(content))
+	if err != nil || size != int64(len(content)) {
+		t.Fatalf("expected the replay to be stored; got %d, %v", size, err)
+	}
+
+	reader, err := store.Get(ctx, session_id)
+	if err != nil {
+		t.Fatalf("expected the replay to be found; got %v", err)
+	}
+	stored, _ := io.ReadAll(reader)
+	reader.Close()
+	if !bytes.Equal(stored, content) {
+		t.Errorf("expected the stored replay to match the upload")
+	}
+
+	if err := store.Delete(ctx, session_id); err != nil {
+		t.Fatalf("expected the replay to be deleted; got %v", err)
+	}
+	if _, err := store.Get(ctx, session_id); !errors.Is(err, replays.ErrReplayNotFound) {
+		t.Errorf("expected ErrReplayNotFound; got %v", err)
+	}
+
+	if _, err := store.Put(ctx, "../escape", bytes.NewReader(content)); !errors.Is(err, replays.ErrInvalidKey) {
+		t.Errorf("expected ErrInvalidKey; got %v", err)
+	}
+}
+
+func TestReplaySignature(t *testing.T) {
+	key := "secret"
+	session_id := "3f1c8a52-8a4e-4c1b-9d1e-0c1f2a3b4c5d"
+	content := []byte{0x1f, 0x8b, 0x08, 0x00}
+
+	signature := replays.SignReplay(key, session_id, content)
+	if err := replays.VerifyReplay(key, session_id, content, signature); err != nil {
+		t.Errorf("expected a valid signature; got %v", err)
+	}
+	if err := replays.VerifyReplay(key, "other-session", content, signature); !errors.Is(err, replays.ErrInvalidSignature) {
+		t.Errorf("expected ErrInvalidSignature for another session; got %v", err)
+	}
+	if err := replays.VerifyReplay(key, session_id, append(content, 0x01), signature); !errors.Is(err, replays.ErrInvalidSignature) {
+		t.Errorf("expected ErrInvalidSignature for altered content; got %v", err)
+	}
+}
@@ -0,0 +1,69 @@
+package tests
+
+import (
+	"backend/lib/rulesets"
+	"errors"
+	"testing"
+
+	basepool "github.com/ciphrpool/base-pool/gen"
+)
+
+func TestRulesetPresets(t *testing.T) {
+	for _, duel_type := range []basepool.DuelType{basepool.DuelTypeFriendly, basepool.DuelTypeRanked, basepool.DuelTypeTournament} {
+		presets := rulesets.Presets(duel_type)
+		if len(presets) == 0 {
+			t.Fatalf("expected presets for %s", duel_type)
+		}
+		for _, preset := range presets {
+			if err := preset.Validate(); err != nil {
+				t.Errorf("preset %s of %s is invalid: %v", preset.Preset, duel_type, err)
+			}
+		}
+		if ruleset := rulesets.Default(duel_type); ruleset.Preset != rulesets.DEFAULT_PRESET {
+			t.Errorf("expected the default preset for %s; got %s", duel_type, ruleset.Preset)
+		}
+	}
+
+	if _, err := rulesets.Preset(basepool.DuelTypeRanked, "blitz"); !errors.Is(err, rulesets.ErrUnknownPreset) {
+		t.Errorf("expected ErrUnknownPreset for a ranked blitz; got %v", err)
+	}
+}
+
+func TestRulesetValidation(t *testing.T) {
+	standard := rulesets.Default(basepool.DuelTypeFriendly)
+
+	unsupported := standard
+	unsupported.Version = rulesets.RULESET_VERSION + 1
+	if err := unsupported.Validate(); !errors.Is(err, rulesets.ErrUnsupportedVersion) {
+		t.Errorf("expected ErrUnsupportedVersion; got %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		overrides rulesets.Overrides
+		valid     bool
+	}{
+		{"none", rulesets.Overrides{}, true},
+		{"time limit", rulesets.Overrides{TimeLimit: intPtr(300)}, true},
+		{"short time limit", rulesets.Overrides{TimeLimit: intPtr(10)}, false},
+		{"negative energy", rulesets.Overrides{StartingEnergy: intPtr(-1)}, false},
+		{"resource cap", rulesets.Overrides{ResourceCaps: &rulesets.ResourceCaps{Energy: rulesets.MAX_RESOURCE_CAP + 1}}, false},
+		{"victory condition", rulesets.Overrides{VictoryConditions: []rulesets.VictoryCondition{"surrender"}}, false},
+		{"duplicate victory condition", rulesets.Overrides{VictoryConditions: []rulesets.VictoryCondition{rulesets.VictoryTimeout, rulesets.VictoryTimeout}}, false},
+	}
+
+	for _, tt := range tests {
+		customized, err := rulesets.Customize(standard, tt.overrides)
+		if (err == nil) != tt.valid {
+			t.Errorf("%s: expected valid=%v; got %v", tt.name, tt.valid, err)
+			continue
+		}
+		if err == nil && customized.Customized != (tt.name != "none") {
+			t.Errorf("%s: unexpected customized flag %v", tt.name, customized.Customized)
+		}
+	}
+}
+
+func intPtr(value int) *int {
+	return &value
+}
@@ -0,0 +1,77 @@
+package tests
+
+import (
+	"backend/lib/services"
+	"backend/lib/stats"
+	"testing"
+
+	basepool "github.com/ciphrpool/base-pool/gen"
+)
+
+func TestStatsAggregate(t *testing.T) {
+	samples := []stats.Sample{
+		{DuelType: "ranked", Result: stats.Win, Method: "ego_depletion", Duration: 100, Resources: stats.Resources{EgoCount: 4, Energy: 10}},
+		{DuelType: "ranked", Result: stats.Loss, Method: "ego_depletion", Duration: 200, Resources: stats.Resources{EgoCount: 2, Energy: 20}},
+		{DuelType: "friendly", Result: stats.Draw, Method: "timeout", Duration: 300, Resources: stats.Resources{EgoCount: 0, Energy: 30}},
+	}
+
+	counters := make(map[string]int64)
+	for _, sample := range samples {
+		for field, value := range stats.Counters(sample) {
+			counters[field] += value
+		}
+	}
+	user_stats := stats.Aggregate(counters)
+
+	if user_stats.Played != 3 || user_stats.Wins != 1 || user_stats.Losses != 1 || user_stats.Draws != 1 {
+		t.Errorf("expected 3 duels with 1/1/1; got %+v", user_stats.TypeStats)
+	}
+	if ranked := user_stats.ByType["ranked"]; ranked.Played != 2 || ranked.WinRate != 0.5 {
+		t.Errorf("expected 2 ranked duels at 50%%; got %+v", ranked)
+	}
+	if method := user_stats.ByMethod["ego_depletion"]; method.Played != 2 || method.Wins != 1 {
+		t.Errorf("expected 1 win out of 2 by ego depletion; got %+v", method)
+	}
+	if method := user_stats.ByMethod["timeout"]; method.Played != 1 || method.Wins != 0 {
+		t.Errorf("expected no win out of 1 by timeout; got %+v", method)
+	}
+	if user_stats.AverageDuration != 200 {
+		t.Errorf("expected an average duration of 200; got %f", user_stats.AverageDuration)
+	}
+	if user_stats.Averages.EgoCount != 2 || user_stats.Averages.Energy != 20 {
+		t.Errorf("expected resource averages 2/20; got %+v", user_stats.Averages)
+	}
+
+	// A player without duels has empty stats
+	if empty := stats.Aggregate(map[string]int64{"duels": 0}); empty.Played != 0 || empty.WinRate != 0 {
+		t.Errorf("expected empty stats; got %+v", empty)
+	}
+}
+
+func TestStatsHeadToHead(t *testing.T) {
+	// From the most recent duel, the requesting user is p1 on even rows
+	rows := []services.HeadToHeadRow{
+		{DuelOutcome: string(basepool.DuelOutcomeP1WON), IsP1: true, Duration: 10},
+		{DuelOutcome: string(basepool.DuelOutcomeP1WON), IsP1: false, Duration: 20},
+		{DuelOutcome: string(basepool.DuelOutcomeP1WON), IsP1: true, Duration: 30},
+		{DuelOutcome: string(basepool.DuelOutcomeDraw), IsP1: false, Duration: 40},
+	}
+
+	head_to_head := stats.ComputeHeadToHead(rows, 2)
+	if head_to_head.Played != 4 || head_to_head.Wins != 2 || head_to_head.Losses != 1 || head_to_head.Draws != 1 {
+		t.Errorf("expected 4 duels with 2/1/1; got %+v", head_to_head)
+	}
+	if head_to_head.Streak.Result != stats.Win || head_to_head.Streak.Count != 1 {
+		t.Errorf("expected a streak of 1 win; got %+v", head_to_head.Streak)
+	}
+	if len(head_to_head.Last) != 2 || head_to_head.Last[1].Result != stats.Loss {
+		t.Errorf("expected the last 2 duels ending with a loss; got %+v", head_to_head.Last)
+	}
+	if head_to_head.AverageDuration != 25 {
+		t.Errorf("expected an average duration of 25; got %f", head_to_head.AverageDuration)
+	}
+
+	if empty := stats.ComputeHeadToHead(nil, 5); empty.Played != 0 || empty.Streak.Count != 0 {
+		t.Errorf("expected an empty head to head; got %+v", empty)
+	}
+}
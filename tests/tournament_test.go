@@ -0,0 +1,161 @@
+package tests
+
+import (
+	"backend/lib/services"
+	"backend/lib/tournaments"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+func newTournamentPlayers(elos ...uint) []services.TournamentPlayerData {
+	players := make([]services.TournamentPlayerData, len(elos))
+	for i, elo := range elos {
+		players[i] = services.TournamentPlayerData{
+			PID: pgtype.UUID{Bytes: uuid.New(), Valid: true},
+			Elo: elo,
+		}
+	}
+	return players
+}
+
+func TestTournamentSeedOrder(t *testing.T) {
+	expected := []int{0, 7, 3, 4, 1, 6, 2, 5}
+	order := tournaments.SeedOrder(8)
+	for i := range expected {
+		if order[i] != expected[i] {
+			t.Fatalf("expected seed order %v; got %v", expected, order)
+		}
+	}
+}
+
+func TestTournamentSingleEliminationByes(t *testing.T) {
+	players := newTournamentPlayers(1500, 1400, 1300, 1200, 1100)
+	round := tournaments.SingleEliminationFirstRound(players)
+
+	if len(round.Matches) != 4 {
+		t.Fatalf("expected 4 matches; got %d", len(round.Matches))
+	}
+	byes := 0
+	for _, match := range round.Matches {
+		if !match.P1.Valid {
+			t.Errorf("expected every match to have a first player")
+		}
+		if !match.P2.Valid {
+			byes++
+			if match.Status != services.TournamentMatchFinished || match.Winner.Bytes != match.P1.Bytes {
+				t.Errorf("expected a bye to be won by the first player")
+			}
+		}
+	}
+	if byes != 3 {
+		t.Errorf("expected 3 byes; got %d", byes)
+	}
+	// The top seed gets a bye
+	if round.Matches[0].P1.Bytes != players[0].PID.Bytes || round.Matches[0].P2.Valid {
+		t.Errorf("expected the top seed to get a bye")
+	}
+}
+
+func TestTournamentSwissAvoidsRematches(t *testing.T) {
+	players := newTournamentPlayers(1500, 1400, 1300, 1200)
+	first := tournaments.SwissNextRound(players, nil)
+	if len(first.Matches) != 2 {
+		t.Fatalf("expected 2 matches; got %d", len(first.Matches))
+	}
+
+	second := tournaments.SwissNextRound(players, []services.TournamentRoundData{first})
+	for _, match := range second.Matches {
+		for _, previous := range first.Matches {
+			if (match.P1.Bytes == previous.P1.Bytes && match.P2.Bytes == previous.P2.Bytes) ||
+				(match.P1.Bytes == previous.P2.Bytes && match.P2.Bytes == previous.P1.Bytes) {
+				t.Errorf("expected no rematch in the second round")
+			}
+		}
+	}
+}
+
+func TestTournamentMatchDeadline(t *testing.T) {
+	players := newTournamentPlayers(1500, 1400)
+	tournament := services.TournamentData{
+		Format:  services.TournamentFormatSingleElimination,
+		Status:  services.TournamentStatusRunning,
+		Players: players,
+		Rounds:  []services.TournamentRoundData{tournaments.SingleEliminationFirstRound(players)},
+	}
+	now := time.Now()
+	launch := func(session_id string, deadline time.Time) {
+		match := &tournament.Rounds[0].Matches[0]
+		match.SessionID = session_id
+		match.Status = services.TournamentMatchRunning
+		match.Deadline = deadline
+	}
+
+	launch("in-time", now.Add(time.Minute))
+	if expired := tournaments.ExpireMatches(&tournament, now); len(expired) != 0 {
+		t.Fatalf("expected no expired match before the deadline; got %v", expired)
+	}
+
+	// An unplayed match is played again, then won by walkover by the better seed
+	for replay := 1; replay <= tournaments.MAX_MATCH_REPLAYS; replay++ {
+		launch("late", now.Add(-time.Minute))
+		if expired := tournaments.ExpireMatches(&tournament, now); len(expired) != 1 || expired[0] != "late" {
+			t.Fatalf("expected the late match to expire; got %v", expired)
+		}
+		match := tournament.Rounds[0].Matches[0]
+		if match.Status != services.TournamentMatchPending || match.Replays != replay {
+			t.Fatalf("expected the match to be replayed %d times; got %s after %d", replay, match.Status, match.Replays)
+		}
+	}
+	launch("late", now.Add(-time.Minute))
+	tournaments.ExpireMatches(&tournament, now)
+	if tournament.Status != services.TournamentStatusFinished || tournament.Winner.Bytes != players[0].PID.Bytes {
+		t.Errorf("expected the better seed to win by walkover; got %s won by %v", tournament.Status, tournament.Winner)
+	}
+}
+
+func TestTournamentAbandonedMatch(t *testing.T) {
+	launch := func(format services.TournamentFormat) services.TournamentData {
+		players := newTournamentPlayers(1500, 1400)
+		tournament := services.TournamentData{
+			Format:  format,
+			Status:  services.TournamentStatusRunning,
+			Players: players,
+			Rounds:  []services.TournamentRoundData{tournaments.SingleEliminationFirstRound(players)},
+		}
+		match := &tournament.Rounds[0].Matches[0]
+		match.SessionID = "aborted"
+		match.Status = services.TournamentMatchRunning
+		return tournament
+	}
+
+	if err := tournaments.RecordAbandoned(&services.TournamentData{}, "aborted"); err != tournaments.ErrUnknownMatch {
+		t.Errorf("expected an unknown match outside of a tournament; got %v", err)
+	}
+
+	// Neither player is eliminated, the match is played again
+	elimination := launch(services.TournamentFormatSingleElimination)
+	if err := tournaments.RecordAbandoned(&elimination, "aborted"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	match := elimination.Rounds[0].Matches[0]
+	if match.Status != services.TournamentMatchPending || match.SessionID != "" || match.Replays != 1 {
+		t.Errorf("expected the match to be replayed; got %s with session %q after %d replays", match.Status, match.SessionID, match.Replays)
+	}
+
+	// A swiss draw splits the point
+	swiss := launch(services.TournamentFormatSwiss)
+	if err := tournaments.RecordAbandoned(&swiss, "aborted"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if swiss.Rounds[0].Matches[0].Status != services.TournamentMatchFinished {
+		t.Errorf("expected the swiss match to be finished; got %s", swiss.Rounds[0].Matches[0].Status)
+	}
+	for _, player := range swiss.Players {
+		if player.Score != 0.5 {
+			t.Errorf("expected the point to be split; got %v", player.Score)
+		}
+	}
+}